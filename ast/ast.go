@@ -2,13 +2,20 @@ package ast
 
 import (
 	"bytes"
+	"math/big"
+	"strings"
 
+	"github.com/chaitanya-Uike/lemon/decimal"
 	"github.com/chaitanya-Uike/lemon/token"
 )
 
 type Node interface {
 	TokenLiteral() string
 	String() string
+	// Pos is the position of the first character of the node and End the
+	// position immediately after it.
+	Pos() token.Position
+	End() token.Position
 }
 
 type Statement interface {
@@ -32,6 +39,19 @@ func (p *Program) TokenLiteral() string {
 	return ""
 }
 
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return token.Position{}
+}
+func (p *Program) End() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[len(p.Statements)-1].End()
+	}
+	return token.Position{}
+}
+
 func (p *Program) String() string {
 	var out bytes.Buffer
 	for _, stmt := range p.Statements {
@@ -40,6 +60,31 @@ func (p *Program) String() string {
 	return out.String()
 }
 
+// BadStatement stands in for source the parser could not make a statement
+// of, it spans the tokens From through To.
+type BadStatement struct {
+	From token.Token
+	To   token.Token
+}
+
+func (bs *BadStatement) statementNode()       {}
+func (bs *BadStatement) TokenLiteral() string { return bs.From.Literal }
+func (bs *BadStatement) Pos() token.Position  { return bs.From.Pos }
+func (bs *BadStatement) End() token.Position  { return bs.To.End }
+func (bs *BadStatement) String() string       { return "<bad statement>" }
+
+// BadExpression stands in for an expression that failed to parse.
+type BadExpression struct {
+	From token.Token
+	To   token.Token
+}
+
+func (be *BadExpression) expressionNode()      {}
+func (be *BadExpression) TokenLiteral() string { return be.From.Literal }
+func (be *BadExpression) Pos() token.Position  { return be.From.Pos }
+func (be *BadExpression) End() token.Position  { return be.To.End }
+func (be *BadExpression) String() string       { return "<bad expression>" }
+
 type ExpressionStatement struct {
 	Token      token.Token
 	Expression Expression
@@ -47,6 +92,13 @@ type ExpressionStatement struct {
 
 func (es *ExpressionStatement) statementNode()       {}
 func (es *ExpressionStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExpressionStatement) Pos() token.Position  { return es.Token.Pos }
+func (es *ExpressionStatement) End() token.Position {
+	if es.Expression != nil {
+		return es.Expression.End()
+	}
+	return es.Token.End
+}
 func (es *ExpressionStatement) String() string {
 	return es.Expression.String()
 }
@@ -58,15 +110,22 @@ type IdentifierLiteral struct {
 
 func (il *IdentifierLiteral) expressionNode()      {}
 func (il *IdentifierLiteral) TokenLiteral() string { return il.Token.Literal }
+func (il *IdentifierLiteral) Pos() token.Position  { return il.Token.Pos }
+func (il *IdentifierLiteral) End() token.Position  { return il.Token.End }
 func (il *IdentifierLiteral) String() string       { return il.Value }
 
+// IntegerLiteral holds literals that don't fit an int64 in Big, Value is 0
+// for those.
 type IntegerLiteral struct {
 	Token token.Token
 	Value int64
+	Big   *big.Int
 }
 
 func (il *IntegerLiteral) expressionNode()      {}
 func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
+func (il *IntegerLiteral) Pos() token.Position  { return il.Token.Pos }
+func (il *IntegerLiteral) End() token.Position  { return il.Token.End }
 func (il *IntegerLiteral) String() string       { return il.Token.Literal }
 
 type FloatLiteral struct {
@@ -76,8 +135,22 @@ type FloatLiteral struct {
 
 func (fl *FloatLiteral) expressionNode()      {}
 func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FloatLiteral) Pos() token.Position  { return fl.Token.Pos }
+func (fl *FloatLiteral) End() token.Position  { return fl.Token.End }
 func (fl *FloatLiteral) String() string       { return fl.Token.Literal }
 
+// DecimalLiteral is a number with a d suffix, evaluated exactly in base 10.
+type DecimalLiteral struct {
+	Token token.Token
+	Value decimal.Decimal
+}
+
+func (dl *DecimalLiteral) expressionNode()      {}
+func (dl *DecimalLiteral) TokenLiteral() string { return dl.Token.Literal }
+func (dl *DecimalLiteral) Pos() token.Position  { return dl.Token.Pos }
+func (dl *DecimalLiteral) End() token.Position  { return dl.Token.End }
+func (dl *DecimalLiteral) String() string       { return dl.Token.Literal }
+
 type BooleanLiteral struct {
 	Token token.Token
 	Value bool
@@ -85,8 +158,35 @@ type BooleanLiteral struct {
 
 func (bl *BooleanLiteral) expressionNode()      {}
 func (bl *BooleanLiteral) TokenLiteral() string { return bl.Token.Literal }
+func (bl *BooleanLiteral) Pos() token.Position  { return bl.Token.Pos }
+func (bl *BooleanLiteral) End() token.Position  { return bl.Token.End }
 func (bl *BooleanLiteral) String() string       { return bl.Token.Literal }
 
+type StringLiteral struct {
+	Token token.Token
+	Value string
+}
+
+func (sl *StringLiteral) expressionNode()      {}
+func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
+func (sl *StringLiteral) Pos() token.Position  { return sl.Token.Pos }
+func (sl *StringLiteral) End() token.Position  { return sl.Token.End }
+func (sl *StringLiteral) String() string       { return "\"" + sl.Token.Literal + "\"" }
+
+// InterpolatedString is a string literal containing ${...} interpolations.
+// Parts holds the literal segments as *StringLiteral alternating with the
+// embedded expressions, in source order.
+type InterpolatedString struct {
+	Token token.Token
+	Parts []Expression
+}
+
+func (is *InterpolatedString) expressionNode()      {}
+func (is *InterpolatedString) TokenLiteral() string { return is.Token.Literal }
+func (is *InterpolatedString) Pos() token.Position  { return is.Token.Pos }
+func (is *InterpolatedString) End() token.Position  { return is.Token.End }
+func (is *InterpolatedString) String() string       { return "\"" + is.Token.Literal + "\"" }
+
 type PrefixExpression struct {
 	Token      token.Token
 	Operator   string
@@ -95,6 +195,8 @@ type PrefixExpression struct {
 
 func (pe *PrefixExpression) expressionNode()      {}
 func (pe *PrefixExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PrefixExpression) Pos() token.Position  { return pe.Token.Pos }
+func (pe *PrefixExpression) End() token.Position  { return pe.Expression.End() }
 func (pe *PrefixExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
@@ -113,6 +215,8 @@ type InfixExpression struct {
 
 func (ie *InfixExpression) expressionNode()      {}
 func (ie *InfixExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *InfixExpression) Pos() token.Position  { return ie.Left.Pos() }
+func (ie *InfixExpression) End() token.Position  { return ie.Right.End() }
 func (ie *InfixExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
@@ -123,6 +227,52 @@ func (ie *InfixExpression) String() string {
 	return out.String()
 }
 
+type DeclareStatement struct {
+	Token token.Token
+	Name  *IdentifierLiteral
+	Type  TypeExpression // nil without an annotation
+	Value Expression
+}
+
+func (ds *DeclareStatement) statementNode()       {}
+func (ds *DeclareStatement) TokenLiteral() string { return ds.Token.Literal }
+func (ds *DeclareStatement) Pos() token.Position  { return ds.Name.Pos() }
+func (ds *DeclareStatement) End() token.Position  { return ds.Value.End() }
+func (ds *DeclareStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString(ds.Name.String())
+	if ds.Type != nil {
+		out.WriteString(": " + ds.Type.String())
+	}
+	out.WriteString(" " + ds.TokenLiteral() + " ")
+	if ds.Value != nil {
+		out.WriteString(ds.Value.String())
+	}
+	return out.String()
+}
+
+// AssignStatement rebinds a variable or stores into an element, Target is
+// either an *IdentifierLiteral or an *IndexExpression.
+type AssignStatement struct {
+	Token  token.Token
+	Target Expression
+	Value  Expression
+}
+
+func (as *AssignStatement) statementNode()       {}
+func (as *AssignStatement) TokenLiteral() string { return as.Token.Literal }
+func (as *AssignStatement) Pos() token.Position  { return as.Target.Pos() }
+func (as *AssignStatement) End() token.Position  { return as.Value.End() }
+func (as *AssignStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString(as.Target.String())
+	out.WriteString(" " + as.TokenLiteral() + " ")
+	if as.Value != nil {
+		out.WriteString(as.Value.String())
+	}
+	return out.String()
+}
+
 type ReturnStatement struct {
 	Token       token.Token
 	ReturnValue Expression
@@ -130,6 +280,13 @@ type ReturnStatement struct {
 
 func (rs *ReturnStatement) statementNode()       {}
 func (rs *ReturnStatement) TokenLiteral() string { return rs.Token.Literal }
+func (rs *ReturnStatement) Pos() token.Position  { return rs.Token.Pos }
+func (rs *ReturnStatement) End() token.Position {
+	if rs.ReturnValue != nil {
+		return rs.ReturnValue.End()
+	}
+	return rs.Token.End
+}
 func (rs *ReturnStatement) String() string {
 	var out bytes.Buffer
 	out.WriteString(rs.TokenLiteral())
@@ -147,10 +304,13 @@ type AlternateStatement interface {
 type BlockStatement struct {
 	Token      token.Token
 	Statements []Statement
+	Rbrace     token.Token
 }
 
 func (bs *BlockStatement) statementNode()       {}
 func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BlockStatement) Pos() token.Position  { return bs.Token.Pos }
+func (bs *BlockStatement) End() token.Position  { return bs.Rbrace.End }
 func (bs *BlockStatement) String() string {
 	var out bytes.Buffer
 	out.WriteString("{")
@@ -172,6 +332,13 @@ type IfStatement struct {
 func (is *IfStatement) statementNode()          {}
 func (is *IfStatement) alternateStatementNode() {}
 func (is *IfStatement) TokenLiteral() string    { return is.Token.Literal }
+func (is *IfStatement) Pos() token.Position     { return is.Token.Pos }
+func (is *IfStatement) End() token.Position {
+	if is.Alternate != nil {
+		return is.Alternate.End()
+	}
+	return is.Consequence.End()
+}
 func (is *IfStatement) String() string {
 	var out bytes.Buffer
 
@@ -186,3 +353,305 @@ func (is *IfStatement) String() string {
 
 	return out.String()
 }
+
+type WhileStatement struct {
+	Token     token.Token
+	Condition Expression
+	Body      *BlockStatement
+}
+
+func (ws *WhileStatement) statementNode()       {}
+func (ws *WhileStatement) TokenLiteral() string { return ws.Token.Literal }
+func (ws *WhileStatement) Pos() token.Position  { return ws.Token.Pos }
+func (ws *WhileStatement) End() token.Position  { return ws.Body.End() }
+func (ws *WhileStatement) String() string {
+	return "while " + ws.Condition.String() + " " + ws.Body.String()
+}
+
+// ForInStatement is `for x in iterable {...}`.
+type ForInStatement struct {
+	Token    token.Token
+	Variable *IdentifierLiteral
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (fs *ForInStatement) statementNode()       {}
+func (fs *ForInStatement) TokenLiteral() string { return fs.Token.Literal }
+func (fs *ForInStatement) Pos() token.Position  { return fs.Token.Pos }
+func (fs *ForInStatement) End() token.Position  { return fs.Body.End() }
+func (fs *ForInStatement) String() string {
+	return "for " + fs.Variable.String() + " in " + fs.Iterable.String() + " " + fs.Body.String()
+}
+
+// ForStatement is `for init; cond; post {...}`, any of the three clauses may
+// be nil and a missing condition loops forever.
+type ForStatement struct {
+	Token     token.Token
+	Init      Statement
+	Condition Expression
+	Post      Statement
+	Body      *BlockStatement
+}
+
+func (fs *ForStatement) statementNode()       {}
+func (fs *ForStatement) TokenLiteral() string { return fs.Token.Literal }
+func (fs *ForStatement) Pos() token.Position  { return fs.Token.Pos }
+func (fs *ForStatement) End() token.Position  { return fs.Body.End() }
+func (fs *ForStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("for ")
+	if fs.Init != nil {
+		out.WriteString(fs.Init.String())
+	}
+	out.WriteString("; ")
+	if fs.Condition != nil {
+		out.WriteString(fs.Condition.String())
+	}
+	out.WriteString("; ")
+	if fs.Post != nil {
+		out.WriteString(fs.Post.String() + " ")
+	}
+	out.WriteString(fs.Body.String())
+
+	return out.String()
+}
+
+// BranchStatement is a break or continue, Label is nil unless one was given.
+type BranchStatement struct {
+	Token token.Token
+	Label *IdentifierLiteral
+}
+
+func (bs *BranchStatement) statementNode()       {}
+func (bs *BranchStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BranchStatement) Pos() token.Position  { return bs.Token.Pos }
+func (bs *BranchStatement) End() token.Position {
+	if bs.Label != nil {
+		return bs.Label.End()
+	}
+	return bs.Token.End
+}
+func (bs *BranchStatement) String() string {
+	if bs.Label != nil {
+		return bs.TokenLiteral() + " " + bs.Label.String()
+	}
+	return bs.TokenLiteral()
+}
+
+// LabeledStatement is a loop prefixed with `label:`.
+type LabeledStatement struct {
+	Label     *IdentifierLiteral
+	Statement Statement
+}
+
+func (ls *LabeledStatement) statementNode()       {}
+func (ls *LabeledStatement) TokenLiteral() string { return ls.Label.TokenLiteral() }
+func (ls *LabeledStatement) Pos() token.Position  { return ls.Label.Pos() }
+func (ls *LabeledStatement) End() token.Position  { return ls.Statement.End() }
+func (ls *LabeledStatement) String() string {
+	return ls.Label.String() + ": " + ls.Statement.String()
+}
+
+type FunctionLiteral struct {
+	Token      token.Token
+	Parameters []*IdentifierLiteral
+	// ParameterTypes holds the annotation of each parameter, nil for those
+	// without one.
+	ParameterTypes []TypeExpression
+	ReturnType     TypeExpression // nil without an annotation
+	Body           *BlockStatement
+}
+
+func (fl *FunctionLiteral) expressionNode()      {}
+func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FunctionLiteral) Pos() token.Position  { return fl.Token.Pos }
+func (fl *FunctionLiteral) End() token.Position  { return fl.Body.End() }
+func (fl *FunctionLiteral) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(fl.TokenLiteral())
+	out.WriteString(fl.signature())
+	out.WriteString(fl.Body.String())
+
+	return out.String()
+}
+
+// signature writes the annotated parameters and return type of the
+// function, followed by a space.
+func (fl *FunctionLiteral) signature() string {
+	params := []string{}
+	for i, p := range fl.Parameters {
+		if i < len(fl.ParameterTypes) && fl.ParameterTypes[i] != nil {
+			params = append(params, p.String()+": "+fl.ParameterTypes[i].String())
+			continue
+		}
+		params = append(params, p.String())
+	}
+
+	out := "(" + strings.Join(params, ", ") + ")"
+	if fl.ReturnType != nil {
+		out += ": " + fl.ReturnType.String()
+	}
+	return out + " "
+}
+
+type FunctionStatement struct {
+	Token    token.Token
+	Name     *IdentifierLiteral
+	Function *FunctionLiteral
+	Doc      string // text of the /// comment above the statement
+}
+
+func (fs *FunctionStatement) statementNode()       {}
+func (fs *FunctionStatement) TokenLiteral() string { return fs.Token.Literal }
+func (fs *FunctionStatement) Pos() token.Position  { return fs.Token.Pos }
+func (fs *FunctionStatement) End() token.Position  { return fs.Function.End() }
+func (fs *FunctionStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(fs.TokenLiteral())
+	out.WriteString(" " + fs.Name.String())
+	out.WriteString(fs.Function.signature())
+	out.WriteString(fs.Function.Body.String())
+
+	return out.String()
+}
+
+type CallExpression struct {
+	Token     token.Token
+	Function  Expression
+	Arguments []Expression
+	Rparen    token.Token
+}
+
+func (ce *CallExpression) expressionNode()      {}
+func (ce *CallExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *CallExpression) Pos() token.Position  { return ce.Function.Pos() }
+func (ce *CallExpression) End() token.Position  { return ce.Rparen.End }
+func (ce *CallExpression) String() string {
+	var out bytes.Buffer
+
+	args := []string{}
+	for _, a := range ce.Arguments {
+		args = append(args, a.String())
+	}
+
+	out.WriteString(ce.Function.String())
+	out.WriteString("(")
+	out.WriteString(strings.Join(args, ", "))
+	out.WriteString(")")
+
+	return out.String()
+}
+
+type ArrayLiteral struct {
+	Token    token.Token
+	Elements []Expression
+	Rbracket token.Token
+}
+
+func (al *ArrayLiteral) expressionNode()      {}
+func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
+func (al *ArrayLiteral) Pos() token.Position  { return al.Token.Pos }
+func (al *ArrayLiteral) End() token.Position  { return al.Rbracket.End }
+func (al *ArrayLiteral) String() string {
+	var out bytes.Buffer
+
+	elements := []string{}
+	for _, el := range al.Elements {
+		elements = append(elements, el.String())
+	}
+
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+
+	return out.String()
+}
+
+type IndexExpression struct {
+	Token    token.Token
+	Left     Expression
+	Index    Expression
+	Rbracket token.Token
+}
+
+func (ie *IndexExpression) expressionNode()      {}
+func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpression) Pos() token.Position  { return ie.Left.Pos() }
+func (ie *IndexExpression) End() token.Position  { return ie.Rbracket.End }
+func (ie *IndexExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(ie.Left.String())
+	out.WriteString("[")
+	out.WriteString(ie.Index.String())
+	out.WriteString("])")
+
+	return out.String()
+}
+
+// SliceExpression is xs[Low:High], either bound may be nil.
+type SliceExpression struct {
+	Token    token.Token
+	Left     Expression
+	Low      Expression
+	High     Expression
+	Rbracket token.Token
+}
+
+func (se *SliceExpression) expressionNode()      {}
+func (se *SliceExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SliceExpression) Pos() token.Position  { return se.Left.Pos() }
+func (se *SliceExpression) End() token.Position  { return se.Rbracket.End }
+func (se *SliceExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(se.Left.String())
+	out.WriteString("[")
+	if se.Low != nil {
+		out.WriteString(se.Low.String())
+	}
+	out.WriteString(":")
+	if se.High != nil {
+		out.WriteString(se.High.String())
+	}
+	out.WriteString("])")
+
+	return out.String()
+}
+
+type HashPair struct {
+	Key   Expression
+	Value Expression
+}
+
+// HashLiteral keeps its pairs in source order.
+type HashLiteral struct {
+	Token  token.Token
+	Pairs  []HashPair
+	Rbrace token.Token
+}
+
+func (hl *HashLiteral) expressionNode()      {}
+func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
+func (hl *HashLiteral) Pos() token.Position  { return hl.Token.Pos }
+func (hl *HashLiteral) End() token.Position  { return hl.Rbrace.End }
+func (hl *HashLiteral) String() string {
+	var out bytes.Buffer
+
+	pairs := []string{}
+	for _, pair := range hl.Pairs {
+		pairs = append(pairs, pair.Key.String()+": "+pair.Value.String())
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
@@ -0,0 +1,98 @@
+package ast
+
+import (
+	"strings"
+
+	"github.com/chaitanya-Uike/lemon/token"
+)
+
+// TypeExpression is a type annotation. Annotations are optional and only
+// read by the type checker, the evaluator and the compiler ignore them.
+type TypeExpression interface {
+	Node
+	typeNode()
+}
+
+// NamedType is a type written as a name, like int or string.
+type NamedType struct {
+	Token token.Token
+	Name  string
+}
+
+func (nt *NamedType) typeNode()            {}
+func (nt *NamedType) TokenLiteral() string { return nt.Token.Literal }
+func (nt *NamedType) Pos() token.Position  { return nt.Token.Pos }
+func (nt *NamedType) End() token.Position  { return nt.Token.End }
+func (nt *NamedType) String() string       { return nt.Name }
+
+// ListType is written [Elem].
+type ListType struct {
+	Token    token.Token
+	Elem     TypeExpression
+	Rbracket token.Token
+}
+
+func (lt *ListType) typeNode()            {}
+func (lt *ListType) TokenLiteral() string { return lt.Token.Literal }
+func (lt *ListType) Pos() token.Position  { return lt.Token.Pos }
+func (lt *ListType) End() token.Position  { return lt.Rbracket.End }
+func (lt *ListType) String() string       { return "[" + lt.Elem.String() + "]" }
+
+// MapType is written {Key: Value}.
+type MapType struct {
+	Token  token.Token
+	Key    TypeExpression
+	Value  TypeExpression
+	Rbrace token.Token
+}
+
+func (mt *MapType) typeNode()            {}
+func (mt *MapType) TokenLiteral() string { return mt.Token.Literal }
+func (mt *MapType) Pos() token.Position  { return mt.Token.Pos }
+func (mt *MapType) End() token.Position  { return mt.Rbrace.End }
+func (mt *MapType) String() string {
+	return "{" + mt.Key.String() + ": " + mt.Value.String() + "}"
+}
+
+// FunctionType is written func(Params): Result, Result is nil for a
+// function written without one.
+type FunctionType struct {
+	Token  token.Token
+	Params []TypeExpression
+	Rparen token.Token
+	Result TypeExpression
+}
+
+func (ft *FunctionType) typeNode()            {}
+func (ft *FunctionType) TokenLiteral() string { return ft.Token.Literal }
+func (ft *FunctionType) Pos() token.Position  { return ft.Token.Pos }
+func (ft *FunctionType) End() token.Position {
+	if ft.Result != nil {
+		return ft.Result.End()
+	}
+	return ft.Rparen.End
+}
+func (ft *FunctionType) String() string {
+	params := []string{}
+	for _, p := range ft.Params {
+		params = append(params, p.String())
+	}
+
+	out := ft.TokenLiteral() + "(" + strings.Join(params, ", ") + ")"
+	if ft.Result != nil {
+		out += ": " + ft.Result.String()
+	}
+	return out
+}
+
+// OptionalType is written Elem?, a value of type Elem or null.
+type OptionalType struct {
+	Elem     TypeExpression
+	Question token.Token
+}
+
+func (ot *OptionalType) typeNode()            {}
+func (ot *OptionalType) TokenLiteral() string { return ot.Question.Literal }
+func (ot *OptionalType) Pos() token.Position  { return ot.Elem.Pos() }
+func (ot *OptionalType) End() token.Position  { return ot.Question.End }
+func (ot *OptionalType) String() string       { return ot.Elem.String() + "?" }
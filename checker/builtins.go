@@ -0,0 +1,24 @@
+package checker
+
+// builtins holds the types of the builtin functions. Those taking values
+// of several types, like len, take any.
+var builtins = func() map[string]*scheme {
+	elem, key, value := &Var{}, &Var{}, &Var{}
+
+	return map[string]*scheme{
+		"int":     {t: &Func{Params: []Type{Any}, Result: Int}},
+		"float":   {t: &Func{Params: []Type{Any}, Result: Float}},
+		"decimal": {t: &Func{Params: []Type{Any}, Result: Decimal}},
+		"str":     {t: &Func{Params: []Type{Any}, Result: String}},
+		"len":     {t: &Func{Params: []Type{Any}, Result: Int}},
+		"push": {
+			vars: []*Var{elem},
+			t:    &Func{Params: []Type{&List{Elem: elem}, elem}, Result: &List{Elem: elem}, Variadic: true},
+		},
+		"keys": {
+			vars: []*Var{key, value},
+			t:    &Func{Params: []Type{&Map{Key: key, Value: value}}, Result: &List{Elem: key}},
+		},
+		"puts": {t: &Func{Params: []Type{Any}, Result: Null, Variadic: true}},
+	}
+}()
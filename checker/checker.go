@@ -0,0 +1,640 @@
+// Package checker is an optional pass that infers the types of a program,
+// Hindley-Milner style. It reports the operations that are bound to fail at
+// runtime because of the types of their operands, like true + 1, and the
+// variables and arguments given a value of another type than the one
+// inferred for them.
+//
+// Parameters, return values and declared variables may be annotated with
+// their type, which is then checked instead of inferred.
+//
+// The language stays dynamic: a value whose type can't be inferred, like
+// the element of a list holding both ints and strings, gets the type any,
+// which agrees with every other type.
+package checker
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/chaitanya-Uike/lemon/ast"
+	"github.com/chaitanya-Uike/lemon/diagnostic"
+)
+
+// diagnostic codes reported by the checker
+const (
+	CodeMismatch = "T001"
+	CodeOperator = "T002"
+	CodeCall     = "T003"
+	CodeOperand  = "T004"
+	CodeType     = "T005"
+)
+
+type Result struct {
+	// Types holds the type of every expression, the type variables that
+	// were never bound stay in it.
+	Types       map[ast.Expression]Type
+	Diagnostics []*diagnostic.Diagnostic
+}
+
+// Check infers the types of program. It expects a program the resolver
+// accepts, names it can't find are given the type any.
+func Check(program *ast.Program) *Result {
+	c := &checker{res: &Result{Types: make(map[ast.Expression]Type)}}
+
+	c.openScope()
+	c.statements(program.Statements)
+	c.closeScope()
+	c.checkPending()
+
+	for exp, t := range c.res.Types {
+		c.res.Types[exp] = Resolve(t)
+	}
+	sort.SliceStable(c.res.Diagnostics, func(i, j int) bool {
+		return c.res.Diagnostics[i].Span.Start.Offset < c.res.Diagnostics[j].Span.Start.Offset
+	})
+	return c.res
+}
+
+type symbol struct {
+	scheme   *scheme
+	declared bool
+}
+
+type scope struct {
+	outer   *scope
+	depth   int // the number of functions around the scope
+	symbols map[string]*symbol
+}
+
+// operation is a prefix or infix expression whose operands were not known
+// yet when it was checked, it is checked again once they are.
+type operation struct {
+	exp         ast.Expression
+	operator    string
+	left, right Type // left is nil for a prefix expression
+}
+
+// function is a function being checked.
+type function struct {
+	params   []Type
+	declared Type // the annotated return type, nil without one
+	result   Type // the type returned so far
+}
+
+type checker struct {
+	unifier
+	scope     *scope
+	depth     int
+	functions []*function
+	pending   []operation
+	res       *Result
+}
+
+func (c *checker) openScope() {
+	c.scope = &scope{outer: c.scope, depth: c.depth, symbols: make(map[string]*symbol)}
+}
+
+func (c *checker) closeScope() {
+	c.scope = c.scope.outer
+}
+
+func (c *checker) report(code string, node ast.Node, format string, a ...any) {
+	c.res.Diagnostics = append(c.res.Diagnostics, &diagnostic.Diagnostic{
+		Severity: diagnostic.Error,
+		Code:     code,
+		Span:     diagnostic.Span{Start: node.Pos(), End: node.End()},
+		Message:  fmt.Sprintf(format, a...),
+	})
+}
+
+func (c *checker) record(exp ast.Expression, t Type) Type {
+	c.res.Types[exp] = t
+	return t
+}
+
+func (c *checker) define(decl *ast.IdentifierLiteral, t Type, declared bool) {
+	if _, ok := c.scope.symbols[decl.Value]; ok {
+		return
+	}
+	c.scope.symbols[decl.Value] = &symbol{scheme: &scheme{t: t}, declared: declared}
+	c.record(decl, t)
+}
+
+// declare marks a variable hoisted by define as declared, giving it the
+// type t. A function is generalized over the type variables that only
+// appear in its own type, so each use of it can have different types.
+func (c *checker) declare(decl *ast.IdentifierLiteral, t Type, function bool) {
+	sym, ok := c.scope.symbols[decl.Value]
+	if !ok || sym.declared {
+		return
+	}
+	// uses of the variable in functions declared earlier may already have
+	// fixed its type
+	if v, ok := prune(sym.scheme.t).(*Var); ok {
+		c.bind(v, t)
+	} else {
+		c.unify(sym.scheme.t, t)
+	}
+	sym.declared = true
+	if function {
+		sym.scheme = c.generalize(sym)
+	}
+}
+
+func (c *checker) generalize(sym *symbol) *scheme {
+	t := sym.scheme.t
+	sym.scheme = nil
+	env := c.envVars()
+	sym.scheme = &scheme{t: t}
+
+	vars := make(map[*Var]bool)
+	freeVars(t, vars)
+	for v := range vars {
+		if !env[v] {
+			sym.scheme.vars = append(sym.scheme.vars, v)
+		}
+	}
+	return sym.scheme
+}
+
+// envVars returns the unbound type variables of the variables in scope,
+// which can't be generalized.
+func (c *checker) envVars() map[*Var]bool {
+	env := make(map[*Var]bool)
+	for s := c.scope; s != nil; s = s.outer {
+		for _, sym := range s.symbols {
+			if sym.scheme == nil {
+				continue
+			}
+			vars := make(map[*Var]bool)
+			freeVars(sym.scheme.t, vars)
+			for _, v := range sym.scheme.vars {
+				delete(vars, v)
+			}
+			for v := range vars {
+				env[v] = true
+			}
+		}
+	}
+	return env
+}
+
+// lookup finds a variable the way the resolver does.
+func (c *checker) lookup(name string) (*scheme, bool) {
+	for s := c.scope; s != nil; s = s.outer {
+		sym, ok := s.symbols[name]
+		if !ok || (s.depth == c.depth && !sym.declared) {
+			continue
+		}
+		return sym.scheme, true
+	}
+	s, ok := builtins[name]
+	return s, ok
+}
+
+func (c *checker) identifier(id *ast.IdentifierLiteral) Type {
+	s, ok := c.lookup(id.Value)
+	if !ok {
+		return c.record(id, Any)
+	}
+	return c.record(id, c.instantiate(s))
+}
+
+// statements checks a list of statements in the current scope and returns
+// the type of its value, nil when it always leaves the list through a
+// return, break or continue.
+func (c *checker) statements(stmts []ast.Statement) Type {
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *ast.DeclareStatement:
+			c.define(stmt.Name, c.fresh(), false)
+		case *ast.FunctionStatement:
+			c.define(stmt.Name, c.fresh(), false)
+		}
+	}
+
+	var value Type = Null
+	for _, stmt := range stmts {
+		t := c.statement(stmt)
+		if value != nil {
+			value = t
+		}
+	}
+	return value
+}
+
+func (c *checker) block(block *ast.BlockStatement) Type {
+	c.openScope()
+	defer c.closeScope()
+	return c.statements(block.Statements)
+}
+
+func (c *checker) statement(stmt ast.Statement) Type {
+	switch stmt := stmt.(type) {
+	case *ast.ExpressionStatement:
+		return c.expression(stmt.Expression)
+	case *ast.BlockStatement:
+		return c.block(stmt)
+	case *ast.IfStatement:
+		c.expression(stmt.Condition)
+		consequence := c.block(stmt.Consequence)
+		var alternate Type = Null
+		if stmt.Alternate != nil {
+			alternate = c.statement(stmt.Alternate)
+		}
+		return c.joinResults(consequence, alternate)
+	case *ast.ReturnStatement:
+		var t Type = Null
+		var node ast.Node = stmt
+		if stmt.ReturnValue != nil {
+			t = c.expression(stmt.ReturnValue)
+			node = stmt.ReturnValue
+		}
+		if n := len(c.functions); n > 0 {
+			c.returns(c.functions[n-1], node, t)
+		}
+		return nil
+	case *ast.BranchStatement:
+		return nil
+	case *ast.DeclareStatement:
+		t := c.expression(stmt.Value)
+		if stmt.Type != nil {
+			declared := c.typeOf(stmt.Type)
+			if !c.assign(declared, t) {
+				c.report(CodeMismatch, stmt.Value, "cannot assign %s to %s of type %s", t, stmt.Name.Value, declared)
+			}
+			t = declared
+		}
+		c.declare(stmt.Name, t, isFunction(stmt.Value))
+	case *ast.FunctionStatement:
+		c.declare(stmt.Name, c.function(stmt.Function), true)
+	case *ast.AssignStatement:
+		c.assignment(stmt)
+	case *ast.WhileStatement:
+		c.expression(stmt.Condition)
+		c.block(stmt.Body)
+	case *ast.ForInStatement:
+		// the variable and the body share the scope of an iteration
+		elem := c.iteration(stmt.Iterable)
+		c.openScope()
+		c.define(stmt.Variable, elem, true)
+		c.statements(stmt.Body.Statements)
+		c.closeScope()
+	case *ast.ForStatement:
+		c.openScope()
+		if stmt.Init != nil {
+			c.statements([]ast.Statement{stmt.Init})
+		}
+		if stmt.Condition != nil {
+			c.expression(stmt.Condition)
+		}
+		if stmt.Post != nil {
+			c.statement(stmt.Post)
+		}
+		c.block(stmt.Body)
+		c.closeScope()
+	case *ast.LabeledStatement:
+		return c.statement(stmt.Statement)
+	}
+	return Null
+}
+
+func isFunction(exp ast.Expression) bool {
+	_, ok := exp.(*ast.FunctionLiteral)
+	return ok
+}
+
+func (c *checker) assignment(as *ast.AssignStatement) {
+	value := c.expression(as.Value)
+
+	switch target := as.Target.(type) {
+	case *ast.IdentifierLiteral:
+		t := c.identifier(target)
+		if !c.assign(t, value) {
+			c.report(CodeMismatch, as.Value, "cannot assign %s to %s of type %s", value, target.Value, t)
+		}
+	case *ast.IndexExpression:
+		left := c.expression(target.Left)
+		index := c.expression(target.Index)
+
+		switch l := prune(left).(type) {
+		case *List:
+			if !c.unify(index, Int) {
+				c.report(CodeOperand, target, "index operator not supported: %s[%s]", l, index)
+			} else if !c.assign(l.Elem, value) {
+				c.report(CodeMismatch, as.Value, "cannot assign %s to an element of %s", value, l)
+			}
+		case *Map:
+			c.mapKey(target.Index, l, index)
+			if !c.assign(l.Value, value) {
+				c.report(CodeMismatch, as.Value, "cannot assign %s to a value of %s", value, l)
+			}
+		case *Basic:
+			if l != Any {
+				c.report(CodeOperand, target, "index assignment not supported: %s", l)
+			}
+		case *Func, *Optional:
+			c.report(CodeOperand, target, "index assignment not supported: %s", l)
+		}
+		c.record(target, value)
+	default:
+		c.expression(as.Target)
+	}
+}
+
+// iteration returns the type of the items of a for-in loop over exp.
+func (c *checker) iteration(exp ast.Expression) Type {
+	switch t := prune(c.expression(exp)).(type) {
+	case *List:
+		return t.Elem
+	case *Map:
+		return t.Key
+	case *Basic:
+		if t == String || t == Any {
+			return t
+		}
+		c.report(CodeOperand, exp, "cannot iterate over %s", t)
+	case *Func, *Optional:
+		c.report(CodeOperand, exp, "cannot iterate over %s", t)
+	}
+	return Any
+}
+
+// function infers the type of a function literal, its parameters and its
+// body share a scope. The result is what the body returns or, when it
+// reaches its end, the value of its last statement.
+func (c *checker) function(fl *ast.FunctionLiteral) Type {
+	c.depth++
+	c.openScope()
+
+	params := make([]Type, len(fl.Parameters))
+	for i, param := range fl.Parameters {
+		if i < len(fl.ParameterTypes) && fl.ParameterTypes[i] != nil {
+			params[i] = c.typeOf(fl.ParameterTypes[i])
+		} else {
+			params[i] = c.fresh()
+		}
+		c.define(param, params[i], true)
+	}
+
+	f := &function{params: params}
+	if fl.ReturnType != nil {
+		f.declared = c.typeOf(fl.ReturnType)
+	}
+
+	c.functions = append(c.functions, f)
+	if value := c.statements(fl.Body.Statements); value != nil {
+		var node ast.Node = fl.Body
+		if n := len(fl.Body.Statements); n > 0 {
+			node = fl.Body.Statements[n-1]
+		}
+		c.returns(f, node, value)
+	}
+	c.functions = c.functions[:len(c.functions)-1]
+
+	result := f.declared
+	if result == nil {
+		result = f.result
+	}
+	if result == nil {
+		result = Null
+	}
+
+	c.closeScope()
+	c.depth--
+	return c.record(fl, &Func{Params: params, Result: result})
+}
+
+// returns adds t to the types returned by f, node is the value returned.
+func (c *checker) returns(f *function, node ast.Node, t Type) {
+	if f.declared != nil && !c.assign(f.declared, t) {
+		c.report(CodeMismatch, node, "cannot return %s from a function returning %s", t, f.declared)
+	}
+	f.result = c.joinResults(f.result, t)
+}
+
+// joinResults joins the types of two values a function may return. That one
+// of them is a string says nothing about the parameters of the functions
+// being checked, so their type variables are left unbound.
+func (c *checker) joinResults(a, b Type) Type {
+	var params []Type
+	for _, f := range c.functions {
+		params = append(params, f.params...)
+	}
+	return c.joinFixed(a, b, params)
+}
+
+var namedTypes = map[string]Type{
+	"int":     Int,
+	"float":   Float,
+	"decimal": Decimal,
+	"bool":    Bool,
+	"string":  String,
+	"null":    Null,
+	"any":     Any,
+}
+
+// typeOf returns the type an annotation stands for.
+func (c *checker) typeOf(te ast.TypeExpression) Type {
+	switch te := te.(type) {
+	case *ast.NamedType:
+		if t, ok := namedTypes[te.Name]; ok {
+			return t
+		}
+		c.report(CodeType, te, "unknown type: %s", te.Name)
+	case *ast.ListType:
+		return &List{Elem: c.typeOf(te.Elem)}
+	case *ast.MapType:
+		return &Map{Key: c.typeOf(te.Key), Value: c.typeOf(te.Value)}
+	case *ast.FunctionType:
+		params := make([]Type, len(te.Params))
+		for i, p := range te.Params {
+			params[i] = c.typeOf(p)
+		}
+		var result Type = Null
+		if te.Result != nil {
+			result = c.typeOf(te.Result)
+		}
+		return &Func{Params: params, Result: result}
+	case *ast.OptionalType:
+		return &Optional{Elem: c.typeOf(te.Elem)}
+	}
+	return Any
+}
+
+func (c *checker) expression(exp ast.Expression) Type {
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral:
+		return c.record(exp, Int)
+	case *ast.FloatLiteral:
+		return c.record(exp, Float)
+	case *ast.DecimalLiteral:
+		return c.record(exp, Decimal)
+	case *ast.BooleanLiteral:
+		return c.record(exp, Bool)
+	case *ast.StringLiteral:
+		return c.record(exp, String)
+	case *ast.InterpolatedString:
+		for _, part := range exp.Parts {
+			c.expression(part)
+		}
+		return c.record(exp, String)
+	case *ast.IdentifierLiteral:
+		return c.identifier(exp)
+	case *ast.PrefixExpression:
+		return c.record(exp, c.prefix(exp))
+	case *ast.InfixExpression:
+		return c.record(exp, c.infix(exp))
+	case *ast.FunctionLiteral:
+		return c.function(exp)
+	case *ast.CallExpression:
+		return c.record(exp, c.call(exp))
+	case *ast.ArrayLiteral:
+		var elem Type
+		for _, element := range exp.Elements {
+			elem = c.join(elem, c.expression(element))
+		}
+		if elem == nil {
+			elem = c.fresh()
+		}
+		return c.record(exp, &List{Elem: elem})
+	case *ast.HashLiteral:
+		var key, value Type
+		for _, pair := range exp.Pairs {
+			k := c.expression(pair.Key)
+			c.hashKey(pair.Key, k)
+			key = c.join(key, k)
+			value = c.join(value, c.expression(pair.Value))
+		}
+		if key == nil {
+			key, value = c.fresh(), c.fresh()
+		}
+		return c.record(exp, &Map{Key: key, Value: value})
+	case *ast.IndexExpression:
+		return c.record(exp, c.index(exp))
+	case *ast.SliceExpression:
+		return c.record(exp, c.slice(exp))
+	}
+	return Any
+}
+
+// hashKey reports a key of a type the hash can't hold.
+func (c *checker) hashKey(exp ast.Expression, t Type) bool {
+	switch t := prune(t).(type) {
+	case *List, *Map, *Func:
+		c.report(CodeOperand, exp, "unusable as hash key: %s", t)
+		return false
+	case *Basic:
+		if t == Null {
+			c.report(CodeOperand, exp, "unusable as hash key: %s", t)
+			return false
+		}
+	}
+	return true
+}
+
+// mapKey checks the key exp of type t used to index m. Numbers of different
+// types are the same key when they are equal, like 1 and 1.0.
+func (c *checker) mapKey(exp ast.Expression, m *Map, t Type) {
+	if !c.hashKey(exp, t) || c.unify(m.Key, t) {
+		return
+	}
+	if isNumeric(prune(m.Key)) && isNumeric(prune(t)) {
+		return
+	}
+	c.report(CodeMismatch, exp, "cannot use %s as a key of %s", t, m)
+}
+
+func (c *checker) call(ce *ast.CallExpression) Type {
+	function := c.expression(ce.Function)
+	args := make([]Type, len(ce.Arguments))
+	for i, arg := range ce.Arguments {
+		args[i] = c.expression(arg)
+	}
+
+	switch f := prune(function).(type) {
+	case *Func:
+		return c.apply(ce, f, args)
+	case *Var:
+		result := c.fresh()
+		c.unify(f, &Func{Params: args, Result: result})
+		return result
+	case *Basic:
+		if f == Any {
+			return Any
+		}
+	}
+	c.report(CodeCall, ce.Function, "not a function: %s", function)
+	return Any
+}
+
+// apply checks the arguments of a call to a function of type f.
+func (c *checker) apply(ce *ast.CallExpression, f *Func, args []Type) Type {
+	switch want := len(f.Params); {
+	case f.Variadic && len(args) < want-1:
+		c.report(CodeCall, ce, "wrong number of arguments. got=%d, want at least %d", len(args), want-1)
+	case !f.Variadic && len(args) != want:
+		c.report(CodeCall, ce, "wrong number of arguments. got=%d, want=%d", len(args), want)
+	}
+
+	for i, arg := range args {
+		if i >= len(f.Params) && !f.Variadic {
+			break
+		}
+		param := f.Params[min(i, len(f.Params)-1)]
+		if !c.assign(param, arg) {
+			c.report(CodeMismatch, ce.Arguments[i], "cannot use %s as %s in argument %d", arg, param, i+1)
+		}
+	}
+	return f.Result
+}
+
+func (c *checker) index(ie *ast.IndexExpression) Type {
+	left := c.expression(ie.Left)
+	index := c.expression(ie.Index)
+
+	switch l := prune(left).(type) {
+	case *List:
+		if c.unify(index, Int) {
+			return l.Elem
+		}
+	case *Map:
+		c.mapKey(ie.Index, l, index)
+		return l.Value
+	case *Basic:
+		if l == Any {
+			return Any
+		}
+		if l == String && c.unify(index, Int) {
+			return String
+		}
+	case *Var:
+		// a list, a string or a hash
+		return Any
+	}
+	c.report(CodeOperand, ie, "index operator not supported: %s[%s]", left, index)
+	return Any
+}
+
+func (c *checker) slice(se *ast.SliceExpression) Type {
+	left := c.expression(se.Left)
+	for _, bound := range []ast.Expression{se.Low, se.High} {
+		if bound == nil {
+			continue
+		}
+		if t := c.expression(bound); !c.unify(t, Int) {
+			c.report(CodeOperand, bound, "slice index must be int, got %s", t)
+		}
+	}
+
+	switch l := prune(left).(type) {
+	case *List, *Var:
+		return left
+	case *Basic:
+		if l == String || l == Any {
+			return l
+		}
+	}
+	c.report(CodeOperand, se, "slice operator not supported: %s", left)
+	return Any
+}
@@ -0,0 +1,224 @@
+package checker
+
+import (
+	"testing"
+
+	"github.com/chaitanya-Uike/lemon/ast"
+	"github.com/chaitanya-Uike/lemon/lexer"
+	"github.com/chaitanya-Uike/lemon/parser"
+)
+
+func testCheck(t *testing.T, input string) (*ast.Program, *Result) {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		for _, msg := range p.Errors() {
+			t.Errorf("parser error: %q", msg)
+		}
+		t.FailNow()
+	}
+
+	return program, Check(program)
+}
+
+// lastExpression returns the expression of the last statement of program.
+func lastExpression(t *testing.T, program *ast.Program) ast.Expression {
+	t.Helper()
+
+	stmt, ok := program.Statements[len(program.Statements)-1].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("last statement is not *ast.ExpressionStatement, got %T", program.Statements[len(program.Statements)-1])
+	}
+	return stmt.Expression
+}
+
+func TestInferredTypes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1", "int"},
+		{"9223372036854775808", "int"},
+		{"1.5 * 2", "float"},
+		{"1 + 0.5d", "decimal"},
+		{`"a" + "b"`, "string"},
+		{`"${1}"`, "string"},
+		{"!5", "bool"},
+		{"-2.5", "float"},
+		{"~1", "int"},
+		{"1 < 2 && 0", "bool"},
+		{"[1, 2]", "[int]"},
+		{`[1, "a"]`, "[any]"},
+		{"[]", "['a]"},
+		{`({"a": [1]})`, "{string: [int]}"},
+		{"[1][0]", "int"},
+		{`"abc"[1:]`, "string"},
+		{"func(x) { x + 1 }", "func(int): int"},
+		{"func(a, b) { a + b }", "func('a, 'a): 'a"},
+		{"func(f, x) { f(f(x)) }", "func(func('a): 'a, 'a): 'a"},
+		{"func(xs, x) { push(xs, x) }", "func(['a], 'a): ['a]"},
+		{"func(m) { keys(m) }", "func({'a: 'b}): ['a]"},
+		{"func(x) { puts(x) }", "func('a): null"},
+		{"func id(x) { x }\nid", "func('a): 'a"},
+		{"func id(x) { x }\nid(1)", "int"},
+		{"func id(x) { x }\nid(1); id(\"a\")", "string"},
+		{"func f(c) { if c { 1 } else { 2 } }\nf", "func('a): int"},
+		{`func f(c) { if c { 1 } else { "a" } }` + "\nf", "func('a): any"},
+		{"func f(c) { if c { 1 } }\nf", "func('a): any"},
+		{"func f(c) { if c { return 1 }\nreturn 2 }\nf", "func('a): int"},
+		{"func f(n) { if n == 0 { return \"zero\" }\nn }\nf", "func('a): any"},
+		{"func f(n) { if n < 2 { return n }\nreturn f(n - 1) + f(n - 2) }\nf", "func(int): int"},
+		{"func isEven(n) { if n == 0 { return true }\nisOdd(n - 1) }\nfunc isOdd(n) { if n == 0 { return false }\nisEven(n - 1) }\nisEven", "func(int): bool"},
+		{"func counter() { count := 0; return func() { count = count + 1; count } }\ncounter", "func(): func(): int"},
+		{"xs := []; xs = push(xs, true); xs", "[bool]"},
+		{"func add(a: int, b: int): int { a + b }\nadd", "func(int, int): int"},
+		{"func(x: float) { x }", "func(float): float"},
+		{"func(f: func(int): bool, x) { f(x) }", "func(func(int): bool, int): bool"},
+		{"func(): [string]? { [] }", "func(): [string]?"},
+		{"xs: [int] := []; xs", "[int]"},
+		{"m: {string: any} := {}; m", "{string: any}"},
+		{"x: int? := 1; x", "int?"},
+		{"x: any := 1; x = \"a\"; x", "any"},
+	}
+
+	for _, tt := range tests {
+		program, res := testCheck(t, tt.input)
+		if len(res.Diagnostics) != 0 {
+			t.Errorf("%s - unexpected diagnostics %v", tt.input, res.Diagnostics)
+			continue
+		}
+
+		typ, ok := res.Types[lastExpression(t, program)]
+		if !ok {
+			t.Errorf("%s - no type inferred", tt.input)
+			continue
+		}
+		if typ.String() != tt.expected {
+			t.Errorf("%s - expected %s, got %s", tt.input, tt.expected, typ)
+		}
+	}
+}
+
+func TestValidPrograms(t *testing.T) {
+	inputs := []string{
+		"x := 1; x = x + len([1])",
+		"1 == 1.0; 1 < 2.5; 1d == 1",
+		`[1] + [2]; ({"a": 1}) == {"a": 1}; [1] != [2]`,
+		"func(x) { -x }(1)",
+		"func(x) { x == 1 }(2)",
+		`xs := [1, "a"]; xs[0] + 1; xs[1] + "b"`,
+		"m := {}; m[1] = 2; m[3]",
+		`m := {1: "int"}; m[1.0] + "!"; m[2.5] = "float"`,
+		"for c in \"abc\" { puts(c + \"!\") }",
+		"m := {1: 2}; for k in m { k + 1 }",
+		"for i := 0; i < 3; i = i + 1 { puts(i) }",
+		"func apply(f, x) { f(x) }\napply(func(n) { n + 1 }, 1); apply(len, \"ab\")",
+		`puts(); puts(1, "a"); push([1], 2, 3)`,
+		"func f() { g() }\nfunc g() { 1 }\nf() + 1",
+		"func f(n) { return n; \"unreachable\" }\nf(1) + 1",
+		"if 0 { 1 } else { \"a\" }",
+		"int(\"1\") + 1; str(1) + \"a\"; float(1) * 2.5; decimal(\"0.1\") + 1",
+		"func f(x: int?) { x }\nf(1); x: int? := 2; f(x); y: any := x",
+		"func f(n) { if n == 0 { return \"zero\" }\nn }\nf(1)",
+		"func f(n) { if n { \"a\" } else { n } }\nf(true)",
+		"func f(n) { g := func() { if n { return 1 }\nn }\ng }\nf(true)",
+	}
+
+	for _, input := range inputs {
+		_, res := testCheck(t, input)
+		for _, d := range res.Diagnostics {
+			t.Errorf("%s - unexpected diagnostic %s", input, d.Error())
+		}
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	tests := []struct {
+		input    string
+		code     string
+		expected string
+	}{
+		{"true + 1", CodeMismatch, "1:1: type mismatch: bool + int"},
+		{"-true", CodeOperator, "1:1: unknown operator: -bool"},
+		{"~1.5", CodeOperator, "1:1: unknown operator: ~float"},
+		{"true + false", CodeOperator, "1:1: unknown operator: bool + bool"},
+		{`"a" < "b"`, CodeOperator, "1:1: unknown operator: string < string"},
+		{"1.5 | 1", CodeOperator, "1:1: unknown operator: float | int"},
+		{"1.5 == 1.5d", CodeMismatch, "1:1: type mismatch: float == decimal"},
+		{`x := 1; x == "a"`, CodeMismatch, "1:9: type mismatch: int == string"},
+		{"[1] - [2]", CodeOperator, "1:1: unknown operator: [int] - [int]"},
+		{"func f(x) { x * 2 }\nf(true)", CodeMismatch, "2:3: cannot use bool as int in argument 1"},
+		{"func f(x) { if x { 1 } else { 2 } }\nf(1) + \"a\"", CodeMismatch, "2:1: type mismatch: int + string"},
+		{"xs := []\nn := -xs[0]\nxs = push(xs, true)", CodeOperator, "2:6: unknown operator: -bool"},
+		{"func(x) { x == 1 }(true)", CodeMismatch, "1:11: type mismatch: bool == int"},
+		{"func f(x) { x + 1 }\n1 + f(2) + \"!\"", CodeMismatch, "2:1: type mismatch: int + string"},
+		{`x := 1; x = "a"`, CodeMismatch, "1:13: cannot assign string to x of type int"},
+		{`xs := [1]; xs[0] = "a"`, CodeMismatch, "1:20: cannot assign string to an element of [int]"},
+		{`m := {"a": 1}; m["b"] = true`, CodeMismatch, "1:25: cannot assign bool to a value of {string: int}"},
+		{`m := {"a": 1}; m[1] = 2`, CodeMismatch, "1:18: cannot use int as a key of {string: int}"},
+		{`m := {"a": 1}; m[true]`, CodeMismatch, "1:18: cannot use bool as a key of {string: int}"},
+		{"1(2)", CodeCall, "1:1: not a function: int"},
+		{"func(x) { x }(1, 2)", CodeCall, "1:1: wrong number of arguments. got=2, want=1"},
+		{"push()", CodeCall, "1:1: wrong number of arguments. got=0, want at least 1"},
+		{"keys([1])", CodeMismatch, "1:6: cannot use [int] as {'a: 'b} in argument 1"},
+		{"for x in 5 {}", CodeOperand, "1:10: cannot iterate over int"},
+		{"5[0]", CodeOperand, "1:1: index operator not supported: int[int]"},
+		{`[1]["a"]`, CodeOperand, "1:1: index operator not supported: [int][string]"},
+		{"true[1:]", CodeOperand, "1:1: slice operator not supported: bool"},
+		{"[1][true:]", CodeOperand, "1:5: slice index must be int, got bool"},
+		{"({[1]: 2})", CodeOperand, "1:3: unusable as hash key: [int]"},
+		{"1; 2[0] = 1", CodeOperand, "1:4: index assignment not supported: int"},
+		{"func add(a: int, b: int): int { a + b }\nadd(1, \"2\")", CodeMismatch, "2:8: cannot use string as int in argument 2"},
+		{"func(x: [string]) { x[0] + 1 }", CodeMismatch, "1:21: type mismatch: string + int"},
+		{`func f(): int { "a" }`, CodeMismatch, "1:17: cannot return string from a function returning int"},
+		{"func f(): int { return true }", CodeMismatch, "1:24: cannot return bool from a function returning int"},
+		{"func f(x): string { if x { return \"a\" }\n}", CodeMismatch, "1:21: cannot return null from a function returning string"},
+		{"x: float := 1", CodeMismatch, "1:13: cannot assign int to x of type float"},
+		{"f: func(int): int := func(s) { s + \"!\" }", CodeMismatch, "1:22: cannot assign func(string): string to f of type func(int): int"},
+		{"x: int? := 1; x + 1", CodeMismatch, "1:15: type mismatch: int? + int"},
+		{"func f(): int? { 1 }\nn: int := f()", CodeMismatch, "2:11: cannot assign int? to n of type int"},
+		{"func f(x: int) { x }\ny: int? := 1; f(y)", CodeMismatch, "2:17: cannot use int? as int in argument 1"},
+		{"func f(x: int?): int { x }", CodeMismatch, "1:24: cannot return int? from a function returning int"},
+		{"xs := [1]; x: int? := 2; xs[0] = x", CodeMismatch, "1:34: cannot assign int? to an element of [int]"},
+		{"x: number := 1", CodeType, "1:4: unknown type: number"},
+	}
+
+	for _, tt := range tests {
+		_, res := testCheck(t, tt.input)
+		if len(res.Diagnostics) != 1 {
+			t.Errorf("%s - expected 1 diagnostic, got %d: %v", tt.input, len(res.Diagnostics), res.Diagnostics)
+			continue
+		}
+
+		d := res.Diagnostics[0]
+		if d.Code != tt.code {
+			t.Errorf("%s - expected code %s, got %s", tt.input, tt.code, d.Code)
+		}
+		if d.Error() != tt.expected {
+			t.Errorf("%s - expected %q, got %q", tt.input, tt.expected, d.Error())
+		}
+	}
+}
+
+func TestDiagnosticSpans(t *testing.T) {
+	_, res := testCheck(t, "x := 1\ny := (true +\n  x) * 2\n-\"a\"")
+	if len(res.Diagnostics) != 2 {
+		t.Fatalf("Expected 2 diagnostics, got %d: %v", len(res.Diagnostics), res.Diagnostics)
+	}
+
+	tests := []struct {
+		start, end string
+	}{
+		{"2:7", "3:4"},
+		{"4:1", "4:5"},
+	}
+
+	for i, tt := range tests {
+		span := res.Diagnostics[i].Span
+		if span.Start.String() != tt.start || span.End.String() != tt.end {
+			t.Errorf("diagnostic %d - expected span %s-%s, got %s-%s", i, tt.start, tt.end, span.Start, span.End)
+		}
+	}
+}
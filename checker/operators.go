@@ -0,0 +1,197 @@
+package checker
+
+import (
+	"fmt"
+
+	"github.com/chaitanya-Uike/lemon/ast"
+)
+
+// The operators are typed after the evaluator's rules, with the messages
+// of the errors they give at runtime.
+
+// operatorError is an operation that fails at runtime.
+type operatorError struct {
+	code    string
+	message string
+}
+
+func mismatch(left Type, operator string, right Type) *operatorError {
+	return &operatorError{CodeMismatch, fmt.Sprintf("type mismatch: %s %s %s", left, operator, right)}
+}
+
+func unknownInfix(left Type, operator string, right Type) *operatorError {
+	return &operatorError{CodeOperator, fmt.Sprintf("unknown operator: %s %s %s", left, operator, right)}
+}
+
+func unknownPrefix(operator string, right Type) *operatorError {
+	return &operatorError{CodeOperator, fmt.Sprintf("unknown operator: %s%s", operator, right)}
+}
+
+func isBitwise(operator string) bool {
+	switch operator {
+	case "&", "|", "^", "<<", ">>":
+		return true
+	}
+	return false
+}
+
+func isComparison(operator string) bool {
+	switch operator {
+	case "<", ">", "<=", ">=", "==", "!=":
+		return true
+	}
+	return false
+}
+
+func isUnbound(t Type) bool {
+	_, ok := prune(t).(*Var)
+	return ok
+}
+
+func (c *checker) prefix(pe *ast.PrefixExpression) Type {
+	right := c.expression(pe.Expression)
+	if pe.Operator == "!" {
+		return Bool
+	}
+
+	if isUnbound(right) {
+		c.pending = append(c.pending, operation{exp: pe, operator: pe.Operator, right: right})
+		if pe.Operator == "~" {
+			c.unify(right, Int)
+			return Int
+		}
+		return right
+	}
+
+	t, err := prefixType(pe.Operator, prune(right))
+	if err != nil {
+		c.report(err.code, pe, "%s", err.message)
+		return Any
+	}
+	return t
+}
+
+func prefixType(operator string, right Type) (Type, *operatorError) {
+	if right == Any {
+		return Any, nil
+	}
+	switch {
+	case operator == "-" && isNumeric(right):
+		return right, nil
+	case operator == "~" && right == Int:
+		return Int, nil
+	}
+	return nil, unknownPrefix(operator, right)
+}
+
+func (c *checker) infix(ie *ast.InfixExpression) Type {
+	left := c.expression(ie.Left)
+	right := c.expression(ie.Right)
+	if ie.Operator == "&&" || ie.Operator == "||" {
+		return Bool
+	}
+
+	if isUnbound(left) || isUnbound(right) {
+		// guess the operands from the operator, the types inferred for
+		// them later are checked at the end
+		c.pending = append(c.pending, operation{exp: ie, operator: ie.Operator, left: left, right: right})
+		switch {
+		case isBitwise(ie.Operator):
+			c.unify(left, Int)
+			c.unify(right, Int)
+			return Int
+		case ie.Operator == "==" || ie.Operator == "!=":
+			return Bool
+		case isComparison(ie.Operator):
+			c.unify(left, right)
+			return Bool
+		case c.unify(left, right):
+			return left
+		}
+		return Any
+	}
+
+	t, err := c.infixType(ie.Operator, prune(left), prune(right))
+	if err != nil {
+		c.report(err.code, ie, "%s", err.message)
+		return Any
+	}
+	return t
+}
+
+func (c *checker) infixType(operator string, left, right Type) (Type, *operatorError) {
+	if left == Any || right == Any {
+		switch {
+		case isComparison(operator):
+			return Bool, nil
+		case isBitwise(operator):
+			return Int, nil
+		}
+		return Any, nil
+	}
+
+	switch {
+	case isNumeric(left) && isNumeric(right):
+		if isBitwise(operator) {
+			if left == Int && right == Int {
+				return Int, nil
+			}
+			return nil, unknownInfix(left, operator, right)
+		}
+		if (left == Float && right == Decimal) || (left == Decimal && right == Float) {
+			return nil, mismatch(left, operator, right)
+		}
+		if isComparison(operator) {
+			return Bool, nil
+		}
+		switch {
+		case left == Decimal || right == Decimal:
+			return Decimal, nil
+		case left == Float || right == Float:
+			return Float, nil
+		}
+		return Int, nil
+	case left == String && right == String:
+		switch operator {
+		case "+":
+			return String, nil
+		case "==", "!=":
+			return Bool, nil
+		}
+	case !sameKind(left, right):
+		return nil, mismatch(left, operator, right)
+	case operator == "==" || operator == "!=":
+		return Bool, nil
+	case operator == "+":
+		if l, ok := left.(*List); ok {
+			return &List{Elem: c.join(l.Elem, right.(*List).Elem)}, nil
+		}
+	}
+	return nil, unknownInfix(left, operator, right)
+}
+
+// checkPending checks the operations whose operands were inferred after
+// them. Operands still unbound can be of any type, as in a function that
+// adds its parameters.
+func (c *checker) checkPending() {
+	for _, op := range c.pending {
+		right := prune(op.right)
+		if isUnbound(right) {
+			continue
+		}
+
+		var err *operatorError
+		if op.left == nil {
+			_, err = prefixType(op.operator, right)
+		} else {
+			left := prune(op.left)
+			if isUnbound(left) {
+				continue
+			}
+			_, err = c.infixType(op.operator, left, right)
+		}
+		if err != nil {
+			c.report(err.code, op.exp, "%s", err.message)
+		}
+	}
+}
@@ -0,0 +1,398 @@
+package checker
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Type is the static type of an expression.
+type Type interface {
+	String() string
+}
+
+// Basic is a type without parameters, the basic types are singletons.
+type Basic struct {
+	Name string
+}
+
+func (b *Basic) String() string { return format(b) }
+
+var (
+	Int     = &Basic{Name: "int"}
+	Float   = &Basic{Name: "float"}
+	Decimal = &Basic{Name: "decimal"}
+	Bool    = &Basic{Name: "bool"}
+	String  = &Basic{Name: "string"}
+	Null    = &Basic{Name: "null"}
+	// Any is the type of values only known at runtime, it agrees with
+	// every type.
+	Any = &Basic{Name: "any"}
+)
+
+type List struct {
+	Elem Type
+}
+
+func (l *List) String() string { return format(l) }
+
+type Map struct {
+	Key   Type
+	Value Type
+}
+
+func (m *Map) String() string { return format(m) }
+
+// Optional is the type of values of type Elem or null.
+type Optional struct {
+	Elem Type
+}
+
+func (o *Optional) String() string { return format(o) }
+
+// Func is the type of a function, a variadic function takes any number of
+// arguments of the type of its last parameter.
+type Func struct {
+	Params   []Type
+	Result   Type
+	Variadic bool
+}
+
+func (f *Func) String() string { return format(f) }
+
+// Var is a type still to be inferred, it stands for the type it is bound to.
+type Var struct {
+	ref Type
+}
+
+func (v *Var) String() string { return format(v) }
+
+// format writes t the way annotations are written, type variables are
+// named 'a, 'b and so on in the order they appear.
+func format(t Type) string {
+	names := make(map[*Var]string)
+
+	var write func(t Type) string
+	write = func(t Type) string {
+		switch t := prune(t).(type) {
+		case *Basic:
+			return t.Name
+		case *List:
+			return "[" + write(t.Elem) + "]"
+		case *Map:
+			return "{" + write(t.Key) + ": " + write(t.Value) + "}"
+		case *Func:
+			params := make([]string, len(t.Params))
+			for i, p := range t.Params {
+				params[i] = write(p)
+			}
+			if t.Variadic {
+				params[len(params)-1] = "..." + params[len(params)-1]
+			}
+			return "func(" + strings.Join(params, ", ") + "): " + write(t.Result)
+		case *Optional:
+			return write(t.Elem) + "?"
+		case *Var:
+			name, ok := names[t]
+			if !ok {
+				if n := len(names); n < 26 {
+					name = "'" + string(rune('a'+n))
+				} else {
+					name = fmt.Sprintf("'t%d", n)
+				}
+				names[t] = name
+			}
+			return name
+		}
+		return "?"
+	}
+	return write(t)
+}
+
+// prune follows bound type variables down to the type they stand for.
+func prune(t Type) Type {
+	for {
+		v, ok := t.(*Var)
+		if !ok || v.ref == nil {
+			return t
+		}
+		t = v.ref
+	}
+}
+
+// Resolve returns t with every bound type variable replaced, at any depth.
+func Resolve(t Type) Type {
+	switch t := prune(t).(type) {
+	case *List:
+		return &List{Elem: Resolve(t.Elem)}
+	case *Map:
+		return &Map{Key: Resolve(t.Key), Value: Resolve(t.Value)}
+	case *Func:
+		params := make([]Type, len(t.Params))
+		for i, p := range t.Params {
+			params[i] = Resolve(p)
+		}
+		return &Func{Params: params, Result: Resolve(t.Result), Variadic: t.Variadic}
+	case *Optional:
+		return &Optional{Elem: Resolve(t.Elem)}
+	default:
+		return t
+	}
+}
+
+func isNumeric(t Type) bool {
+	return t == Int || t == Float || t == Decimal
+}
+
+// sameKind reports whether two resolved types are values of the same kind
+// at runtime, whatever their parameters.
+func sameKind(a, b Type) bool {
+	switch a.(type) {
+	case *Basic:
+		return a == b
+	case *List:
+		_, ok := b.(*List)
+		return ok
+	case *Map:
+		_, ok := b.(*Map)
+		return ok
+	case *Func:
+		_, ok := b.(*Func)
+		return ok
+	case *Optional:
+		_, ok := b.(*Optional)
+		return ok
+	}
+	return false
+}
+
+// scheme is a type generalized over some of its variables, every use of
+// the variable it is bound to gets fresh ones.
+type scheme struct {
+	vars []*Var
+	t    Type
+}
+
+// unifier binds type variables, recording them so a failed unification can
+// be undone.
+type unifier struct {
+	trail []*Var
+}
+
+func (u *unifier) fresh() *Var {
+	return &Var{}
+}
+
+// unify makes a and b the same type by binding type variables, it leaves
+// the variables untouched when that is not possible.
+func (u *unifier) unify(a, b Type) bool {
+	mark := len(u.trail)
+	if u.unifyTypes(a, b) {
+		return true
+	}
+	u.undo(mark)
+	return false
+}
+
+// undo unbinds the variables bound since the trail was mark long.
+func (u *unifier) undo(mark int) {
+	for _, v := range u.trail[mark:] {
+		v.ref = nil
+	}
+	u.trail = u.trail[:mark]
+}
+
+func (u *unifier) unifyTypes(a, b Type) bool {
+	a, b = prune(a), prune(b)
+	if a == b || a == Any || b == Any {
+		return true
+	}
+	if v, ok := a.(*Var); ok {
+		return u.bind(v, b)
+	}
+	if v, ok := b.(*Var); ok {
+		return u.bind(v, a)
+	}
+	if o, ok := a.(*Optional); ok {
+		return u.unifyOptional(o, b)
+	}
+	if o, ok := b.(*Optional); ok {
+		return u.unifyOptional(o, a)
+	}
+
+	switch a := a.(type) {
+	case *List:
+		b, ok := b.(*List)
+		return ok && u.unifyTypes(a.Elem, b.Elem)
+	case *Map:
+		b, ok := b.(*Map)
+		return ok && u.unifyTypes(a.Key, b.Key) && u.unifyTypes(a.Value, b.Value)
+	case *Func:
+		b, ok := b.(*Func)
+		if !ok || len(a.Params) != len(b.Params) || a.Variadic != b.Variadic {
+			return false
+		}
+		for i := range a.Params {
+			if !u.unifyTypes(a.Params[i], b.Params[i]) {
+				return false
+			}
+		}
+		return u.unifyTypes(a.Result, b.Result)
+	}
+	return false
+}
+
+// assign unifies the type a value is used as with the type of the value.
+// Where an optional is expected a value of its element type or null will do,
+// but an optional value can't be used where its element type is expected.
+func (u *unifier) assign(to, from Type) bool {
+	if _, ok := prune(from).(*Optional); ok {
+		switch to := prune(to).(type) {
+		case *Optional, *Var:
+		default:
+			if to != Any {
+				return false
+			}
+		}
+	}
+	return u.unify(to, from)
+}
+
+// unifyOptional unifies an optional type with t, which agrees with it when
+// it is null or the type of its values.
+func (u *unifier) unifyOptional(o *Optional, t Type) bool {
+	switch t := t.(type) {
+	case *Optional:
+		return u.unifyTypes(o.Elem, t.Elem)
+	case *Basic:
+		if t == Null {
+			return true
+		}
+	}
+	return u.unifyTypes(o.Elem, t)
+}
+
+func (u *unifier) bind(v *Var, t Type) bool {
+	if occurs(v, t) {
+		return false
+	}
+	v.ref = t
+	u.trail = append(u.trail, v)
+	return true
+}
+
+func occurs(v *Var, t Type) bool {
+	switch t := prune(t).(type) {
+	case *Var:
+		return t == v
+	case *List:
+		return occurs(v, t.Elem)
+	case *Map:
+		return occurs(v, t.Key) || occurs(v, t.Value)
+	case *Func:
+		for _, p := range t.Params {
+			if occurs(v, p) {
+				return true
+			}
+		}
+		return occurs(v, t.Result)
+	case *Optional:
+		return occurs(v, t.Elem)
+	}
+	return false
+}
+
+// join returns the type that a and b agree on, or Any when they don't, the
+// way a value coming from either of two branches is typed.
+func (u *unifier) join(a, b Type) Type {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	case u.unify(a, b):
+		return a
+	default:
+		return Any
+	}
+}
+
+// joinFixed is join for types that can't bind the free variables of fixed,
+// they are Any when agreeing would take binding one.
+func (u *unifier) joinFixed(a, b Type, fixed []Type) Type {
+	if a == nil || b == nil {
+		return u.join(a, b)
+	}
+
+	vars := make(map[*Var]bool)
+	for _, t := range fixed {
+		freeVars(t, vars)
+	}
+	mark := len(u.trail)
+	if !u.unify(a, b) {
+		return Any
+	}
+	for _, v := range u.trail[mark:] {
+		if vars[v] {
+			u.undo(mark)
+			return Any
+		}
+	}
+	return a
+}
+
+// freeVars adds the unbound type variables of t to vars.
+func freeVars(t Type, vars map[*Var]bool) {
+	switch t := prune(t).(type) {
+	case *Var:
+		vars[t] = true
+	case *List:
+		freeVars(t.Elem, vars)
+	case *Map:
+		freeVars(t.Key, vars)
+		freeVars(t.Value, vars)
+	case *Func:
+		for _, p := range t.Params {
+			freeVars(p, vars)
+		}
+		freeVars(t.Result, vars)
+	case *Optional:
+		freeVars(t.Elem, vars)
+	}
+}
+
+// instantiate returns the type of s with fresh variables for the ones it
+// is generalized over.
+func (u *unifier) instantiate(s *scheme) Type {
+	if len(s.vars) == 0 {
+		return s.t
+	}
+	fresh := make(map[*Var]Type, len(s.vars))
+	for _, v := range s.vars {
+		fresh[v] = u.fresh()
+	}
+	return substitute(s.t, fresh)
+}
+
+func substitute(t Type, vars map[*Var]Type) Type {
+	switch t := prune(t).(type) {
+	case *Var:
+		if s, ok := vars[t]; ok {
+			return s
+		}
+		return t
+	case *List:
+		return &List{Elem: substitute(t.Elem, vars)}
+	case *Map:
+		return &Map{Key: substitute(t.Key, vars), Value: substitute(t.Value, vars)}
+	case *Func:
+		params := make([]Type, len(t.Params))
+		for i, p := range t.Params {
+			params[i] = substitute(p, vars)
+		}
+		return &Func{Params: params, Result: substitute(t.Result, vars), Variadic: t.Variadic}
+	case *Optional:
+		return &Optional{Elem: substitute(t.Elem, vars)}
+	default:
+		return t
+	}
+}
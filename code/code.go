@@ -0,0 +1,249 @@
+// Package code defines the bytecode instruction set of lemon: the opcodes,
+// their operands and how they are encoded.
+package code
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+type Instructions []byte
+
+func (ins Instructions) String() string {
+	var out bytes.Buffer
+
+	i := 0
+	for i < len(ins) {
+		def, err := Lookup(ins[i])
+		if err != nil {
+			fmt.Fprintf(&out, "ERROR: %s\n", err)
+			i++
+			continue
+		}
+
+		operands, read := ReadOperands(def, ins[i+1:])
+		fmt.Fprintf(&out, "%04d %s\n", i, fmtInstruction(def, operands))
+
+		i += 1 + read
+	}
+
+	return out.String()
+}
+
+func fmtInstruction(def *Definition, operands []int) string {
+	if len(operands) != len(def.OperandWidths) {
+		return fmt.Sprintf("ERROR: operand len %d does not match defined %d\n", len(operands), len(def.OperandWidths))
+	}
+
+	var out bytes.Buffer
+	out.WriteString(def.Name)
+	for _, operand := range operands {
+		fmt.Fprintf(&out, " %d", operand)
+	}
+	return out.String()
+}
+
+type Opcode byte
+
+const (
+	OpConstant Opcode = iota
+	OpPop
+	OpTrue
+	OpFalse
+	OpNull
+
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpMod
+	OpBitAnd
+	OpBitOr
+	OpBitXor
+	OpShl
+	OpShr
+	OpEqual
+	OpNotEqual
+	OpLess
+	OpGreater
+	OpLessEqual
+	OpGreaterEqual
+
+	OpMinus
+	OpBang
+	OpBitNot
+
+	OpJump
+	OpJumpNotTruthy
+
+	OpGetGlobal
+	OpSetGlobal
+	OpGetLocal
+	OpSetLocal
+	OpNewCell
+	OpGetCell
+	OpSetCell
+	OpGetFree
+	OpSetFree
+	OpGetBuiltin
+
+	OpArray
+	OpHash
+	OpIndex
+	OpSetIndex
+	OpSlice
+	OpInterpolate
+
+	OpIter
+	OpNext
+
+	OpCaptureLocal
+	OpCaptureFree
+	OpClosure
+	OpCall
+	OpReturnValue
+)
+
+// Definition describes an opcode, OperandWidths holds the size in bytes of
+// each of its operands.
+type Definition struct {
+	Name          string
+	OperandWidths []int
+}
+
+var definitions = map[Opcode]*Definition{
+	// OpConstant pushes the constant at the index of its operand.
+	OpConstant: {"OpConstant", []int{2}},
+	OpPop:      {"OpPop", []int{}},
+	OpTrue:     {"OpTrue", []int{}},
+	OpFalse:    {"OpFalse", []int{}},
+	OpNull:     {"OpNull", []int{}},
+
+	OpAdd:          {"OpAdd", []int{}},
+	OpSub:          {"OpSub", []int{}},
+	OpMul:          {"OpMul", []int{}},
+	OpDiv:          {"OpDiv", []int{}},
+	OpMod:          {"OpMod", []int{}},
+	OpBitAnd:       {"OpBitAnd", []int{}},
+	OpBitOr:        {"OpBitOr", []int{}},
+	OpBitXor:       {"OpBitXor", []int{}},
+	OpShl:          {"OpShl", []int{}},
+	OpShr:          {"OpShr", []int{}},
+	OpEqual:        {"OpEqual", []int{}},
+	OpNotEqual:     {"OpNotEqual", []int{}},
+	OpLess:         {"OpLess", []int{}},
+	OpGreater:      {"OpGreater", []int{}},
+	OpLessEqual:    {"OpLessEqual", []int{}},
+	OpGreaterEqual: {"OpGreaterEqual", []int{}},
+
+	OpMinus:  {"OpMinus", []int{}},
+	OpBang:   {"OpBang", []int{}},
+	OpBitNot: {"OpBitNot", []int{}},
+
+	// jumps take the absolute offset of their target
+	OpJump:          {"OpJump", []int{2}},
+	OpJumpNotTruthy: {"OpJumpNotTruthy", []int{2}},
+
+	OpGetGlobal: {"OpGetGlobal", []int{2}},
+	OpSetGlobal: {"OpSetGlobal", []int{2}},
+	OpGetLocal:  {"OpGetLocal", []int{1}},
+	OpSetLocal:  {"OpSetLocal", []int{1}},
+	// OpNewCell puts a new, empty cell into a local slot, the second operand
+	// is the constant holding the variable's name for error messages.
+	OpNewCell: {"OpNewCell", []int{1, 2}},
+	OpGetCell: {"OpGetCell", []int{1}},
+	OpSetCell: {"OpSetCell", []int{1}},
+	OpGetFree: {"OpGetFree", []int{1}},
+	OpSetFree: {"OpSetFree", []int{1}},
+	// OpGetBuiltin looks up the builtin named by a constant.
+	OpGetBuiltin: {"OpGetBuiltin", []int{2}},
+
+	OpArray:    {"OpArray", []int{2}},
+	OpHash:     {"OpHash", []int{2}},
+	OpIndex:    {"OpIndex", []int{}},
+	OpSetIndex: {"OpSetIndex", []int{}},
+	// the operand of OpSlice tells which bounds are on the stack, 1 for the
+	// low and 2 for the high one
+	OpSlice:       {"OpSlice", []int{1}},
+	OpInterpolate: {"OpInterpolate", []int{2}},
+
+	// OpIter replaces an iterable with an iterator over it, OpNext pops the
+	// iterator and pushes its next item or jumps once it is exhausted.
+	OpIter: {"OpIter", []int{}},
+	OpNext: {"OpNext", []int{2}},
+
+	// OpCaptureLocal and OpCaptureFree push the cell of a variable for
+	// OpClosure to capture, which takes the function's constant index and
+	// the number of cells.
+	OpCaptureLocal: {"OpCaptureLocal", []int{1}},
+	OpCaptureFree:  {"OpCaptureFree", []int{1}},
+	OpClosure:      {"OpClosure", []int{2, 1}},
+	OpCall:         {"OpCall", []int{1}},
+	OpReturnValue:  {"OpReturnValue", []int{}},
+}
+
+func Lookup(op byte) (*Definition, error) {
+	def, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+	return def, nil
+}
+
+// Make encodes an instruction, operands are written big endian.
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	length := 1
+	for _, w := range def.OperandWidths {
+		length += w
+	}
+
+	instruction := make([]byte, length)
+	instruction[0] = byte(op)
+
+	offset := 1
+	for i, o := range operands {
+		width := def.OperandWidths[i]
+		switch width {
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		case 1:
+			instruction[offset] = byte(o)
+		}
+		offset += width
+	}
+
+	return instruction
+}
+
+// ReadOperands decodes the operands of an instruction, ins starts right after
+// the opcode. It returns them with the number of bytes read.
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+
+	for i, width := range def.OperandWidths {
+		switch width {
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
+		}
+		offset += width
+	}
+
+	return operands, offset
+}
+
+func ReadUint16(ins Instructions) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
+
+func ReadUint8(ins Instructions) uint8 {
+	return uint8(ins[0])
+}
@@ -0,0 +1,123 @@
+package code
+
+import "testing"
+
+func TestMake(t *testing.T) {
+	tests := []struct {
+		op       Opcode
+		operands []int
+		expected []byte
+	}{
+		{OpConstant, []int{65534}, []byte{byte(OpConstant), 255, 254}},
+		{OpAdd, []int{}, []byte{byte(OpAdd)}},
+		{OpGetLocal, []int{255}, []byte{byte(OpGetLocal), 255}},
+		{OpClosure, []int{65534, 255}, []byte{byte(OpClosure), 255, 254, 255}},
+		{OpNewCell, []int{3, 513}, []byte{byte(OpNewCell), 3, 2, 1}},
+	}
+
+	for _, tt := range tests {
+		instruction := Make(tt.op, tt.operands...)
+
+		if len(instruction) != len(tt.expected) {
+			t.Fatalf("Expected instruction of length %d, got %d", len(tt.expected), len(instruction))
+		}
+		for i, b := range tt.expected {
+			if instruction[i] != b {
+				t.Errorf("Expected byte %d to be %d, got %d", i, b, instruction[i])
+			}
+		}
+	}
+}
+
+func TestInstructionsString(t *testing.T) {
+	instructions := []Instructions{
+		Make(OpAdd),
+		Make(OpGetLocal, 1),
+		Make(OpConstant, 2),
+		Make(OpConstant, 65535),
+		Make(OpClosure, 65535, 255),
+	}
+
+	expected := `0000 OpAdd
+0001 OpGetLocal 1
+0003 OpConstant 2
+0006 OpConstant 65535
+0009 OpClosure 65535 255
+`
+
+	concatted := Instructions{}
+	for _, ins := range instructions {
+		concatted = append(concatted, ins...)
+	}
+
+	if concatted.String() != expected {
+		t.Errorf("Expected instructions formatted as\n%q\ngot\n%q", expected, concatted.String())
+	}
+}
+
+func TestReadOperands(t *testing.T) {
+	tests := []struct {
+		op        Opcode
+		operands  []int
+		bytesRead int
+	}{
+		{OpConstant, []int{65535}, 2},
+		{OpGetLocal, []int{255}, 1},
+		{OpClosure, []int{65535, 255}, 3},
+	}
+
+	for _, tt := range tests {
+		instruction := Make(tt.op, tt.operands...)
+
+		def, err := Lookup(byte(tt.op))
+		if err != nil {
+			t.Fatalf("definition not found: %q", err)
+		}
+
+		operandsRead, n := ReadOperands(def, instruction[1:])
+		if n != tt.bytesRead {
+			t.Fatalf("Expected %d bytes read, got %d", tt.bytesRead, n)
+		}
+
+		for i, want := range tt.operands {
+			if operandsRead[i] != want {
+				t.Errorf("Expected operand %d, got %d", want, operandsRead[i])
+			}
+		}
+	}
+}
+
+func TestLineTable(t *testing.T) {
+	var lines LineTable
+	lines = lines.Add(0, 1)
+	lines = lines.Add(3, 1)
+	lines = lines.Add(6, 3)
+	lines = lines.Add(9, 0)
+	lines = lines.Add(10, 2)
+
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(lines))
+	}
+
+	tests := []struct {
+		offset int
+		line   int
+	}{
+		{0, 1},
+		{5, 1},
+		{6, 3},
+		{9, 3},
+		{10, 2},
+		{99, 2},
+	}
+
+	for _, tt := range tests {
+		if line := lines.Line(tt.offset); line != tt.line {
+			t.Errorf("offset %d - expected line %d, got %d", tt.offset, tt.line, line)
+		}
+	}
+
+	if line := LineTable(nil).Line(0); line != 0 {
+		t.Errorf("Expected line 0 in an empty table, got %d", line)
+	}
+}
@@ -0,0 +1,32 @@
+package code
+
+import "sort"
+
+// LineEntry says the instructions from Offset up to the next entry of a
+// LineTable were compiled from Line of the source.
+type LineEntry struct {
+	Offset int
+	Line   int
+}
+
+// LineTable maps the instructions of a function back to the source lines
+// they come from, ordered by offset.
+type LineTable []LineEntry
+
+// Add records that the instruction at offset comes from line, it only grows
+// the table when the line changes.
+func (lt LineTable) Add(offset, line int) LineTable {
+	if line <= 0 || (len(lt) > 0 && lt[len(lt)-1].Line == line) {
+		return lt
+	}
+	return append(lt, LineEntry{Offset: offset, Line: line})
+}
+
+// Line returns the source line of the instruction at offset, 0 if unknown.
+func (lt LineTable) Line(offset int) int {
+	i := sort.Search(len(lt), func(i int) bool { return lt[i].Offset > offset })
+	if i == 0 {
+		return 0
+	}
+	return lt[i-1].Line
+}
@@ -0,0 +1,972 @@
+// Package compiler translates a parsed program into bytecode for the vm.
+package compiler
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/chaitanya-Uike/lemon/ast"
+	"github.com/chaitanya-Uike/lemon/code"
+	"github.com/chaitanya-Uike/lemon/object"
+	"github.com/chaitanya-Uike/lemon/token"
+)
+
+// Bytecode is a compiled program. It runs as the body of a function with
+// NumLocals slots for the variables of its blocks, top level variables are
+// globals.
+type Bytecode struct {
+	Instructions code.Instructions
+	Constants    []object.Object
+	NumLocals    int
+	Globals      []string
+	Lines        code.LineTable
+
+	// Source names the file the program was compiled from, it is only used
+	// in listings.
+	Source string
+}
+
+var infixOpcodes = map[string]code.Opcode{
+	"+":  code.OpAdd,
+	"-":  code.OpSub,
+	"*":  code.OpMul,
+	"/":  code.OpDiv,
+	"%":  code.OpMod,
+	"&":  code.OpBitAnd,
+	"|":  code.OpBitOr,
+	"^":  code.OpBitXor,
+	"<<": code.OpShl,
+	">>": code.OpShr,
+	"==": code.OpEqual,
+	"!=": code.OpNotEqual,
+	"<":  code.OpLess,
+	">":  code.OpGreater,
+	"<=": code.OpLessEqual,
+	">=": code.OpGreaterEqual,
+}
+
+var prefixOpcodes = map[string]code.Opcode{
+	"-": code.OpMinus,
+	"!": code.OpBang,
+	"~": code.OpBitNot,
+}
+
+// CompilationScope holds the instructions of the function being compiled.
+type CompilationScope struct {
+	instructions code.Instructions
+	lines        code.LineTable
+	loops        []*loop
+}
+
+// loop tracks the jumps of break and continue statements, they are patched
+// once the addresses they go to are known.
+type loop struct {
+	label     string
+	breaks    []int
+	continues []int
+}
+
+type Compiler struct {
+	constants []object.Object
+	names     map[string]int
+
+	symbolTable *SymbolTable
+	scopes      []CompilationScope
+	scopeIndex  int
+	line        int
+
+	captured map[*ast.IdentifierLiteral]bool
+}
+
+func New() *Compiler {
+	return newCompiler(make(map[*ast.IdentifierLiteral]bool))
+}
+
+func newCompiler(captured map[*ast.IdentifierLiteral]bool) *Compiler {
+	return &Compiler{
+		names:       make(map[string]int),
+		symbolTable: NewSymbolTable(captured),
+		scopes:      []CompilationScope{{}},
+		captured:    captured,
+	}
+}
+
+// Compile compiles program, errors are reported with the messages the
+// evaluator gives at runtime. They are found in the whole program though:
+// a declaration repeated in a branch that never runs is an error here, the
+// evaluator only fails once it runs it. The resolver reports these errors
+// before either of them runs the program.
+//
+// The program is compiled twice: which variables closures capture is only
+// known once their bodies are compiled, but it decides how the code declaring
+// the variables is compiled. The first pass only collects them.
+func (c *Compiler) Compile(program *ast.Program) error {
+	if err := newCompiler(c.captured).compileProgram(program); err != nil {
+		return err
+	}
+	return c.compileProgram(program)
+}
+
+func (c *Compiler) compileProgram(program *ast.Program) error {
+	if err := c.hoist(program.Statements); err != nil {
+		return err
+	}
+	if err := c.compileStatements(program.Statements, true); err != nil {
+		return err
+	}
+	c.emit(code.OpReturnValue)
+
+	if c.symbolTable.frame.maxLocals > localsLimit {
+		return errors.New(tooManyLocals)
+	}
+	return nil
+}
+
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Instructions: c.currentInstructions(),
+		Constants:    c.constants,
+		NumLocals:    c.symbolTable.frame.maxLocals,
+		Globals:      *c.symbolTable.globals,
+		Lines:        c.scopes[c.scopeIndex].lines,
+	}
+}
+
+// compileStatements compiles a list of statements. With value set the last
+// one leaves its result on the stack, as the evaluator would return it.
+func (c *Compiler) compileStatements(stmts []ast.Statement, value bool) error {
+	for i, stmt := range stmts {
+		if err := c.compileStatement(stmt, value && i == len(stmts)-1); err != nil {
+			return err
+		}
+	}
+	if value && len(stmts) == 0 {
+		c.emit(code.OpNull)
+	}
+	return nil
+}
+
+func (c *Compiler) compileStatement(stmt ast.Statement, value bool) error {
+	defer c.at(stmt)()
+
+	switch stmt := stmt.(type) {
+	case *ast.ExpressionStatement:
+		if err := c.compileExpression(stmt.Expression); err != nil {
+			return err
+		}
+		if !value {
+			c.emit(code.OpPop)
+		}
+		return nil
+	case *ast.BlockStatement:
+		c.enterBlock()
+		if err := c.hoist(stmt.Statements); err != nil {
+			return err
+		}
+		if err := c.compileStatements(stmt.Statements, value); err != nil {
+			return err
+		}
+		c.leaveBlock()
+		return nil
+	case *ast.IfStatement:
+		return c.compileIfStatement(stmt, value)
+	case *ast.ReturnStatement:
+		if stmt.ReturnValue == nil {
+			c.emit(code.OpNull)
+		} else if err := c.compileExpression(stmt.ReturnValue); err != nil {
+			return err
+		}
+		c.emit(code.OpReturnValue)
+		return nil
+	case *ast.BranchStatement:
+		return c.compileBranchStatement(stmt)
+	}
+
+	var err error
+	switch stmt := stmt.(type) {
+	case *ast.DeclareStatement:
+		err = c.compileDeclaration(stmt.Name, stmt.Value)
+	case *ast.FunctionStatement:
+		err = c.compileDeclaration(stmt.Name, stmt.Function)
+	case *ast.AssignStatement:
+		err = c.compileAssignStatement(stmt)
+	case *ast.WhileStatement:
+		err = c.compileWhileStatement(stmt, "")
+	case *ast.ForInStatement:
+		err = c.compileForInStatement(stmt, "")
+	case *ast.ForStatement:
+		err = c.compileForStatement(stmt, "")
+	case *ast.LabeledStatement:
+		err = c.compileLabeledStatement(stmt)
+	default:
+		err = fmt.Errorf("unknown node: %T", stmt)
+	}
+	if err != nil {
+		return err
+	}
+
+	// the remaining statements evaluate to nothing
+	if value {
+		c.emit(code.OpNull)
+	}
+	return nil
+}
+
+// hoist defines the variables declared by stmts in the current scope before
+// any of them are compiled, so functions in the scope can refer to variables
+// declared after them. Captured variables get their cell right away.
+func (c *Compiler) hoist(stmts []ast.Statement) error {
+	for _, stmt := range stmts {
+		var name *ast.IdentifierLiteral
+		switch stmt := stmt.(type) {
+		case *ast.DeclareStatement:
+			name = stmt.Name
+		case *ast.FunctionStatement:
+			name = stmt.Name
+		default:
+			continue
+		}
+
+		if _, ok := c.symbolTable.store[name.Value]; ok {
+			continue
+		}
+		symbol := c.symbolTable.Define(name)
+		if symbol.Scope == CellScope {
+			if err := c.newCell(symbol); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Compiler) compileDeclaration(name *ast.IdentifierLiteral, value ast.Expression) error {
+	symbol := c.symbolTable.store[name.Value]
+	if symbol.declared {
+		return fmt.Errorf("identifier already declared: %s", name.Value)
+	}
+
+	var err error
+	if fl, ok := value.(*ast.FunctionLiteral); ok {
+		err = c.compileFunctionLiteral(fl, name.Value)
+	} else {
+		err = c.compileExpression(value)
+	}
+	if err != nil {
+		return err
+	}
+
+	symbol.declared = true
+	return c.storeSymbol(symbol)
+}
+
+func (c *Compiler) compileAssignStatement(as *ast.AssignStatement) error {
+	switch target := as.Target.(type) {
+	case *ast.IdentifierLiteral:
+		symbol, ok := c.symbolTable.Resolve(target.Value)
+		if !ok {
+			return fmt.Errorf("assignment to undeclared identifier: %s", target.Value)
+		}
+		if err := c.compileExpression(as.Value); err != nil {
+			return err
+		}
+		return c.storeSymbol(symbol)
+	case *ast.IndexExpression:
+		if err := c.compileExpression(target.Left); err != nil {
+			return err
+		}
+		if err := c.compileExpression(target.Index); err != nil {
+			return err
+		}
+		if err := c.compileExpression(as.Value); err != nil {
+			return err
+		}
+		c.emit(code.OpSetIndex)
+		return nil
+	default:
+		return fmt.Errorf("cannot assign to %s", as.Target)
+	}
+}
+
+func (c *Compiler) compileIfStatement(is *ast.IfStatement, value bool) error {
+	if err := c.compileExpression(is.Condition); err != nil {
+		return err
+	}
+	jumpNotTruthy, err := c.emit(code.OpJumpNotTruthy, 9999)
+	if err != nil {
+		return err
+	}
+
+	if err := c.compileStatement(is.Consequence, value); err != nil {
+		return err
+	}
+
+	if is.Alternate == nil && !value {
+		return c.patchJump(jumpNotTruthy)
+	}
+
+	jump, err := c.emit(code.OpJump, 9999)
+	if err != nil {
+		return err
+	}
+	if err := c.patchJump(jumpNotTruthy); err != nil {
+		return err
+	}
+
+	if is.Alternate == nil {
+		c.emit(code.OpNull)
+	} else if err := c.compileStatement(is.Alternate, value); err != nil {
+		return err
+	}
+
+	return c.patchJump(jump)
+}
+
+func (c *Compiler) compileLabeledStatement(ls *ast.LabeledStatement) error {
+	switch stmt := ls.Statement.(type) {
+	case *ast.WhileStatement:
+		return c.compileWhileStatement(stmt, ls.Label.Value)
+	case *ast.ForInStatement:
+		return c.compileForInStatement(stmt, ls.Label.Value)
+	case *ast.ForStatement:
+		return c.compileForStatement(stmt, ls.Label.Value)
+	default:
+		return c.compileStatement(ls.Statement, false)
+	}
+}
+
+func (c *Compiler) compileWhileStatement(ws *ast.WhileStatement, label string) error {
+	start := len(c.currentInstructions())
+
+	if err := c.compileExpression(ws.Condition); err != nil {
+		return err
+	}
+	exit, err := c.emit(code.OpJumpNotTruthy, 9999)
+	if err != nil {
+		return err
+	}
+
+	c.enterLoop(label)
+	if err := c.compileStatement(ws.Body, false); err != nil {
+		return err
+	}
+	if _, err := c.emit(code.OpJump, start); err != nil {
+		return err
+	}
+
+	if err := c.leaveLoop(start); err != nil {
+		return err
+	}
+	return c.patchJump(exit)
+}
+
+// compileForInStatement keeps the iterator in a hidden local, so that no
+// loop state is left on the stack when break or return leave the loop. The
+// variable and the body share a scope entered anew for every item.
+func (c *Compiler) compileForInStatement(fs *ast.ForInStatement, label string) error {
+	c.enterBlock()
+	iterator := c.symbolTable.DefineHidden()
+
+	if err := c.compileExpression(fs.Iterable); err != nil {
+		return err
+	}
+	c.emit(code.OpIter)
+	if err := c.storeSymbol(iterator); err != nil {
+		return err
+	}
+
+	start := len(c.currentInstructions())
+	if err := c.loadSymbol(iterator); err != nil {
+		return err
+	}
+	next, err := c.emit(code.OpNext, 9999)
+	if err != nil {
+		return err
+	}
+
+	c.enterBlock()
+	variable := c.symbolTable.Define(fs.Variable)
+	if variable.Scope == CellScope {
+		if err := c.newCell(variable); err != nil {
+			return err
+		}
+	}
+	variable.declared = true
+	if err := c.hoist(fs.Body.Statements); err != nil {
+		return err
+	}
+	if err := c.storeSymbol(variable); err != nil {
+		return err
+	}
+
+	c.enterLoop(label)
+	if err := c.compileStatements(fs.Body.Statements, false); err != nil {
+		return err
+	}
+	c.leaveBlock()
+	if _, err := c.emit(code.OpJump, start); err != nil {
+		return err
+	}
+
+	if err := c.leaveLoop(start); err != nil {
+		return err
+	}
+	if err := c.patchJump(next); err != nil {
+		return err
+	}
+	c.leaveBlock()
+	return nil
+}
+
+// compileForStatement compiles the clauses in a scope of their own around
+// the body, a variable declared by the init statement is shared by all
+// iterations.
+func (c *Compiler) compileForStatement(fs *ast.ForStatement, label string) error {
+	c.enterBlock()
+
+	if fs.Init != nil {
+		if err := c.hoist([]ast.Statement{fs.Init}); err != nil {
+			return err
+		}
+		if err := c.compileStatement(fs.Init, false); err != nil {
+			return err
+		}
+	}
+
+	start := len(c.currentInstructions())
+	exit := -1
+	if fs.Condition != nil {
+		if err := c.compileExpression(fs.Condition); err != nil {
+			return err
+		}
+		var err error
+		exit, err = c.emit(code.OpJumpNotTruthy, 9999)
+		if err != nil {
+			return err
+		}
+	}
+
+	c.enterLoop(label)
+	if err := c.compileStatement(fs.Body, false); err != nil {
+		return err
+	}
+
+	post := len(c.currentInstructions())
+	if fs.Post != nil {
+		if err := c.compileStatement(fs.Post, false); err != nil {
+			return err
+		}
+	}
+	if _, err := c.emit(code.OpJump, start); err != nil {
+		return err
+	}
+
+	if err := c.leaveLoop(post); err != nil {
+		return err
+	}
+	if exit >= 0 {
+		if err := c.patchJump(exit); err != nil {
+			return err
+		}
+	}
+	c.leaveBlock()
+	return nil
+}
+
+func (c *Compiler) compileBranchStatement(bs *ast.BranchStatement) error {
+	loops := c.scopes[c.scopeIndex].loops
+	if len(loops) == 0 {
+		return fmt.Errorf("%s outside of a loop", bs.TokenLiteral())
+	}
+
+	target := loops[len(loops)-1]
+	if bs.Label != nil {
+		target = nil
+		for _, l := range loops {
+			if l.label == bs.Label.Value {
+				target = l
+			}
+		}
+		if target == nil {
+			return fmt.Errorf("%s label not defined: %s", bs.TokenLiteral(), bs.Label.Value)
+		}
+	}
+
+	jump, err := c.emit(code.OpJump, 9999)
+	if err != nil {
+		return err
+	}
+	if bs.Token.Type == token.BREAK {
+		target.breaks = append(target.breaks, jump)
+	} else {
+		target.continues = append(target.continues, jump)
+	}
+	return nil
+}
+
+func (c *Compiler) enterLoop(label string) {
+	scope := &c.scopes[c.scopeIndex]
+	scope.loops = append(scope.loops, &loop{label: label})
+}
+
+// leaveLoop patches the branches of the innermost loop, continue goes to
+// next and break to the current end of the instructions.
+func (c *Compiler) leaveLoop(next int) error {
+	scope := &c.scopes[c.scopeIndex]
+	l := scope.loops[len(scope.loops)-1]
+	scope.loops = scope.loops[:len(scope.loops)-1]
+
+	for _, pos := range l.continues {
+		if err := c.changeOperand(pos, next); err != nil {
+			return err
+		}
+	}
+	for _, pos := range l.breaks {
+		if err := c.patchJump(pos); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Compiler) compileExpression(exp ast.Expression) error {
+	defer c.at(exp)()
+
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral:
+		if exp.Big != nil {
+			return c.emitConstant(&object.BigInteger{Value: exp.Big})
+		}
+		return c.emitConstant(&object.Integer{Value: exp.Value})
+	case *ast.FloatLiteral:
+		return c.emitConstant(&object.Float{Value: exp.Value})
+	case *ast.DecimalLiteral:
+		return c.emitConstant(&object.Decimal{Value: exp.Value})
+	case *ast.StringLiteral:
+		return c.emitConstant(&object.String{Value: exp.Value})
+	case *ast.BooleanLiteral:
+		if exp.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+	case *ast.InterpolatedString:
+		for _, part := range exp.Parts {
+			if err := c.compileExpression(part); err != nil {
+				return err
+			}
+		}
+		_, err := c.emit(code.OpInterpolate, len(exp.Parts))
+		return err
+	case *ast.IdentifierLiteral:
+		symbol, ok := c.symbolTable.Resolve(exp.Value)
+		if !ok {
+			// whatever is not a variable has to be a builtin, the vm reports
+			// an unknown name when it is reached like the evaluator does
+			name, err := c.addName(exp.Value)
+			if err != nil {
+				return err
+			}
+			_, err = c.emit(code.OpGetBuiltin, name)
+			return err
+		}
+		return c.loadSymbol(symbol)
+	case *ast.PrefixExpression:
+		if err := c.compileExpression(exp.Expression); err != nil {
+			return err
+		}
+		op, ok := prefixOpcodes[exp.Operator]
+		if !ok {
+			return fmt.Errorf("unknown operator: %s", exp.Operator)
+		}
+		c.emit(op)
+	case *ast.InfixExpression:
+		if exp.Operator == "&&" || exp.Operator == "||" {
+			return c.compileLogicalExpression(exp)
+		}
+		if err := c.compileExpression(exp.Left); err != nil {
+			return err
+		}
+		if err := c.compileExpression(exp.Right); err != nil {
+			return err
+		}
+		op, ok := infixOpcodes[exp.Operator]
+		if !ok {
+			return fmt.Errorf("unknown operator: %s", exp.Operator)
+		}
+		c.emit(op)
+	case *ast.ArrayLiteral:
+		for _, el := range exp.Elements {
+			if err := c.compileExpression(el); err != nil {
+				return err
+			}
+		}
+		_, err := c.emit(code.OpArray, len(exp.Elements))
+		return err
+	case *ast.HashLiteral:
+		for _, pair := range exp.Pairs {
+			if err := c.compileExpression(pair.Key); err != nil {
+				return err
+			}
+			if err := c.compileExpression(pair.Value); err != nil {
+				return err
+			}
+		}
+		_, err := c.emit(code.OpHash, len(exp.Pairs)*2)
+		return err
+	case *ast.IndexExpression:
+		if err := c.compileExpression(exp.Left); err != nil {
+			return err
+		}
+		if err := c.compileExpression(exp.Index); err != nil {
+			return err
+		}
+		c.emit(code.OpIndex)
+	case *ast.SliceExpression:
+		return c.compileSliceExpression(exp)
+	case *ast.FunctionLiteral:
+		return c.compileFunctionLiteral(exp, "")
+	case *ast.CallExpression:
+		if err := c.compileExpression(exp.Function); err != nil {
+			return err
+		}
+		for _, arg := range exp.Arguments {
+			if err := c.compileExpression(arg); err != nil {
+				return err
+			}
+		}
+		_, err := c.emit(code.OpCall, len(exp.Arguments))
+		return err
+	default:
+		return fmt.Errorf("unknown node: %T", exp)
+	}
+
+	return nil
+}
+
+// compileLogicalExpression only evaluates the right operand when the left
+// one doesn't decide the result. Like the evaluator, the result is always a
+// boolean, a value is turned into one by negating it twice.
+func (c *Compiler) compileLogicalExpression(exp *ast.InfixExpression) error {
+	if err := c.compileExpression(exp.Left); err != nil {
+		return err
+	}
+	jumpNotTruthy, err := c.emit(code.OpJumpNotTruthy, 9999)
+	if err != nil {
+		return err
+	}
+
+	if exp.Operator == "||" {
+		c.emit(code.OpTrue)
+		jump, err := c.emit(code.OpJump, 9999)
+		if err != nil {
+			return err
+		}
+		if err := c.patchJump(jumpNotTruthy); err != nil {
+			return err
+		}
+		if err := c.compileExpression(exp.Right); err != nil {
+			return err
+		}
+		c.emit(code.OpBang)
+		c.emit(code.OpBang)
+		return c.patchJump(jump)
+	}
+
+	if err := c.compileExpression(exp.Right); err != nil {
+		return err
+	}
+	c.emit(code.OpBang)
+	c.emit(code.OpBang)
+	jump, err := c.emit(code.OpJump, 9999)
+	if err != nil {
+		return err
+	}
+	if err := c.patchJump(jumpNotTruthy); err != nil {
+		return err
+	}
+	c.emit(code.OpFalse)
+	return c.patchJump(jump)
+}
+
+func (c *Compiler) compileSliceExpression(se *ast.SliceExpression) error {
+	if err := c.compileExpression(se.Left); err != nil {
+		return err
+	}
+
+	bounds := 0
+	if se.Low != nil {
+		if err := c.compileExpression(se.Low); err != nil {
+			return err
+		}
+		bounds |= 1
+	}
+	if se.High != nil {
+		if err := c.compileExpression(se.High); err != nil {
+			return err
+		}
+		bounds |= 2
+	}
+
+	_, err := c.emit(code.OpSlice, bounds)
+	return err
+}
+
+// compileFunctionLiteral compiles the body into a constant and emits the
+// creation of a closure over the cells of the variables it captures.
+// Parameters start out as plain values in their slots, captured ones are
+// moved into a cell first.
+func (c *Compiler) compileFunctionLiteral(fl *ast.FunctionLiteral, name string) error {
+	c.enterScope()
+
+	for _, param := range fl.Parameters {
+		symbol := c.symbolTable.Define(param)
+		symbol.declared = true
+		if symbol.Scope == CellScope {
+			if _, err := c.emit(code.OpGetLocal, symbol.Index); err != nil {
+				return err
+			}
+			if err := c.newCell(symbol); err != nil {
+				return err
+			}
+			if _, err := c.emit(code.OpSetCell, symbol.Index); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := c.hoist(fl.Body.Statements); err != nil {
+		return err
+	}
+	if err := c.compileStatements(fl.Body.Statements, true); err != nil {
+		return err
+	}
+	c.emit(code.OpReturnValue)
+
+	free := c.symbolTable.frame.Free
+	numLocals := c.symbolTable.frame.maxLocals
+	if numLocals > localsLimit {
+		return errors.New(tooManyLocals)
+	}
+	lines := c.scopes[c.scopeIndex].lines
+	instructions := c.leaveScope()
+
+	for _, symbol := range free {
+		op := code.OpCaptureLocal
+		if symbol.Scope == FreeScope {
+			op = code.OpCaptureFree
+		}
+		if _, err := c.emit(op, symbol.Index); err != nil {
+			return err
+		}
+	}
+
+	fn := &object.CompiledFunction{
+		Instructions:  instructions,
+		NumLocals:     numLocals,
+		NumParameters: len(fl.Parameters),
+		Name:          name,
+		Lines:         lines,
+	}
+	index, err := c.addConstant(fn)
+	if err != nil {
+		return err
+	}
+	_, err = c.emit(code.OpClosure, index, len(free))
+	return err
+}
+
+func (c *Compiler) loadSymbol(s *Symbol) error {
+	var err error
+	switch s.Scope {
+	case GlobalScope:
+		_, err = c.emit(code.OpGetGlobal, s.Index)
+	case LocalScope:
+		_, err = c.emit(code.OpGetLocal, s.Index)
+	case CellScope:
+		_, err = c.emit(code.OpGetCell, s.Index)
+	case FreeScope:
+		_, err = c.emit(code.OpGetFree, s.Index)
+	}
+	return err
+}
+
+func (c *Compiler) storeSymbol(s *Symbol) error {
+	var err error
+	switch s.Scope {
+	case GlobalScope:
+		_, err = c.emit(code.OpSetGlobal, s.Index)
+	case LocalScope:
+		_, err = c.emit(code.OpSetLocal, s.Index)
+	case CellScope:
+		_, err = c.emit(code.OpSetCell, s.Index)
+	case FreeScope:
+		_, err = c.emit(code.OpSetFree, s.Index)
+	}
+	return err
+}
+
+// newCell emits the creation of the cell holding a captured variable.
+func (c *Compiler) newCell(s *Symbol) error {
+	name, err := c.addName(s.Name)
+	if err != nil {
+		return err
+	}
+	_, err = c.emit(code.OpNewCell, s.Index, name)
+	return err
+}
+
+// addConstant returns the index of obj in the constant pool, which has to
+// fit the operand of OpConstant.
+func (c *Compiler) addConstant(obj object.Object) (int, error) {
+	if len(c.constants) > math.MaxUint16 {
+		return 0, errors.New(tooManyConstants)
+	}
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1, nil
+}
+
+func (c *Compiler) emitConstant(obj object.Object) error {
+	index, err := c.addConstant(obj)
+	if err != nil {
+		return err
+	}
+	_, err = c.emit(code.OpConstant, index)
+	return err
+}
+
+// addName returns the constant holding name as a string, every name is only
+// added once.
+func (c *Compiler) addName(name string) (int, error) {
+	if index, ok := c.names[name]; ok {
+		return index, nil
+	}
+	index, err := c.addConstant(&object.String{Value: name})
+	if err != nil {
+		return 0, err
+	}
+	c.names[name] = index
+	return index, nil
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+// localsLimit is the number of locals a function can have, their slots are
+// a byte operand.
+const localsLimit = 256
+
+const (
+	tooManyConstants = "too many constants"
+	tooManyLocals    = "too many local variables"
+	tooManyFree      = "too many free variables"
+	jumpTooFar       = "jump target out of range"
+)
+
+// operandErrors describe what each operand too large for its instruction
+// stands for.
+var operandErrors = map[code.Opcode][]string{
+	code.OpConstant:      {tooManyConstants},
+	code.OpGetBuiltin:    {tooManyConstants},
+	code.OpJump:          {jumpTooFar},
+	code.OpJumpNotTruthy: {jumpTooFar},
+	code.OpNext:          {jumpTooFar},
+	code.OpGetGlobal:     {"too many global variables"},
+	code.OpSetGlobal:     {"too many global variables"},
+	code.OpGetLocal:      {tooManyLocals},
+	code.OpSetLocal:      {tooManyLocals},
+	code.OpGetCell:       {tooManyLocals},
+	code.OpSetCell:       {tooManyLocals},
+	code.OpNewCell:       {tooManyLocals, tooManyConstants},
+	code.OpCaptureLocal:  {tooManyLocals},
+	code.OpGetFree:       {tooManyFree},
+	code.OpSetFree:       {tooManyFree},
+	code.OpCaptureFree:   {tooManyFree},
+	code.OpArray:         {"too many elements in array literal"},
+	code.OpHash:          {"too many pairs in hash literal"},
+	code.OpInterpolate:   {"too many parts in string"},
+	code.OpCall:          {"too many arguments"},
+	code.OpClosure:       {tooManyConstants, tooManyFree},
+}
+
+// emit appends an instruction and returns its position, operands that don't
+// fit their width are an error rather than being truncated.
+func (c *Compiler) emit(op code.Opcode, operands ...int) (int, error) {
+	if err := checkOperands(op, operands); err != nil {
+		return 0, err
+	}
+	ins := code.Make(op, operands...)
+	pos := len(c.currentInstructions())
+	scope := &c.scopes[c.scopeIndex]
+	scope.instructions = append(scope.instructions, ins...)
+	scope.lines = scope.lines.Add(pos, c.line)
+	return pos, nil
+}
+
+func checkOperands(op code.Opcode, operands []int) error {
+	def, err := code.Lookup(byte(op))
+	if err != nil {
+		return err
+	}
+	for i, width := range def.OperandWidths {
+		if operands[i] < 0 || operands[i] >= 1<<(8*width) {
+			if msgs := operandErrors[op]; i < len(msgs) {
+				return errors.New(msgs[i])
+			}
+			return fmt.Errorf("operand %d of %s out of range: %d", i, def.Name, operands[i])
+		}
+	}
+	return nil
+}
+
+// at attributes the instructions emitted for node to its line, the returned
+// function restores the line of the enclosing node.
+func (c *Compiler) at(node ast.Node) func() {
+	line := c.line
+	if pos := node.Pos(); pos.IsValid() {
+		c.line = pos.Line
+	}
+	return func() { c.line = line }
+}
+
+// changeOperand rewrites the operand of the single operand instruction at
+// pos, it is used to patch jumps once their target is known.
+func (c *Compiler) changeOperand(pos int, operand int) error {
+	op := code.Opcode(c.currentInstructions()[pos])
+	if err := checkOperands(op, []int{operand}); err != nil {
+		return err
+	}
+	copy(c.currentInstructions()[pos:], code.Make(op, operand))
+	return nil
+}
+
+// patchJump points the jump at pos to the current end of the instructions.
+func (c *Compiler) patchJump(pos int) error {
+	return c.changeOperand(pos, len(c.currentInstructions()))
+}
+
+func (c *Compiler) enterBlock() {
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveBlock() {
+	c.symbolTable = c.symbolTable.Leave()
+}
+
+func (c *Compiler) enterScope() {
+	c.scopes = append(c.scopes, CompilationScope{})
+	c.scopeIndex++
+	c.symbolTable = NewFunctionSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() code.Instructions {
+	instructions := c.currentInstructions()
+
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+	c.symbolTable = c.symbolTable.Outer
+
+	return instructions
+}
@@ -0,0 +1,218 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/chaitanya-Uike/lemon/code"
+	"github.com/chaitanya-Uike/lemon/lexer"
+	"github.com/chaitanya-Uike/lemon/object"
+	"github.com/chaitanya-Uike/lemon/parser"
+)
+
+type compilerTestCase struct {
+	input                string
+	expectedConstants    []any
+	expectedInstructions []code.Instructions
+}
+
+func TestExpressions(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input:             "1 + 2",
+			expectedConstants: []any{1, 2},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "if true { 10 }",
+			expectedConstants: []any{10},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpTrue),
+				code.Make(code.OpJumpNotTruthy, 10),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpJump, 11),
+				code.Make(code.OpNull),
+				code.Make(code.OpReturnValue),
+			},
+		},
+		{
+			input:             "x := 1; x",
+			expectedConstants: []any{1},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestClosures(t *testing.T) {
+	tests := []compilerTestCase{
+		{
+			input: "func(a) { b := a; func() { b } }",
+			expectedConstants: []any{
+				"b",
+				[]code.Instructions{
+					code.Make(code.OpGetFree, 0),
+					code.Make(code.OpReturnValue),
+				},
+				[]code.Instructions{
+					code.Make(code.OpNewCell, 1, 0),
+					code.Make(code.OpGetLocal, 0),
+					code.Make(code.OpSetCell, 1),
+					code.Make(code.OpCaptureLocal, 1),
+					code.Make(code.OpClosure, 1, 1),
+					code.Make(code.OpReturnValue),
+				},
+			},
+			expectedInstructions: []code.Instructions{
+				code.Make(code.OpClosure, 2, 0),
+				code.Make(code.OpReturnValue),
+			},
+		},
+	}
+
+	runCompilerTests(t, tests)
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a := 1; a := 2", "identifier already declared: a"},
+		{"b = 1", "assignment to undeclared identifier: b"},
+	}
+
+	for _, tt := range tests {
+		err := New().Compile(parser.New(lexer.New(tt.input)).ParseProgram())
+		if err == nil {
+			t.Fatalf("%s - expected error %q, got none", tt.input, tt.expected)
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("%s - expected error %q, got %q", tt.input, tt.expected, err)
+		}
+	}
+}
+
+// TestOperandLimits compiles programs right at and just past the largest
+// operand their instructions can hold.
+func TestOperandLimits(t *testing.T) {
+	repeat := func(s string, n int) string {
+		return strings.TrimSuffix(strings.Repeat(s, n), ", ")
+	}
+	locals := func(n int) string {
+		var b strings.Builder
+		for i := range n {
+			fmt.Fprintf(&b, "a%d := 0\n", i)
+		}
+		return b.String()
+	}
+	uses := func(n int) string {
+		var b strings.Builder
+		for i := range n {
+			fmt.Fprintf(&b, "a%d\n", i)
+		}
+		return b.String()
+	}
+
+	tests := []struct {
+		name     string
+		input    func(n int) string
+		limit    int
+		expected string
+	}{
+		{"constants", func(n int) string { return repeat("1; ", n) }, 65536, "too many constants"},
+		{"arguments", func(n int) string { return "puts(" + repeat("1, ", n) + ")" }, 255, "too many arguments"},
+		{"array", func(n int) string { return "[" + repeat("1, ", n) + "]" }, 65535, "too many elements in array literal"},
+		{"hash", func(n int) string { return "({" + repeat("1: 1, ", n) + "})" }, 32767, "too many pairs in hash literal"},
+		{"interpolation", func(n int) string { return `"` + strings.Repeat("${1}", n) + `"` }, 65535, "too many parts in string"},
+		// the jump over the body goes to 4 + 4*n
+		{"jump", func(n int) string { return "if false { " + strings.Repeat("1; ", n) + "}\n0" }, 16382, "jump target out of range"},
+		{"free variables", func(n int) string { return "func() {\n" + locals(n) + "func() {\n" + uses(n) + "}\n}" }, 255, "too many free variables"},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input(tt.limit))).ParseProgram()
+		if err := New().Compile(program); err != nil {
+			t.Errorf("%s - unexpected error at the limit of %d: %s", tt.name, tt.limit, err)
+		}
+
+		program = parser.New(lexer.New(tt.input(tt.limit + 1))).ParseProgram()
+		err := New().Compile(program)
+		if err == nil {
+			t.Errorf("%s - expected error %q past the limit, got none", tt.name, tt.expected)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("%s - expected error %q, got %q", tt.name, tt.expected, err)
+		}
+	}
+}
+
+func runCompilerTests(t *testing.T, tests []compilerTestCase) {
+	t.Helper()
+
+	for _, tt := range tests {
+		compiler := New()
+		if err := compiler.Compile(parser.New(lexer.New(tt.input)).ParseProgram()); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		bytecode := compiler.Bytecode()
+		testInstructions(t, tt.expectedInstructions, bytecode.Instructions)
+		testConstants(t, tt.expectedConstants, bytecode.Constants)
+	}
+}
+
+func testInstructions(t *testing.T, expected []code.Instructions, actual code.Instructions) {
+	t.Helper()
+
+	var concatted code.Instructions
+	for _, ins := range expected {
+		concatted = append(concatted, ins...)
+	}
+
+	if actual.String() != concatted.String() {
+		t.Errorf("Expected instructions\n%s\ngot\n%s", concatted, actual)
+	}
+}
+
+func testConstants(t *testing.T, expected []any, actual []object.Object) {
+	t.Helper()
+
+	if len(expected) != len(actual) {
+		t.Fatalf("Expected %d constants, got %d", len(expected), len(actual))
+	}
+
+	for i, constant := range expected {
+		switch constant := constant.(type) {
+		case int:
+			integer, ok := actual[i].(*object.Integer)
+			if !ok || integer.Value != int64(constant) {
+				t.Errorf("constant %d - expected %d, got %s", i, constant, actual[i].Inspect())
+			}
+		case string:
+			str, ok := actual[i].(*object.String)
+			if !ok || str.Value != constant {
+				t.Errorf("constant %d - expected %q, got %s", i, constant, actual[i].Inspect())
+			}
+		case []code.Instructions:
+			fn, ok := actual[i].(*object.CompiledFunction)
+			if !ok {
+				t.Fatalf("constant %d - expected *object.CompiledFunction, got %T", i, actual[i])
+			}
+			testInstructions(t, constant, fn.Instructions)
+		}
+	}
+}
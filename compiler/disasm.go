@@ -0,0 +1,110 @@
+package compiler
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/chaitanya-Uike/lemon/code"
+	"github.com/chaitanya-Uike/lemon/object"
+)
+
+// Disassemble lists the instructions of the main function and of every
+// function in the constant pool. Each instruction is prefixed by the source
+// line it was compiled from where that line starts, and operands referring
+// to constants or names are followed by what they refer to.
+func (b *Bytecode) Disassemble() string {
+	var out bytes.Buffer
+
+	header := fmt.Sprintf("main (locals %d)", b.NumLocals)
+	if b.Source != "" {
+		header = fmt.Sprintf("main %s (locals %d)", b.Source, b.NumLocals)
+	}
+	b.disassemble(&out, header, b.Instructions, b.Lines)
+
+	for i, constant := range b.Constants {
+		fn, ok := constant.(*object.CompiledFunction)
+		if !ok {
+			continue
+		}
+		out.WriteString("\n")
+		header := fmt.Sprintf("%s (params %d, locals %d)", functionName(i, fn), fn.NumParameters, fn.NumLocals)
+		b.disassemble(&out, header, fn.Instructions, fn.Lines)
+	}
+
+	return out.String()
+}
+
+func (b *Bytecode) disassemble(out *bytes.Buffer, header string, ins code.Instructions, lines code.LineTable) {
+	fmt.Fprintf(out, "%s:\n", header)
+
+	prevLine := 0
+	for i := 0; i < len(ins); {
+		def, err := code.Lookup(ins[i])
+		if err != nil {
+			fmt.Fprintf(out, "ERROR: %s\n", err)
+			return
+		}
+		operands, read := code.ReadOperands(def, ins[i+1:])
+
+		lineColumn := ""
+		if line := lines.Line(i); line != prevLine {
+			lineColumn = fmt.Sprint(line)
+			prevLine = line
+		}
+
+		text := def.Name
+		for _, operand := range operands {
+			text += fmt.Sprintf(" %d", operand)
+		}
+		if comment := b.operandComment(code.Opcode(ins[i]), operands); comment != "" {
+			text = fmt.Sprintf("%-24s ; %s", text, comment)
+		}
+
+		fmt.Fprintf(out, "%4s  %04d %s\n", lineColumn, i, text)
+		i += 1 + read
+	}
+}
+
+// operandComment describes the constant or name an instruction refers to.
+func (b *Bytecode) operandComment(op code.Opcode, operands []int) string {
+	switch op {
+	case code.OpConstant:
+		return b.constantComment(operands[0])
+	case code.OpGetBuiltin, code.OpNewCell:
+		return b.constantComment(operands[len(operands)-1])
+	case code.OpClosure:
+		if fn, ok := b.constant(operands[0]).(*object.CompiledFunction); ok {
+			return functionName(operands[0], fn)
+		}
+	case code.OpGetGlobal, code.OpSetGlobal:
+		if operands[0] < len(b.Globals) {
+			return b.Globals[operands[0]]
+		}
+	}
+	return ""
+}
+
+func (b *Bytecode) constantComment(index int) string {
+	switch constant := b.constant(index).(type) {
+	case nil:
+		return "invalid constant"
+	case *object.String:
+		return fmt.Sprintf("%q", constant.Value)
+	default:
+		return constant.Inspect()
+	}
+}
+
+func (b *Bytecode) constant(index int) object.Object {
+	if index >= len(b.Constants) {
+		return nil
+	}
+	return b.Constants[index]
+}
+
+func functionName(index int, fn *object.CompiledFunction) string {
+	if fn.Name == "" {
+		return fmt.Sprintf("function %d", index)
+	}
+	return fmt.Sprintf("function %d %s", index, fn.Name)
+}
@@ -0,0 +1,478 @@
+package compiler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/chaitanya-Uike/lemon/code"
+	"github.com/chaitanya-Uike/lemon/decimal"
+	"github.com/chaitanya-Uike/lemon/object"
+)
+
+// A bytecode file starts with Magic and the Version of the format, then
+// holds the source name, the global names, the main function and the
+// constant pool. Functions are their name, locals, parameters, instructions
+// and line table. Numbers are varints and strings are length prefixed.
+const (
+	Magic   = "\x00lmc"
+	Version = 1
+)
+
+var ErrBadMagic = errors.New("not a lemon bytecode file")
+
+var errTruncated = errors.New("truncated bytecode file")
+
+// constant tags
+const (
+	tagInteger byte = iota + 1
+	tagBigInteger
+	tagFloat
+	tagDecimal
+	tagString
+	tagFunction
+)
+
+// MarshalBinary encodes the bytecode in the file format read by
+// UnmarshalBinary.
+func (b *Bytecode) MarshalBinary() ([]byte, error) {
+	e := &encoder{}
+	e.buf.WriteString(Magic)
+	e.uint(Version)
+
+	e.string(b.Source)
+	e.uint(uint64(len(b.Globals)))
+	for _, name := range b.Globals {
+		e.string(name)
+	}
+	e.function(&object.CompiledFunction{Instructions: b.Instructions, NumLocals: b.NumLocals, Lines: b.Lines})
+
+	e.uint(uint64(len(b.Constants)))
+	for _, constant := range b.Constants {
+		if err := e.constant(constant); err != nil {
+			return nil, err
+		}
+	}
+
+	return e.buf.Bytes(), nil
+}
+
+// UnmarshalBinary decodes a bytecode file written by MarshalBinary. Files of
+// another Version are rejected.
+func (b *Bytecode) UnmarshalBinary(data []byte) error {
+	if !bytes.HasPrefix(data, []byte(Magic)) {
+		return ErrBadMagic
+	}
+	d := &decoder{data: data[len(Magic):]}
+
+	if version := d.uint(); d.err == nil && version != Version {
+		return fmt.Errorf("unsupported bytecode version %d, want %d", version, Version)
+	}
+
+	source := d.string()
+	globals := make([]string, d.length())
+	for i := range globals {
+		globals[i] = d.string()
+	}
+	main := d.function()
+
+	constants := make([]object.Object, d.length())
+	for i := range constants {
+		constants[i] = d.constant()
+	}
+
+	if d.err == nil && len(d.data) != 0 {
+		d.err = fmt.Errorf("%d bytes after the constant pool", len(d.data))
+	}
+	if d.err != nil {
+		return d.err
+	}
+	if err := verify(main, constants, len(globals)); err != nil {
+		return err
+	}
+
+	*b = Bytecode{
+		Instructions: main.Instructions,
+		Constants:    constants,
+		NumLocals:    main.NumLocals,
+		Globals:      globals,
+		Lines:        main.Lines,
+		Source:       source,
+	}
+	return nil
+}
+
+type encoder struct {
+	buf bytes.Buffer
+}
+
+func (e *encoder) uint(n uint64) {
+	e.buf.Write(binary.AppendUvarint(nil, n))
+}
+
+func (e *encoder) string(s string) {
+	e.uint(uint64(len(s)))
+	e.buf.WriteString(s)
+}
+
+func (e *encoder) function(fn *object.CompiledFunction) {
+	e.string(fn.Name)
+	e.uint(uint64(fn.NumLocals))
+	e.uint(uint64(fn.NumParameters))
+	e.string(string(fn.Instructions))
+
+	e.uint(uint64(len(fn.Lines)))
+	for _, entry := range fn.Lines {
+		e.uint(uint64(entry.Offset))
+		e.uint(uint64(entry.Line))
+	}
+}
+
+func (e *encoder) constant(constant object.Object) error {
+	switch constant := constant.(type) {
+	case *object.Integer:
+		e.buf.WriteByte(tagInteger)
+		e.buf.Write(binary.AppendVarint(nil, constant.Value))
+	case *object.BigInteger:
+		e.buf.WriteByte(tagBigInteger)
+		e.string(constant.Value.String())
+	case *object.Float:
+		e.buf.WriteByte(tagFloat)
+		e.uint(math.Float64bits(constant.Value))
+	case *object.Decimal:
+		e.buf.WriteByte(tagDecimal)
+		e.string(constant.Value.String())
+	case *object.String:
+		e.buf.WriteByte(tagString)
+		e.string(constant.Value)
+	case *object.CompiledFunction:
+		e.buf.WriteByte(tagFunction)
+		e.function(constant)
+	default:
+		return fmt.Errorf("cannot encode constant of type %s", constant.Type())
+	}
+	return nil
+}
+
+// decoder reads the parts of a bytecode file, after the first error every
+// read returns a zero value and err keeps that error.
+type decoder struct {
+	data []byte
+	err  error
+}
+
+func (d *decoder) fail(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+	d.data = nil
+}
+
+func (d *decoder) uint() uint64 {
+	n, size := binary.Uvarint(d.data)
+	if size <= 0 {
+		d.fail(errTruncated)
+		return 0
+	}
+	d.data = d.data[size:]
+	return n
+}
+
+// length reads a count of things that follow, each takes at least a byte so
+// it can't be more than what is left.
+func (d *decoder) length() int {
+	n := d.uint()
+	if n > uint64(len(d.data)) {
+		d.fail(errTruncated)
+		return 0
+	}
+	return int(n)
+}
+
+func (d *decoder) bytes() []byte {
+	n := d.length()
+	b := d.data[:n:n]
+	d.data = d.data[n:]
+	return b
+}
+
+func (d *decoder) string() string {
+	return string(d.bytes())
+}
+
+func (d *decoder) function() *object.CompiledFunction {
+	fn := &object.CompiledFunction{
+		Name:          d.string(),
+		NumLocals:     int(d.uint()),
+		NumParameters: int(d.uint()),
+		Instructions:  code.Instructions(d.bytes()),
+	}
+
+	lines := make(code.LineTable, d.length())
+	for i := range lines {
+		lines[i] = code.LineEntry{Offset: int(d.uint()), Line: int(d.uint())}
+	}
+	fn.Lines = lines
+	return fn
+}
+
+func (d *decoder) constant() object.Object {
+	if len(d.data) == 0 {
+		d.fail(errTruncated)
+		return nil
+	}
+	tag := d.data[0]
+	d.data = d.data[1:]
+
+	switch tag {
+	case tagInteger:
+		n, size := binary.Varint(d.data)
+		if size <= 0 {
+			d.fail(errTruncated)
+			return nil
+		}
+		d.data = d.data[size:]
+		return &object.Integer{Value: n}
+	case tagBigInteger:
+		s := d.string()
+		n, ok := new(big.Int).SetString(s, 10)
+		if !ok {
+			d.fail(fmt.Errorf("invalid integer constant %q", s))
+			return nil
+		}
+		return object.NewInteger(n)
+	case tagFloat:
+		return &object.Float{Value: math.Float64frombits(d.uint())}
+	case tagDecimal:
+		s := d.string()
+		n, err := decimal.Parse(s)
+		if err != nil {
+			d.fail(fmt.Errorf("invalid decimal constant %q", s))
+			return nil
+		}
+		return &object.Decimal{Value: n}
+	case tagString:
+		return &object.String{Value: d.string()}
+	case tagFunction:
+		return d.function()
+	default:
+		d.fail(fmt.Errorf("unknown constant tag %d", tag))
+		return nil
+	}
+}
+
+// verify checks the functions of a decoded program, the vm does not check
+// what it runs. Every function has to be a sequence of whole instructions
+// whose operands refer to constants of the right type, to its locals and
+// free variables and to the start of its instructions.
+func verify(main *object.CompiledFunction, constants []object.Object, numGlobals int) error {
+	fns := []*object.CompiledFunction{main}
+	for _, constant := range constants {
+		if fn, ok := constant.(*object.CompiledFunction); ok {
+			fns = append(fns, fn)
+		}
+	}
+
+	starts := make(map[*object.CompiledFunction][]bool, len(fns))
+	for _, fn := range fns {
+		var err error
+		if starts[fn], err = instructionStarts(fn.Instructions); err != nil {
+			return err
+		}
+	}
+
+	// a function can only use the free variables of the smallest closure
+	// made over it
+	free := map[*object.CompiledFunction]int{}
+	for _, fn := range fns {
+		for pos, start := range starts[fn] {
+			if !start || code.Opcode(fn.Instructions[pos]) != code.OpClosure {
+				continue
+			}
+			def, _ := code.Lookup(fn.Instructions[pos])
+			operands, _ := code.ReadOperands(def, fn.Instructions[pos+1:])
+			closed, ok := constantAt(constants, operands[0]).(*object.CompiledFunction)
+			if !ok {
+				return fmt.Errorf("%s at %04d does not refer to a function", def.Name, pos)
+			}
+			if n, seen := free[closed]; !seen || operands[1] < n {
+				free[closed] = operands[1]
+			}
+		}
+	}
+
+	for _, fn := range fns {
+		if fn.NumLocals > localsLimit || fn.NumParameters > fn.NumLocals {
+			return fmt.Errorf("invalid number of locals %d", fn.NumLocals)
+		}
+		for pos, start := range starts[fn] {
+			if !start {
+				continue
+			}
+			if err := verifyOperands(fn, pos, starts[fn], constants, numGlobals, free[fn]); err != nil {
+				return err
+			}
+		}
+	}
+	for _, fn := range fns {
+		if err := verifyStack(fn.Instructions); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// instructionStarts marks the offsets in ins where an instruction starts,
+// ins has to hold whole instructions.
+func instructionStarts(ins code.Instructions) ([]bool, error) {
+	starts := make([]bool, len(ins))
+	for i := 0; i < len(ins); {
+		def, err := code.Lookup(ins[i])
+		if err != nil {
+			return nil, err
+		}
+		starts[i] = true
+		i++
+		for _, width := range def.OperandWidths {
+			i += width
+		}
+		if i > len(ins) {
+			return nil, errTruncated
+		}
+	}
+	return starts, nil
+}
+
+func verifyOperands(fn *object.CompiledFunction, pos int, starts []bool, constants []object.Object, numGlobals, numFree int) error {
+	op := code.Opcode(fn.Instructions[pos])
+	def, _ := code.Lookup(byte(op))
+	operands, _ := code.ReadOperands(def, fn.Instructions[pos+1:])
+
+	var ok bool
+	switch op {
+	case code.OpConstant:
+		ok = operands[0] < len(constants)
+	case code.OpGetBuiltin:
+		_, ok = constantAt(constants, operands[0]).(*object.String)
+	case code.OpNewCell:
+		_, ok = constantAt(constants, operands[1]).(*object.String)
+		ok = ok && operands[0] < fn.NumLocals
+	case code.OpGetLocal, code.OpSetLocal, code.OpGetCell, code.OpSetCell, code.OpCaptureLocal:
+		ok = operands[0] < fn.NumLocals
+	case code.OpGetFree, code.OpSetFree, code.OpCaptureFree:
+		ok = operands[0] < numFree
+	case code.OpGetGlobal, code.OpSetGlobal:
+		ok = operands[0] < numGlobals
+	case code.OpHash:
+		ok = operands[0]%2 == 0
+	case code.OpJump, code.OpJumpNotTruthy, code.OpNext:
+		ok = operands[0] < len(starts) && starts[operands[0]]
+	default:
+		return nil
+	}
+	if !ok {
+		return fmt.Errorf("invalid operand of %s at %04d", def.Name, pos)
+	}
+	return nil
+}
+
+// verifyStack follows every path through the instructions of a function,
+// whose operands were verified, and checks that no instruction pops more
+// values than its path left on the stack. The paths meeting at an
+// instruction have to leave as many values and every path has to end in a
+// return.
+func verifyStack(ins code.Instructions) error {
+	depths := make([]int, len(ins)+1)
+	for i := range depths {
+		depths[i] = -1
+	}
+	depths[0] = 0
+	work := []int{0}
+
+	for len(work) > 0 {
+		pos := work[len(work)-1]
+		work = work[:len(work)-1]
+		if pos == len(ins) {
+			return fmt.Errorf("instructions end without a return")
+		}
+
+		op := code.Opcode(ins[pos])
+		def, _ := code.Lookup(byte(op))
+		operands, read := code.ReadOperands(def, ins[pos+1:])
+		pops, pushes := stackEffect(op, operands)
+		if depths[pos] < pops {
+			return fmt.Errorf("%s at %04d pops more values than there are", def.Name, pos)
+		}
+		depth := depths[pos] - pops + pushes
+
+		type edge struct{ pos, depth int }
+		next := pos + 1 + read
+		var edges []edge
+		switch op {
+		case code.OpReturnValue:
+		case code.OpJump:
+			edges = []edge{{operands[0], depth}}
+		case code.OpJumpNotTruthy:
+			edges = []edge{{next, depth}, {operands[0], depth}}
+		case code.OpNext:
+			// the loop ends without pushing an item
+			edges = []edge{{next, depth}, {operands[0], depth - 1}}
+		default:
+			edges = []edge{{next, depth}}
+		}
+
+		for _, e := range edges {
+			switch depths[e.pos] {
+			case -1:
+				depths[e.pos] = e.depth
+				work = append(work, e.pos)
+			case e.depth:
+			default:
+				return fmt.Errorf("paths to %04d leave different numbers of values on the stack", e.pos)
+			}
+		}
+	}
+	return nil
+}
+
+// stackEffect returns how many values an instruction pops and pushes.
+func stackEffect(op code.Opcode, operands []int) (int, int) {
+	switch op {
+	case code.OpConstant, code.OpTrue, code.OpFalse, code.OpNull,
+		code.OpGetGlobal, code.OpGetLocal, code.OpGetCell, code.OpGetFree, code.OpGetBuiltin,
+		code.OpCaptureLocal, code.OpCaptureFree:
+		return 0, 1
+	case code.OpPop, code.OpJumpNotTruthy, code.OpReturnValue,
+		code.OpSetGlobal, code.OpSetLocal, code.OpSetCell, code.OpSetFree:
+		return 1, 0
+	case code.OpMinus, code.OpBang, code.OpBitNot, code.OpIter, code.OpNext:
+		return 1, 1
+	case code.OpIndex:
+		return 2, 1
+	case code.OpSetIndex:
+		return 3, 0
+	case code.OpSlice:
+		return 1 + operands[0]&1 + operands[0]>>1&1, 1
+	case code.OpArray, code.OpHash, code.OpInterpolate:
+		return operands[0], 1
+	case code.OpClosure:
+		return operands[1], 1
+	case code.OpCall:
+		return operands[0] + 1, 1
+	case code.OpJump, code.OpNewCell:
+		return 0, 0
+	}
+	// the infix operators
+	return 2, 1
+}
+
+// constantAt returns the constant at index, or nil when there is none.
+func constantAt(constants []object.Object, index int) object.Object {
+	if index >= len(constants) {
+		return nil
+	}
+	return constants[index]
+}
@@ -0,0 +1,240 @@
+package compiler
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/chaitanya-Uike/lemon/code"
+	"github.com/chaitanya-Uike/lemon/lexer"
+	"github.com/chaitanya-Uike/lemon/object"
+	"github.com/chaitanya-Uike/lemon/parser"
+)
+
+func compile(t *testing.T, input string) *Bytecode {
+	t.Helper()
+
+	compiler := New()
+	if err := compiler.Compile(parser.New(lexer.New(input)).ParseProgram()); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	return compiler.Bytecode()
+}
+
+func TestBytecodeRoundTrip(t *testing.T) {
+	bytecode := compile(t, `
+	x := 1 + 99999999999999999999
+	func scale(n) {
+		f := func() { n * 2.5 + 0.1d }
+		f()
+	}
+	"${scale(x)}"`)
+	bytecode.Source = "test.lm"
+
+	data, err := bytecode.MarshalBinary()
+	if err != nil {
+		t.Fatalf("marshal error: %s", err)
+	}
+
+	decoded := &Bytecode{}
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatalf("unmarshal error: %s", err)
+	}
+
+	if decoded.Disassemble() != bytecode.Disassemble() {
+		t.Errorf("Expected\n%s\ngot\n%s", bytecode.Disassemble(), decoded.Disassemble())
+	}
+}
+
+func TestBytecodeDecodeErrors(t *testing.T) {
+	data, err := compile(t, "func f(x) { x }\nf(1)").MarshalBinary()
+	if err != nil {
+		t.Fatalf("marshal error: %s", err)
+	}
+
+	tests := []struct {
+		data     string
+		expected string
+	}{
+		{"", ErrBadMagic.Error()},
+		{"#!lemon", ErrBadMagic.Error()},
+		{Magic + "\x02", "unsupported bytecode version 2, want 1"},
+		{Magic + "\x01\x05ab", "truncated bytecode file"},
+		{string(data[:len(data)-3]), "truncated bytecode file"},
+		{string(data) + "\x00", "1 bytes after the constant pool"},
+		{Magic + "\x01\x00\x00\x00\x00\x00\x02\xff\x00\x00\x00", "opcode 255 undefined"},
+	}
+
+	for _, tt := range tests {
+		err := (&Bytecode{}).UnmarshalBinary([]byte(tt.data))
+		if err == nil {
+			t.Fatalf("%q - expected error %q, got none", tt.data, tt.expected)
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("%q - expected error %q, got %q", tt.data, tt.expected, err)
+		}
+	}
+
+	if err := (&Bytecode{}).UnmarshalBinary(nil); !errors.Is(err, ErrBadMagic) {
+		t.Errorf("Expected ErrBadMagic, got %v", err)
+	}
+}
+
+func TestBytecodeVerifyErrors(t *testing.T) {
+	ins := func(instructions ...[]byte) code.Instructions {
+		var out code.Instructions
+		for _, i := range instructions {
+			out = append(out, i...)
+		}
+		return out
+	}
+	fn := &object.CompiledFunction{Instructions: ins(code.Make(code.OpGetFree, 0), code.Make(code.OpReturnValue))}
+
+	tests := []struct {
+		bytecode *Bytecode
+		expected string
+	}{
+		{
+			&Bytecode{Instructions: ins(code.Make(code.OpConstant, 7))},
+			"invalid operand of OpConstant at 0000",
+		},
+		{
+			&Bytecode{
+				Instructions: ins(code.Make(code.OpClosure, 0, 0)),
+				Constants:    []object.Object{&object.Integer{Value: 1}},
+			},
+			"OpClosure at 0000 does not refer to a function",
+		},
+		{
+			&Bytecode{
+				Instructions: ins(code.Make(code.OpNewCell, 0, 0)),
+				Constants:    []object.Object{&object.Integer{Value: 1}},
+				NumLocals:    1,
+			},
+			"invalid operand of OpNewCell at 0000",
+		},
+		{
+			&Bytecode{Instructions: ins(code.Make(code.OpGetBuiltin, 0))},
+			"invalid operand of OpGetBuiltin at 0000",
+		},
+		{
+			&Bytecode{Instructions: ins(code.Make(code.OpTrue), code.Make(code.OpSetLocal, 0))},
+			"invalid operand of OpSetLocal at 0001",
+		},
+		{
+			&Bytecode{Instructions: ins(code.Make(code.OpGetGlobal, 0))},
+			"invalid operand of OpGetGlobal at 0000",
+		},
+		{
+			&Bytecode{Instructions: ins(code.Make(code.OpGetFree, 0))},
+			"invalid operand of OpGetFree at 0000",
+		},
+		{
+			&Bytecode{
+				Instructions: ins(code.Make(code.OpClosure, 0, 0)),
+				Constants:    []object.Object{fn},
+			},
+			"invalid operand of OpGetFree at 0000",
+		},
+		{
+			&Bytecode{Instructions: ins(code.Make(code.OpJump, 2), code.Make(code.OpNull))},
+			"invalid operand of OpJump at 0000",
+		},
+		{
+			&Bytecode{Instructions: ins(code.Make(code.OpJump, 4))},
+			"invalid operand of OpJump at 0000",
+		},
+		{
+			&Bytecode{Instructions: ins(code.Make(code.OpNull)), NumLocals: 300},
+			"invalid number of locals 300",
+		},
+		{
+			&Bytecode{Instructions: ins(code.Make(code.OpHash, 1))},
+			"invalid operand of OpHash at 0000",
+		},
+		{
+			&Bytecode{Instructions: ins(code.Make(code.OpPop))},
+			"OpPop at 0000 pops more values than there are",
+		},
+		{
+			&Bytecode{Instructions: ins(code.Make(code.OpNull), code.Make(code.OpCall, 1))},
+			"OpCall at 0001 pops more values than there are",
+		},
+		{
+			&Bytecode{Instructions: ins(code.Make(code.OpNull))},
+			"instructions end without a return",
+		},
+		{
+			&Bytecode{Instructions: ins(
+				code.Make(code.OpTrue),
+				code.Make(code.OpJumpNotTruthy, 5),
+				code.Make(code.OpNull),
+				code.Make(code.OpNull),
+				code.Make(code.OpReturnValue),
+			)},
+			"paths to 0005 leave different numbers of values on the stack",
+		},
+	}
+
+	for _, tt := range tests {
+		data, err := tt.bytecode.MarshalBinary()
+		if err != nil {
+			t.Fatalf("marshal error: %s", err)
+		}
+
+		err = (&Bytecode{}).UnmarshalBinary(data)
+		if err == nil {
+			t.Errorf("%s - expected error %q, got none", tt.bytecode.Instructions, tt.expected)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("%s - expected error %q, got %q", tt.bytecode.Instructions, tt.expected, err)
+		}
+	}
+
+	closure := &Bytecode{
+		Instructions: ins(code.Make(code.OpNewCell, 0, 1), code.Make(code.OpCaptureLocal, 0), code.Make(code.OpClosure, 0, 1), code.Make(code.OpReturnValue)),
+		Constants:    []object.Object{fn, &object.String{Value: "x"}},
+		NumLocals:    1,
+	}
+	data, err := closure.MarshalBinary()
+	if err != nil {
+		t.Fatalf("marshal error: %s", err)
+	}
+	if err := (&Bytecode{}).UnmarshalBinary(data); err != nil {
+		t.Errorf("unexpected error for a closure over its free variable: %s", err)
+	}
+}
+
+func TestDisassemble(t *testing.T) {
+	bytecode := compile(t, "x := 5\nfunc double(n) {\n\treturn n * 2\n}\nputs(double(x))")
+	bytecode.Source = "double.lm"
+
+	expected := `main double.lm (locals 0):
+   1  0000 OpConstant 0             ; 5
+      0003 OpSetGlobal 0            ; x
+   2  0006 OpClosure 2 0            ; function 2 double
+      0010 OpSetGlobal 1            ; double
+   5  0013 OpGetBuiltin 3           ; "puts"
+      0016 OpGetGlobal 1            ; double
+      0019 OpGetGlobal 0            ; x
+      0022 OpCall 1
+      0024 OpCall 1
+      0026 OpReturnValue
+
+function 2 double (params 1, locals 1):
+   3  0000 OpGetLocal 0
+      0002 OpConstant 1             ; 2
+      0005 OpMul
+      0006 OpReturnValue
+   2  0007 OpReturnValue
+`
+
+	if actual := bytecode.Disassemble(); actual != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, actual)
+	}
+
+	if !strings.HasPrefix(compile(t, "1").Disassemble(), "main (locals 0):\n") {
+		t.Errorf("Expected no source name in the header")
+	}
+}
@@ -0,0 +1,170 @@
+package compiler
+
+import "github.com/chaitanya-Uike/lemon/ast"
+
+type SymbolScope string
+
+const (
+	GlobalScope SymbolScope = "GLOBAL"
+	LocalScope  SymbolScope = "LOCAL"
+	// CellScope is a local that closures capture, its slot holds an
+	// *object.Cell shared with them rather than the value itself.
+	CellScope SymbolScope = "CELL"
+	FreeScope SymbolScope = "FREE"
+)
+
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+
+	node     *ast.IdentifierLiteral
+	frame    *frame
+	declared bool
+}
+
+// frame holds what the blocks of one function share: the local slots and
+// the variables of enclosing functions it captures.
+type frame struct {
+	outer     *frame
+	numLocals int
+	maxLocals int
+
+	// Free lists the captured symbols as seen from the outer function, in
+	// the order of the function's free variables.
+	Free []*Symbol
+	free map[*Symbol]*Symbol
+}
+
+// SymbolTable is a single block scope. The outermost table of a program
+// holds its globals, the blocks of the program and of functions get local
+// slots.
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	store map[string]*Symbol
+	frame *frame
+	base  int
+
+	// globals is shared by all tables of a program, it names the globals by
+	// index
+	globals  *[]string
+	captured map[*ast.IdentifierLiteral]bool
+}
+
+// NewSymbolTable returns the global table of a program. captured holds the
+// declarations closures capture, those are given a CellScope.
+func NewSymbolTable(captured map[*ast.IdentifierLiteral]bool) *SymbolTable {
+	return &SymbolTable{
+		store:    make(map[string]*Symbol),
+		frame:    &frame{free: make(map[*Symbol]*Symbol)},
+		globals:  &[]string{},
+		captured: captured,
+	}
+}
+
+// NewEnclosedSymbolTable returns a block scope inside outer, in the same
+// function.
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	return &SymbolTable{
+		Outer:    outer,
+		store:    make(map[string]*Symbol),
+		frame:    outer.frame,
+		base:     outer.frame.numLocals,
+		globals:  outer.globals,
+		captured: outer.captured,
+	}
+}
+
+// NewFunctionSymbolTable returns the outermost scope of a function declared
+// inside outer.
+func NewFunctionSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewEnclosedSymbolTable(outer)
+	s.frame = &frame{outer: outer.frame, free: make(map[*Symbol]*Symbol)}
+	s.base = 0
+	return s
+}
+
+// Leave returns the outer scope, the local slots of s can be reused after.
+func (s *SymbolTable) Leave() *SymbolTable {
+	s.frame.numLocals = s.base
+	return s.Outer
+}
+
+// Define adds the variable declared by node to the scope, it is not visible
+// to the code of the function until it is marked declared.
+func (s *SymbolTable) Define(node *ast.IdentifierLiteral) *Symbol {
+	symbol := &Symbol{Name: node.Value, node: node, frame: s.frame}
+
+	switch {
+	case s.Outer == nil:
+		symbol.Scope = GlobalScope
+		symbol.Index = len(*s.globals)
+		*s.globals = append(*s.globals, node.Value)
+	case s.captured[node]:
+		symbol.Scope = CellScope
+		symbol.Index = s.frame.allocate()
+	default:
+		symbol.Scope = LocalScope
+		symbol.Index = s.frame.allocate()
+	}
+
+	s.store[node.Value] = symbol
+	return symbol
+}
+
+// DefineHidden reserves a local slot for the compiler's own use.
+func (s *SymbolTable) DefineHidden() *Symbol {
+	return &Symbol{Scope: LocalScope, Index: s.frame.allocate(), frame: s.frame, declared: true}
+}
+
+func (f *frame) allocate() int {
+	index := f.numLocals
+	f.numLocals++
+	f.maxLocals = max(f.maxLocals, f.numLocals)
+	return index
+}
+
+// Resolve finds the variable name refers to. Code only sees the variables
+// of its own function once they are declared, like the evaluator, but a
+// function body may use those of enclosing scopes declared after it, since
+// it only runs later: that's what lets top level functions call each other.
+func (s *SymbolTable) Resolve(name string) (*Symbol, bool) {
+	for t := s; t != nil; t = t.Outer {
+		symbol, ok := t.store[name]
+		if !ok {
+			continue
+		}
+		if t.frame == s.frame {
+			if !symbol.declared {
+				continue
+			}
+			return symbol, true
+		}
+		if symbol.Scope == GlobalScope {
+			return symbol, true
+		}
+		return s.frame.capture(symbol, s.captured), true
+	}
+	return nil, false
+}
+
+// capture returns the free variable of f for a local of an enclosing
+// function, capturing it in every function in between as well.
+func (f *frame) capture(symbol *Symbol, captured map[*ast.IdentifierLiteral]bool) *Symbol {
+	if free, ok := f.free[symbol]; ok {
+		return free
+	}
+
+	outer := symbol
+	if symbol.frame != f.outer {
+		outer = f.outer.capture(symbol, captured)
+	} else if symbol.node != nil {
+		captured[symbol.node] = true
+	}
+
+	free := &Symbol{Name: symbol.Name, Scope: FreeScope, Index: len(f.Free), frame: f, declared: true}
+	f.Free = append(f.Free, outer)
+	f.free[symbol] = free
+	return free
+}
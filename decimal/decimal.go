@@ -0,0 +1,237 @@
+// Package decimal implements exact base 10 numbers, the runtime values of
+// lemon's d suffixed literals such as 19.99d.
+package decimal
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// QuoScale is the number of fractional digits a quotient is rounded to when
+// it has no exact decimal representation, like 1d / 3d.
+const QuoScale = 20
+
+// maxExponent bounds the exponents Parse accepts, 1e1000000000 would
+// otherwise expand into a billion digits.
+const maxExponent = 10000
+
+var (
+	ErrSyntax         = errors.New("invalid decimal syntax")
+	ErrDivisionByZero = errors.New("division by zero")
+)
+
+// Decimal is the number coef * 10^-scale. The zero value is 0, Decimals are
+// immutable and safe to share.
+type Decimal struct {
+	coef  *big.Int
+	scale int32
+}
+
+func (d Decimal) coefficient() *big.Int {
+	if d.coef == nil {
+		return new(big.Int)
+	}
+	return d.coef
+}
+
+// Parse parses digits with an optional sign, fraction and exponent, '_' may
+// separate digits. The scale of the result is the number of digits written
+// after the point, so 1.50 keeps its trailing zero.
+func Parse(s string) (Decimal, error) {
+	s = strings.ReplaceAll(s, "_", "")
+
+	var exp int64
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		var err error
+		exp, err = strconv.ParseInt(s[i+1:], 10, 32)
+		if err != nil || exp > maxExponent || exp < -maxExponent {
+			return Decimal{}, ErrSyntax
+		}
+		s = s[:i]
+	}
+
+	digits, frac, _ := strings.Cut(s, ".")
+	digits += frac
+	if digits == "" || digits == "+" || digits == "-" {
+		return Decimal{}, ErrSyntax
+	}
+
+	coef, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return Decimal{}, ErrSyntax
+	}
+
+	scale := int64(len(frac)) - exp
+	if scale < 0 {
+		coef.Mul(coef, pow10(-scale))
+		scale = 0
+	}
+	return Decimal{coef: coef, scale: int32(scale)}, nil
+}
+
+func FromInt(i *big.Int) Decimal {
+	return Decimal{coef: new(big.Int).Set(i)}
+}
+
+func FromInt64(i int64) Decimal {
+	return Decimal{coef: big.NewInt(i)}
+}
+
+// FromFloat returns the decimal with the shortest representation that
+// converts back to f, so FromFloat(0.1) is 0.1 and not the binary value.
+func FromFloat(f float64) (Decimal, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return Decimal{}, ErrSyntax
+	}
+	return Parse(strconv.FormatFloat(f, 'g', -1, 64))
+}
+
+func pow10(n int64) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(n), nil)
+}
+
+// align returns the coefficients of d and e scaled to the larger of their
+// scales.
+func align(d, e Decimal) (*big.Int, *big.Int, int32) {
+	a, b := d.coefficient(), e.coefficient()
+	switch {
+	case d.scale < e.scale:
+		return new(big.Int).Mul(a, pow10(int64(e.scale-d.scale))), b, e.scale
+	case d.scale > e.scale:
+		return a, new(big.Int).Mul(b, pow10(int64(d.scale-e.scale))), d.scale
+	}
+	return a, b, d.scale
+}
+
+func (d Decimal) Add(e Decimal) Decimal {
+	a, b, scale := align(d, e)
+	return Decimal{coef: new(big.Int).Add(a, b), scale: scale}
+}
+
+func (d Decimal) Sub(e Decimal) Decimal {
+	a, b, scale := align(d, e)
+	return Decimal{coef: new(big.Int).Sub(a, b), scale: scale}
+}
+
+func (d Decimal) Mul(e Decimal) Decimal {
+	return Decimal{coef: new(big.Int).Mul(d.coefficient(), e.coefficient()), scale: d.scale + e.scale}
+}
+
+// Quo returns d / e rounded half to even to QuoScale fractional digits.
+// Trailing zeros are dropped down to the larger scale of d and e, so
+// 10.00d / 4d is 2.50 and not 2.50000000000000000000.
+func (d Decimal) Quo(e Decimal) (Decimal, error) {
+	if e.Sign() == 0 {
+		return Decimal{}, ErrDivisionByZero
+	}
+
+	// d/e = (a * 10^(QuoScale+es-ds) / b) * 10^-QuoScale
+	num := new(big.Int).Set(d.coefficient())
+	den := new(big.Int).Set(e.coefficient())
+	shift := int64(QuoScale) + int64(e.scale) - int64(d.scale)
+	if shift >= 0 {
+		num.Mul(num, pow10(shift))
+	} else {
+		den.Mul(den, pow10(-shift))
+	}
+
+	q, r := new(big.Int).QuoRem(num, den, new(big.Int))
+
+	// round half to even on the remainder
+	r2 := new(big.Int).Abs(r)
+	r2.Lsh(r2, 1)
+	if c := r2.Cmp(new(big.Int).Abs(den)); c > 0 || c == 0 && q.Bit(0) == 1 {
+		if num.Sign() == den.Sign() {
+			q.Add(q, big.NewInt(1))
+		} else {
+			q.Sub(q, big.NewInt(1))
+		}
+	}
+
+	return Decimal{coef: q, scale: QuoScale}.trim(max(d.scale, e.scale)), nil
+}
+
+// Rem returns the remainder of d / e truncated to an integer, it has the
+// sign of d like Go's % operator.
+func (d Decimal) Rem(e Decimal) (Decimal, error) {
+	if e.Sign() == 0 {
+		return Decimal{}, ErrDivisionByZero
+	}
+	a, b, scale := align(d, e)
+	return Decimal{coef: new(big.Int).Rem(a, b), scale: scale}, nil
+}
+
+func (d Decimal) Neg() Decimal {
+	return Decimal{coef: new(big.Int).Neg(d.coefficient()), scale: d.scale}
+}
+
+// trim drops trailing fractional zeros while the scale is above minScale.
+func (d Decimal) trim(minScale int32) Decimal {
+	coef := new(big.Int).Set(d.coefficient())
+	scale := d.scale
+	ten := big.NewInt(10)
+	r := new(big.Int)
+	for scale > minScale {
+		q, _ := new(big.Int).QuoRem(coef, ten, r)
+		if r.Sign() != 0 {
+			break
+		}
+		coef = q
+		scale--
+	}
+	return Decimal{coef: coef, scale: scale}
+}
+
+func (d Decimal) Sign() int {
+	return d.coefficient().Sign()
+}
+
+// Cmp compares the values of d and e, -1 if d < e, 0 if equal and 1 if d > e.
+// 1.5 and 1.50 are equal.
+func (d Decimal) Cmp(e Decimal) int {
+	a, b, _ := align(d, e)
+	return a.Cmp(b)
+}
+
+func (d Decimal) IsInteger() bool {
+	return d.trim(0).scale == 0
+}
+
+// Int returns d truncated towards zero.
+func (d Decimal) Int() *big.Int {
+	if d.scale == 0 {
+		return new(big.Int).Set(d.coefficient())
+	}
+	return new(big.Int).Quo(d.coefficient(), pow10(int64(d.scale)))
+}
+
+func (d Decimal) Float64() float64 {
+	f, _ := strconv.ParseFloat(d.String(), 64)
+	return f
+}
+
+// Normalize returns d without trailing fractional zeros, equal decimals have
+// equal normalized forms.
+func (d Decimal) Normalize() Decimal {
+	return d.trim(0)
+}
+
+func (d Decimal) String() string {
+	digits := d.coefficient().String()
+	if d.scale == 0 {
+		return digits
+	}
+
+	sign := ""
+	if digits[0] == '-' {
+		sign, digits = "-", digits[1:]
+	}
+	if pad := int(d.scale) - len(digits) + 1; pad > 0 {
+		digits = strings.Repeat("0", pad) + digits
+	}
+	point := len(digits) - int(d.scale)
+	return sign + digits[:point] + "." + digits[point:]
+}
@@ -0,0 +1,139 @@
+package decimal
+
+import (
+	"math/big"
+	"testing"
+)
+
+func mustParse(t *testing.T, s string) Decimal {
+	t.Helper()
+	d, err := Parse(s)
+	if err != nil {
+		t.Fatalf("Parse(%q) failed: %s", s, err)
+	}
+	return d
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"19.99", "19.99"},
+		{"1.50", "1.50"},
+		{"5", "5"},
+		{".5", "0.5"},
+		{"5.", "5"},
+		{"-0.05", "-0.05"},
+		{"1_000.25", "1000.25"},
+		{"1e3", "1000"},
+		{"2.5E-3", "0.0025"},
+		{"12345678901234567890.123456789", "12345678901234567890.123456789"},
+	}
+
+	for _, tt := range tests {
+		if got := mustParse(t, tt.input).String(); got != tt.expected {
+			t.Errorf("Parse(%q) - expected %s, got %s", tt.input, tt.expected, got)
+		}
+	}
+
+	for _, input := range []string{"", ".", "1.2.3", "abc", "1e", "-"} {
+		if _, err := Parse(input); err == nil {
+			t.Errorf("Expected Parse(%q) to fail", input)
+		}
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	tests := []struct {
+		left     string
+		operator string
+		right    string
+		expected string
+	}{
+		{"0.1", "+", "0.2", "0.3"},
+		{"19.99", "+", "0.01", "20.00"},
+		{"1", "-", "0.75", "0.25"},
+		{"19.99", "*", "3", "59.97"},
+		{"1.5", "*", "1.5", "2.25"},
+		{"10.00", "/", "4", "2.50"},
+		{"1", "/", "4", "0.25"},
+		{"1", "/", "3", "0.33333333333333333333"},
+		{"2", "/", "3", "0.66666666666666666667"},
+		{"-2", "/", "3", "-0.66666666666666666667"},
+		{"19.99", "/", "2", "9.995"},
+		{"7.5", "%", "2", "1.5"},
+		{"-7.5", "%", "2", "-1.5"},
+	}
+
+	for _, tt := range tests {
+		left, right := mustParse(t, tt.left), mustParse(t, tt.right)
+
+		var result Decimal
+		var err error
+		switch tt.operator {
+		case "+":
+			result = left.Add(right)
+		case "-":
+			result = left.Sub(right)
+		case "*":
+			result = left.Mul(right)
+		case "/":
+			result, err = left.Quo(right)
+		case "%":
+			result, err = left.Rem(right)
+		}
+		if err != nil {
+			t.Fatalf("%s %s %s failed: %s", tt.left, tt.operator, tt.right, err)
+		}
+
+		if result.String() != tt.expected {
+			t.Errorf("%s %s %s - expected %s, got %s", tt.left, tt.operator, tt.right, tt.expected, result.String())
+		}
+	}
+}
+
+func TestQuoRoundsHalfToEven(t *testing.T) {
+	// 0.5 * 10^-20 lies exactly between two results
+	d := mustParse(t, "0.00000000000000000005")
+	q, err := d.Quo(FromInt64(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.String() != "0.00000000000000000002" {
+		t.Errorf("Expected %s, got %s", "0.00000000000000000002", q.String())
+	}
+}
+
+func TestDivisionByZero(t *testing.T) {
+	if _, err := FromInt64(1).Quo(Decimal{}); err != ErrDivisionByZero {
+		t.Errorf("Expected ErrDivisionByZero, got %v", err)
+	}
+	if _, err := FromInt64(1).Rem(mustParse(t, "0.00")); err != ErrDivisionByZero {
+		t.Errorf("Expected ErrDivisionByZero, got %v", err)
+	}
+}
+
+func TestCmpAndConversions(t *testing.T) {
+	if mustParse(t, "1.50").Cmp(mustParse(t, "1.5")) != 0 {
+		t.Errorf("Expected 1.50 to equal 1.5")
+	}
+	if mustParse(t, "-1").Cmp(mustParse(t, "0.5")) != -1 {
+		t.Errorf("Expected -1 < 0.5")
+	}
+	if !mustParse(t, "2.00").IsInteger() || mustParse(t, "2.01").IsInteger() {
+		t.Errorf("IsInteger wrong")
+	}
+	if mustParse(t, "-2.99").Int().Cmp(big.NewInt(-2)) != 0 {
+		t.Errorf("Expected -2.99 to truncate to -2, got %s", mustParse(t, "-2.99").Int())
+	}
+	if mustParse(t, "2.500").Normalize().String() != "2.5" {
+		t.Errorf("Expected 2.5, got %s", mustParse(t, "2.500").Normalize())
+	}
+	if f, _ := FromFloat(0.1); f.String() != "0.1" {
+		t.Errorf("Expected 0.1, got %s", f.String())
+	}
+	if mustParse(t, "0.25").Float64() != 0.25 {
+		t.Errorf("Expected 0.25, got %g", mustParse(t, "0.25").Float64())
+	}
+}
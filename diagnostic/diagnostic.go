@@ -0,0 +1,57 @@
+package diagnostic
+
+import (
+	"fmt"
+
+	"github.com/chaitanya-Uike/lemon/token"
+)
+
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Error:
+		return "error"
+	case Warning:
+		return "warning"
+	default:
+		return fmt.Sprintf("severity(%d)", int(s))
+	}
+}
+
+// Span is the half-open source range [Start, End).
+type Span struct {
+	Start token.Position
+	End   token.Position
+}
+
+func SpanOf(tok token.Token) Span {
+	return Span{Start: tok.Pos, End: tok.End}
+}
+
+// Fix is a suggested edit, Replacement replaces the text covered by Span.
+// An empty span is an insertion.
+type Fix struct {
+	Message     string
+	Span        Span
+	Replacement string
+}
+
+type Diagnostic struct {
+	Severity Severity
+	Code     string
+	Span     Span
+	Message  string
+	Notes    []string
+	Fix      *Fix
+}
+
+// Error formats the diagnostic on a single line, prefixed by its position.
+func (d *Diagnostic) Error() string {
+	return d.Span.Start.String() + ": " + d.Message
+}
@@ -0,0 +1,139 @@
+package diagnostic
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	colorReset  = "\033[0m"
+	colorBold   = "\033[1m"
+	colorRed    = "\033[1;31m"
+	colorYellow = "\033[1;33m"
+	colorBlue   = "\033[1;34m"
+	colorGreen  = "\033[1;32m"
+)
+
+// Renderer prints diagnostics together with the source line they point at,
+// the offending span is underlined with carets.
+type Renderer struct {
+	Source   string
+	Filename string
+	Color    bool
+}
+
+func NewRenderer(source, filename string, color bool) *Renderer {
+	return &Renderer{Source: source, Filename: filename, Color: color}
+}
+
+// IsTerminal reports whether f is attached to a terminal, used to decide
+// whether output should be colourised.
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+func (r *Renderer) Render(w io.Writer, d *Diagnostic) {
+	severityColor := colorRed
+	if d.Severity == Warning {
+		severityColor = colorYellow
+	}
+
+	header := d.Severity.String()
+	if d.Code != "" {
+		header += "[" + d.Code + "]"
+	}
+	fmt.Fprintf(w, "%s: %s\n", r.paint(severityColor, header), r.paint(colorBold, d.Message))
+
+	start := d.Span.Start
+	location := start.String()
+	if r.Filename != "" {
+		location = r.Filename + ":" + location
+	}
+
+	lineNo := strconv.Itoa(start.Line)
+	gutter := strings.Repeat(" ", len(lineNo))
+	fmt.Fprintf(w, "%s%s %s\n", gutter, r.paint(colorBlue, "-->"), location)
+
+	line, ok := r.line(start.Line)
+	if ok {
+		bar := r.paint(colorBlue, "|")
+		fmt.Fprintf(w, "%s %s\n", gutter, bar)
+		fmt.Fprintf(w, "%s %s %s\n", r.paint(colorBlue, lineNo), bar, line)
+		fmt.Fprintf(w, "%s %s %s\n", gutter, bar, r.paint(severityColor, underline(line, d.Span)))
+	}
+
+	for _, note := range d.Notes {
+		fmt.Fprintf(w, "%s %s %s\n", gutter, r.paint(colorBlue, "="), "note: "+note)
+	}
+	if d.Fix != nil {
+		help := d.Fix.Message
+		if help == "" {
+			help = fmt.Sprintf("replace with %q", d.Fix.Replacement)
+		}
+		fmt.Fprintf(w, "%s %s %s\n", gutter, r.paint(colorBlue, "="), r.paint(colorGreen, "help: ")+help)
+	}
+}
+
+func (r *Renderer) RenderAll(w io.Writer, diagnostics []*Diagnostic) {
+	for _, d := range diagnostics {
+		r.Render(w, d)
+		fmt.Fprintln(w)
+	}
+}
+
+func (r *Renderer) line(n int) (string, bool) {
+	lines := strings.Split(r.Source, "\n")
+	if n < 1 || n > len(lines) {
+		return "", false
+	}
+	return strings.TrimRight(lines[n-1], "\r"), true
+}
+
+// underline builds the caret line for span below line, copying tabs from the
+// source so the carets stay aligned. Columns count bytes, the caret line gets
+// one character per rune. Spans running past the end of the line are cut off
+// there, empty spans still get a single caret.
+func underline(line string, span Span) string {
+	from := max(span.Start.Column-1, 0)
+	to := from + 1
+	if span.End.Line == span.Start.Line && span.End.Column-1 > from {
+		to = span.End.Column - 1
+	} else if span.End.Line > span.Start.Line {
+		to = max(len(line), from+1)
+	}
+
+	var out strings.Builder
+	for i, r := range line[:min(from, len(line))] {
+		if i+utf8.RuneLen(r) > from {
+			break
+		}
+		if r == '\t' {
+			out.WriteByte('\t')
+		} else {
+			out.WriteByte(' ')
+		}
+	}
+	out.WriteString(strings.Repeat(" ", max(from-len(line), 0)))
+
+	width := to - from
+	if from < len(line) {
+		width = max(utf8.RuneCountInString(line[from:min(to, len(line))])+max(to-len(line), 0), 1)
+	}
+	out.WriteString(strings.Repeat("^", width))
+	return out.String()
+}
+
+func (r *Renderer) paint(color, s string) string {
+	if !r.Color {
+		return s
+	}
+	return color + s + colorReset
+}
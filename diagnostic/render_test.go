@@ -0,0 +1,108 @@
+package diagnostic
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/chaitanya-Uike/lemon/token"
+)
+
+func TestRender(t *testing.T) {
+	src := "x := 1\nfunc f() {\n\treturn x +* 2\n}"
+
+	d := &Diagnostic{
+		Severity: Error,
+		Code:     "P002",
+		Span: Span{
+			Start: token.Position{Offset: 30, Line: 3, Column: 12},
+			End:   token.Position{Offset: 31, Line: 3, Column: 13},
+		},
+		Message: "unexpected *",
+		Notes:   []string{"operators need an operand on both sides"},
+		Fix: &Fix{
+			Message:     `remove "*"`,
+			Span:        Span{},
+			Replacement: "",
+		},
+	}
+
+	var out bytes.Buffer
+	NewRenderer(src, "main.lm", false).Render(&out, d)
+
+	expected := strings.Join([]string{
+		"error[P002]: unexpected *",
+		" --> main.lm:3:12",
+		"  |",
+		"3 | \treturn x +* 2",
+		"  | \t          ^",
+		"  = note: operators need an operand on both sides",
+		`  = help: remove "*"`,
+		"",
+	}, "\n")
+
+	if out.String() != expected {
+		t.Fatalf("Unexpected render output.\nexpected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+func TestRenderSpans(t *testing.T) {
+	tests := []struct {
+		line     string
+		span     Span
+		expected string
+	}{
+		{
+			"foo(bar, baz)",
+			Span{Start: token.Position{Line: 1, Column: 5}, End: token.Position{Line: 1, Column: 8}},
+			"    ^^^",
+		},
+		{
+			"foo",
+			Span{Start: token.Position{Line: 1, Column: 4}, End: token.Position{Line: 1, Column: 4}},
+			"   ^",
+		},
+		{
+			"if x {",
+			Span{Start: token.Position{Line: 1, Column: 6}, End: token.Position{Line: 3, Column: 2}},
+			"     ^",
+		},
+		{
+			"größe := 変数 +",
+			Span{Start: token.Position{Line: 1, Column: 12}, End: token.Position{Line: 1, Column: 18}},
+			"         ^^",
+		},
+		{
+			"\tx := \"ä\" $",
+			Span{Start: token.Position{Line: 1, Column: 12}, End: token.Position{Line: 1, Column: 13}},
+			"\t         ^",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := underline(tt.line, tt.span); got != tt.expected {
+			t.Errorf("underline(%q) - expected %q, got %q", tt.line, tt.expected, got)
+		}
+	}
+}
+
+func TestRenderColor(t *testing.T) {
+	d := &Diagnostic{
+		Severity: Warning,
+		Span:     Span{Start: token.Position{Line: 1, Column: 1}, End: token.Position{Line: 1, Column: 2}},
+		Message:  "unused",
+	}
+
+	var out bytes.Buffer
+	NewRenderer("x", "", true).Render(&out, d)
+
+	if !strings.HasPrefix(out.String(), colorYellow+"warning"+colorReset) {
+		t.Fatalf("Expected colourised warning header, got %q", out.String())
+	}
+
+	out.Reset()
+	NewRenderer("x", "", false).Render(&out, d)
+	if strings.Contains(out.String(), "\033[") {
+		t.Fatalf("Expected no escape codes without color, got %q", out.String())
+	}
+}
@@ -0,0 +1,150 @@
+package evaluator
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"unicode/utf8"
+
+	"github.com/chaitanya-Uike/lemon/decimal"
+	"github.com/chaitanya-Uike/lemon/object"
+)
+
+var builtins = map[string]*object.Builtin{
+	"int": {
+		Name: "int",
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			switch arg := args[0].(type) {
+			case *object.Integer, *object.BigInteger:
+				return arg
+			case *object.Float:
+				if math.IsNaN(arg.Value) || math.IsInf(arg.Value, 0) {
+					return newError("cannot convert %s to INTEGER", arg.Inspect())
+				}
+				i, _ := big.NewFloat(arg.Value).Int(nil)
+				return object.NewInteger(i)
+			case *object.Decimal:
+				return object.NewInteger(arg.Value.Int())
+			default:
+				return newError("argument to `int` not supported, got %s", arg.Type())
+			}
+		},
+	},
+	"float": {
+		Name: "float",
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			switch arg := args[0].(type) {
+			case *object.Integer, *object.BigInteger, *object.Float, *object.Decimal:
+				return toFloat(arg)
+			default:
+				return newError("argument to `float` not supported, got %s", arg.Type())
+			}
+		},
+	},
+	"decimal": {
+		Name: "decimal",
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			switch arg := args[0].(type) {
+			case *object.Integer, *object.BigInteger, *object.Decimal:
+				return &object.Decimal{Value: toDecimal(arg)}
+			case *object.Float:
+				d, err := decimal.FromFloat(arg.Value)
+				if err != nil {
+					return newError("cannot convert %s to DECIMAL", arg.Inspect())
+				}
+				return &object.Decimal{Value: d}
+			case *object.String:
+				d, err := decimal.Parse(arg.Value)
+				if err != nil {
+					return newError("could not parse %q as decimal", arg.Value)
+				}
+				return &object.Decimal{Value: d}
+			default:
+				return newError("argument to `decimal` not supported, got %s", arg.Type())
+			}
+		},
+	},
+	"str": {
+		Name: "str",
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			if str, ok := args[0].(*object.String); ok {
+				return str
+			}
+			return &object.String{Value: args[0].Inspect()}
+		},
+	},
+	"len": {
+		Name: "len",
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			switch arg := args[0].(type) {
+			case *object.String:
+				return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
+			case *object.Array:
+				return &object.Integer{Value: int64(len(arg.Elements))}
+			case *object.Hash:
+				return &object.Integer{Value: int64(arg.Len())}
+			default:
+				return newError("argument to `len` not supported, got %s", arg.Type())
+			}
+		},
+	},
+	"push": {
+		Name: "push",
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) < 1 {
+				return newError("wrong number of arguments. got=%d, want at least 1", len(args))
+			}
+			arr, ok := args[0].(*object.Array)
+			if !ok {
+				return newError("argument to `push` must be ARRAY, got %s", args[0].Type())
+			}
+
+			elements := make([]object.Object, 0, len(arr.Elements)+len(args)-1)
+			elements = append(elements, arr.Elements...)
+			elements = append(elements, args[1:]...)
+			return &object.Array{Elements: elements}
+		},
+	},
+	"keys": {
+		Name: "keys",
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+			hash, ok := args[0].(*object.Hash)
+			if !ok {
+				return newError("argument to `keys` must be HASH, got %s", args[0].Type())
+			}
+
+			keys := []object.Object{}
+			for _, pair := range hash.Pairs() {
+				keys = append(keys, pair.Key)
+			}
+			return &object.Array{Elements: keys}
+		},
+	},
+	"puts": {
+		Name: "puts",
+		Fn: func(args ...object.Object) object.Object {
+			for _, arg := range args {
+				fmt.Println(arg.Inspect())
+			}
+			return NULL
+		},
+	},
+}
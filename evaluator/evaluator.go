@@ -0,0 +1,894 @@
+package evaluator
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/chaitanya-Uike/lemon/ast"
+	"github.com/chaitanya-Uike/lemon/object"
+	"github.com/chaitanya-Uike/lemon/token"
+)
+
+var (
+	NULL  = &object.Null{}
+	TRUE  = &object.Boolean{Value: true}
+	FALSE = &object.Boolean{Value: false}
+)
+
+func Eval(node ast.Node, env *object.Environment) object.Object {
+	switch node := node.(type) {
+	case *ast.Program:
+		return evalProgram(node, env)
+	case *ast.ExpressionStatement:
+		return Eval(node.Expression, env)
+	case *ast.BlockStatement:
+		return evalBlockStatement(node, object.NewEnclosedEnvironment(env))
+	case *ast.IfStatement:
+		return evalIfStatement(node, env)
+	case *ast.WhileStatement:
+		return evalWhileStatement(node, "", env)
+	case *ast.ForInStatement:
+		return evalForInStatement(node, "", env)
+	case *ast.ForStatement:
+		return evalForStatement(node, "", env)
+	case *ast.LabeledStatement:
+		return evalLabeledStatement(node, env)
+	case *ast.BranchStatement:
+		var label string
+		if node.Label != nil {
+			label = node.Label.Value
+		}
+		if node.Token.Type == token.BREAK {
+			return &object.Break{Label: label}
+		}
+		return &object.Continue{Label: label}
+	case *ast.ReturnStatement:
+		if node.ReturnValue == nil {
+			return &object.ReturnValue{Value: NULL}
+		}
+		val := Eval(node.ReturnValue, env)
+		if isError(val) {
+			return val
+		}
+		return &object.ReturnValue{Value: val}
+	case *ast.DeclareStatement:
+		val := Eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		if !env.Declare(node.Name.Value, val) {
+			return newError("identifier already declared: %s", node.Name.Value)
+		}
+		return nil
+	case *ast.AssignStatement:
+		return evalAssignStatement(node, env)
+	case *ast.FunctionStatement:
+		fn := &object.Function{Parameters: node.Function.Parameters, Body: node.Function.Body, Env: env}
+		if !env.Declare(node.Name.Value, fn) {
+			return newError("identifier already declared: %s", node.Name.Value)
+		}
+		return nil
+
+	case *ast.IdentifierLiteral:
+		return evalIdentifier(node, env)
+	case *ast.IntegerLiteral:
+		if node.Big != nil {
+			return &object.BigInteger{Value: node.Big}
+		}
+		return &object.Integer{Value: node.Value}
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
+	case *ast.DecimalLiteral:
+		return &object.Decimal{Value: node.Value}
+	case *ast.StringLiteral:
+		return &object.String{Value: node.Value}
+	case *ast.InterpolatedString:
+		return evalInterpolatedString(node, env)
+	case *ast.BooleanLiteral:
+		return nativeBoolToBooleanObject(node.Value)
+	case *ast.FunctionLiteral:
+		return &object.Function{Parameters: node.Parameters, Body: node.Body, Env: env}
+	case *ast.CallExpression:
+		function := Eval(node.Function, env)
+		if isError(function) {
+			return function
+		}
+		args := evalExpressions(node.Arguments, env)
+		if len(args) == 1 && isError(args[0]) {
+			return args[0]
+		}
+		return applyFunction(function, args)
+	case *ast.ArrayLiteral:
+		elements := evalExpressions(node.Elements, env)
+		if len(elements) == 1 && isError(elements[0]) {
+			return elements[0]
+		}
+		return &object.Array{Elements: elements}
+	case *ast.HashLiteral:
+		return evalHashLiteral(node, env)
+	case *ast.IndexExpression:
+		left := Eval(node.Left, env)
+		if isError(left) {
+			return left
+		}
+		index := Eval(node.Index, env)
+		if isError(index) {
+			return index
+		}
+		return evalIndexExpression(left, index)
+	case *ast.SliceExpression:
+		return evalSliceExpression(node, env)
+	case *ast.PrefixExpression:
+		right := Eval(node.Expression, env)
+		if isError(right) {
+			return right
+		}
+		return evalPrefixExpression(node.Operator, right)
+	case *ast.InfixExpression:
+		left := Eval(node.Left, env)
+		if isError(left) {
+			return left
+		}
+		if node.Operator == "&&" || node.Operator == "||" {
+			return evalLogicalExpression(node, left, env)
+		}
+		right := Eval(node.Right, env)
+		if isError(right) {
+			return right
+		}
+		return evalInfixExpression(node.Operator, left, right)
+	}
+
+	return newError("unknown node: %T", node)
+}
+
+func evalProgram(program *ast.Program, env *object.Environment) object.Object {
+	var result object.Object = NULL
+
+	for _, stmt := range program.Statements {
+		result = Eval(stmt, env)
+
+		switch result := result.(type) {
+		case *object.ReturnValue:
+			return result.Value
+		case *object.Error:
+			return result
+		case *object.Break, *object.Continue:
+			return newError("%s outside of a loop", result.Inspect())
+		}
+	}
+
+	return result
+}
+
+func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
+	var result object.Object = NULL
+
+	for _, stmt := range block.Statements {
+		result = Eval(stmt, env)
+
+		if result != nil {
+			switch result.Type() {
+			case object.RETURN_VALUE_OBJ, object.ERROR_OBJ, object.BREAK_OBJ, object.CONTINUE_OBJ:
+				return result
+			}
+		}
+	}
+
+	return result
+}
+
+func evalIfStatement(is *ast.IfStatement, env *object.Environment) object.Object {
+	condition := Eval(is.Condition, env)
+	if isError(condition) {
+		return condition
+	}
+
+	if isTruthy(condition) {
+		return Eval(is.Consequence, env)
+	} else if is.Alternate != nil {
+		return Eval(is.Alternate, env)
+	}
+	return NULL
+}
+
+func evalLabeledStatement(ls *ast.LabeledStatement, env *object.Environment) object.Object {
+	switch loop := ls.Statement.(type) {
+	case *ast.WhileStatement:
+		return evalWhileStatement(loop, ls.Label.Value, env)
+	case *ast.ForInStatement:
+		return evalForInStatement(loop, ls.Label.Value, env)
+	case *ast.ForStatement:
+		return evalForStatement(loop, ls.Label.Value, env)
+	default:
+		return Eval(ls.Statement, env)
+	}
+}
+
+func evalWhileStatement(ws *ast.WhileStatement, label string, env *object.Environment) object.Object {
+	for {
+		condition := Eval(ws.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+		if !isTruthy(condition) {
+			return nil
+		}
+
+		if stop, result := loopControl(Eval(ws.Body, env), label); stop {
+			return result
+		}
+	}
+}
+
+func evalForInStatement(fs *ast.ForInStatement, label string, env *object.Environment) object.Object {
+	iterable := Eval(fs.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+
+	items, err := iterationItems(iterable)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		// every iteration gets a fresh binding, closures created in the body
+		// keep the value of their own iteration
+		iterEnv := object.NewEnclosedEnvironment(env)
+		iterEnv.Set(fs.Variable.Value, item)
+
+		if stop, result := loopControl(evalBlockStatement(fs.Body, iterEnv), label); stop {
+			return result
+		}
+	}
+
+	return nil
+}
+
+func evalForStatement(fs *ast.ForStatement, label string, env *object.Environment) object.Object {
+	loopEnv := object.NewEnclosedEnvironment(env)
+
+	if fs.Init != nil {
+		if init := Eval(fs.Init, loopEnv); isError(init) {
+			return init
+		}
+	}
+
+	for {
+		if fs.Condition != nil {
+			condition := Eval(fs.Condition, loopEnv)
+			if isError(condition) {
+				return condition
+			}
+			if !isTruthy(condition) {
+				return nil
+			}
+		}
+
+		if stop, result := loopControl(Eval(fs.Body, loopEnv), label); stop {
+			return result
+		}
+
+		if fs.Post != nil {
+			if post := Eval(fs.Post, loopEnv); isError(post) {
+				return post
+			}
+		}
+	}
+}
+
+// iterationItems returns what a for-in loop over iterable visits: the
+// elements of an array, the characters of a string or the keys of a hash.
+// They are collected up front, so changes made by the loop body don't affect
+// the iteration.
+func iterationItems(iterable object.Object) ([]object.Object, *object.Error) {
+	var items []object.Object
+	switch iterable := iterable.(type) {
+	case *object.Array:
+		items = slices.Clone(iterable.Elements)
+	case *object.String:
+		for _, r := range iterable.Value {
+			items = append(items, &object.String{Value: string(r)})
+		}
+	case *object.Hash:
+		for _, pair := range iterable.Pairs() {
+			items = append(items, pair.Key)
+		}
+	default:
+		return nil, newError("cannot iterate over %s", iterable.Type())
+	}
+	return items, nil
+}
+
+// loopControl inspects the result of one iteration of a loop labelled label
+// and reports whether the loop has to stop, and with which result. Returns,
+// errors and branches aimed at an outer loop are passed on.
+func loopControl(result object.Object, label string) (bool, object.Object) {
+	switch result := result.(type) {
+	case *object.Break:
+		if result.Label == "" || result.Label == label {
+			return true, nil
+		}
+		return true, result
+	case *object.Continue:
+		if result.Label == "" || result.Label == label {
+			return false, nil
+		}
+		return true, result
+	case *object.ReturnValue, *object.Error:
+		return true, result
+	}
+	return false, nil
+}
+
+func evalIdentifier(node *ast.IdentifierLiteral, env *object.Environment) object.Object {
+	if val, ok := env.Get(node.Value); ok {
+		return val
+	}
+	if builtin, ok := builtins[node.Value]; ok {
+		return builtin
+	}
+	return newError("identifier not found: %s", node.Value)
+}
+
+func evalPrefixExpression(operator string, right object.Object) object.Object {
+	switch operator {
+	case "!":
+		return evalBangOperatorExpression(right)
+	case "-":
+		return evalMinusPrefixOperatorExpression(right)
+	case "~":
+		switch right := right.(type) {
+		case *object.Integer:
+			return &object.Integer{Value: ^right.Value}
+		case *object.BigInteger:
+			return object.NewInteger(new(big.Int).Not(right.Value))
+		}
+		return newError("unknown operator: ~%s", right.Type())
+	default:
+		return newError("unknown operator: %s%s", operator, right.Type())
+	}
+}
+
+func evalBangOperatorExpression(right object.Object) object.Object {
+	return nativeBoolToBooleanObject(!isTruthy(right))
+}
+
+func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
+	switch right := right.(type) {
+	case *object.Integer:
+		if right.Value == math.MinInt64 {
+			return object.NewInteger(new(big.Int).Neg(big.NewInt(right.Value)))
+		}
+		return &object.Integer{Value: -right.Value}
+	case *object.BigInteger:
+		return object.NewInteger(new(big.Int).Neg(right.Value))
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	case *object.Decimal:
+		return &object.Decimal{Value: right.Value.Neg()}
+	default:
+		return newError("unknown operator: -%s", right.Type())
+	}
+}
+
+func evalInfixExpression(operator string, left, right object.Object) object.Object {
+	switch {
+	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
+		return evalIntegerInfixExpression(operator, left, right)
+	case isNumeric(left) && isNumeric(right) && isBitwiseOperator(operator):
+		// no promotion for bitwise operators, they are only defined on integers
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	case isExact(left) && isExact(right):
+		return evalDecimalInfixExpression(operator, left, right)
+	case left.Type() == object.DECIMAL_OBJ || right.Type() == object.DECIMAL_OBJ:
+		// exact and inexact numbers only mix through decimal() or float()
+		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+	case isNumeric(left) && isNumeric(right):
+		return evalFloatInfixExpression(operator, toFloat(left), toFloat(right))
+	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
+		return evalStringInfixExpression(operator, left, right)
+	case left.Type() == object.ARRAY_OBJ && right.Type() == object.ARRAY_OBJ && operator == "+":
+		leftElements := left.(*object.Array).Elements
+		rightElements := right.(*object.Array).Elements
+		elements := make([]object.Object, 0, len(leftElements)+len(rightElements))
+		elements = append(elements, leftElements...)
+		elements = append(elements, rightElements...)
+		return &object.Array{Elements: elements}
+	case left.Type() != right.Type():
+		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+	case operator == "==":
+		return nativeBoolToBooleanObject(left == right)
+	case operator == "!=":
+		return nativeBoolToBooleanObject(left != right)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// evalLogicalExpression only evaluates the right operand of && and || when
+// the left one doesn't already decide the result.
+func evalLogicalExpression(node *ast.InfixExpression, left object.Object, env *object.Environment) object.Object {
+	if isTruthy(left) == (node.Operator == "||") {
+		return nativeBoolToBooleanObject(isTruthy(left))
+	}
+
+	right := Eval(node.Right, env)
+	if isError(right) {
+		return right
+	}
+	return nativeBoolToBooleanObject(isTruthy(right))
+}
+
+// evalIntegerInfixExpression works on int64s and switches to big integers
+// whenever a result would overflow.
+func evalIntegerInfixExpression(operator string, left, right object.Object) object.Object {
+	leftInt, ok := left.(*object.Integer)
+	rightInt, ok2 := right.(*object.Integer)
+	if !ok || !ok2 {
+		return evalBigIntegerInfixExpression(operator, toBigInt(left), toBigInt(right))
+	}
+	leftVal := leftInt.Value
+	rightVal := rightInt.Value
+
+	switch operator {
+	case "+":
+		if sum := leftVal + rightVal; (sum > leftVal) == (rightVal > 0) {
+			return &object.Integer{Value: sum}
+		}
+	case "-":
+		if diff := leftVal - rightVal; (diff < leftVal) == (rightVal > 0) {
+			return &object.Integer{Value: diff}
+		}
+	case "*":
+		if leftVal == 0 || rightVal == 0 {
+			return &object.Integer{Value: 0}
+		}
+		product := leftVal * rightVal
+		if product/rightVal == leftVal && !(leftVal == -1 && rightVal == math.MinInt64) && !(rightVal == -1 && leftVal == math.MinInt64) {
+			return &object.Integer{Value: product}
+		}
+	case "/":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
+		if leftVal != math.MinInt64 || rightVal != -1 {
+			return &object.Integer{Value: leftVal / rightVal}
+		}
+	case "%":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
+		return &object.Integer{Value: leftVal % rightVal}
+	case "&":
+		return &object.Integer{Value: leftVal & rightVal}
+	case "|":
+		return &object.Integer{Value: leftVal | rightVal}
+	case "^":
+		return &object.Integer{Value: leftVal ^ rightVal}
+	case "<<":
+		if rightVal < 0 {
+			return newError("negative shift count: %d", rightVal)
+		}
+		if shifted := leftVal << rightVal; rightVal < 64 && shifted>>rightVal == leftVal {
+			return &object.Integer{Value: shifted}
+		}
+	case ">>":
+		if rightVal < 0 {
+			return newError("negative shift count: %d", rightVal)
+		}
+		return &object.Integer{Value: leftVal >> rightVal}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+
+	return evalBigIntegerInfixExpression(operator, big.NewInt(leftVal), big.NewInt(rightVal))
+}
+
+func isBitwiseOperator(operator string) bool {
+	switch operator {
+	case "&", "|", "^", "<<", ">>":
+		return true
+	}
+	return false
+}
+
+func evalFloatInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := left.(*object.Float).Value
+	rightVal := right.(*object.Float).Value
+
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftVal + rightVal}
+	case "-":
+		return &object.Float{Value: leftVal - rightVal}
+	case "*":
+		return &object.Float{Value: leftVal * rightVal}
+	case "/":
+		return &object.Float{Value: leftVal / rightVal}
+	case "%":
+		return &object.Float{Value: math.Mod(leftVal, rightVal)}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := left.(*object.String).Value
+	rightVal := right.(*object.String).Value
+
+	switch operator {
+	case "+":
+		return &object.String{Value: leftVal + rightVal}
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func evalInterpolatedString(is *ast.InterpolatedString, env *object.Environment) object.Object {
+	var out strings.Builder
+
+	for _, part := range is.Parts {
+		val := Eval(part, env)
+		if isError(val) {
+			return val
+		}
+		out.WriteString(val.Inspect())
+	}
+
+	return &object.String{Value: out.String()}
+}
+
+func isNumeric(obj object.Object) bool {
+	switch obj.Type() {
+	case object.INTEGER_OBJ, object.FLOAT_OBJ, object.DECIMAL_OBJ:
+		return true
+	}
+	return false
+}
+
+func toFloat(obj object.Object) *object.Float {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return &object.Float{Value: float64(obj.Value)}
+	case *object.BigInteger:
+		f, _ := new(big.Float).SetInt(obj.Value).Float64()
+		return &object.Float{Value: f}
+	case *object.Float:
+		return obj
+	case *object.Decimal:
+		return &object.Float{Value: obj.Value.Float64()}
+	}
+	return nil
+}
+
+func evalAssignStatement(as *ast.AssignStatement, env *object.Environment) object.Object {
+	switch target := as.Target.(type) {
+	case *ast.IdentifierLiteral:
+		val := Eval(as.Value, env)
+		if isError(val) {
+			return val
+		}
+		if !env.Assign(target.Value, val) {
+			return newError("assignment to undeclared identifier: %s", target.Value)
+		}
+		return nil
+	case *ast.IndexExpression:
+		left := Eval(target.Left, env)
+		if isError(left) {
+			return left
+		}
+		index := Eval(target.Index, env)
+		if isError(index) {
+			return index
+		}
+		val := Eval(as.Value, env)
+		if isError(val) {
+			return val
+		}
+		if err := evalIndexAssignment(left, index, val); err != nil {
+			return err
+		}
+		return nil
+	default:
+		return newError("cannot assign to %s", as.Target)
+	}
+}
+
+func evalIndexAssignment(left, index, val object.Object) object.Object {
+	switch left := left.(type) {
+	case *object.Array:
+		if index.Type() != object.INTEGER_OBJ {
+			return newError("index operator not supported: %s[%s]", left.Type(), index.Type())
+		}
+		i, ok := resolveIndex(index, len(left.Elements))
+		if !ok {
+			return newError("index out of range: %s (length %d)", index.Inspect(), len(left.Elements))
+		}
+		left.Elements[i] = val
+		return nil
+	case *object.Hash:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", index.Type())
+		}
+		left.Set(key, val)
+		return nil
+	default:
+		return newError("index assignment not supported: %s", left.Type())
+	}
+}
+
+func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
+	hash := object.NewHash()
+
+	for _, pair := range node.Pairs {
+		key := Eval(pair.Key, env)
+		if isError(key) {
+			return key
+		}
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", key.Type())
+		}
+
+		value := Eval(pair.Value, env)
+		if isError(value) {
+			return value
+		}
+
+		hash.Set(hashKey, value)
+	}
+
+	return hash
+}
+
+func evalIndexExpression(left, index object.Object) object.Object {
+	switch {
+	case left.Type() == object.HASH_OBJ:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", index.Type())
+		}
+		if val, ok := left.(*object.Hash).Get(key); ok {
+			return val
+		}
+		return NULL
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		elements := left.(*object.Array).Elements
+		i, ok := resolveIndex(index, len(elements))
+		if !ok {
+			return newError("index out of range: %s (length %d)", index.Inspect(), len(elements))
+		}
+		return elements[i]
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		runes := []rune(left.(*object.String).Value)
+		i, ok := resolveIndex(index, len(runes))
+		if !ok {
+			return newError("index out of range: %s (length %d)", index.Inspect(), len(runes))
+		}
+		return &object.String{Value: string(runes[i])}
+	default:
+		return newError("index operator not supported: %s[%s]", left.Type(), index.Type())
+	}
+}
+
+// resolveIndex maps a possibly negative integer index, counting from the
+// end, onto [0, length). Big integers are always out of range.
+func resolveIndex(obj object.Object, length int) (int, bool) {
+	integer, ok := obj.(*object.Integer)
+	if !ok {
+		return 0, false
+	}
+	index := integer.Value
+	if index < 0 {
+		index += int64(length)
+	}
+	if index < 0 || index >= int64(length) {
+		return 0, false
+	}
+	return int(index), true
+}
+
+func evalSliceExpression(se *ast.SliceExpression, env *object.Environment) object.Object {
+	left := Eval(se.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	var low, high object.Object
+	if se.Low != nil {
+		low = Eval(se.Low, env)
+		if isError(low) {
+			return low
+		}
+	}
+	if se.High != nil {
+		high = Eval(se.High, env)
+		if isError(high) {
+			return high
+		}
+	}
+
+	return evalSlice(left, low, high)
+}
+
+// evalSlice slices an array or a string, a nil bound was left out.
+func evalSlice(left, low, high object.Object) object.Object {
+	var length int
+	switch left := left.(type) {
+	case *object.Array:
+		length = len(left.Elements)
+	case *object.String:
+		length = utf8.RuneCountInString(left.Value)
+	default:
+		return newError("slice operator not supported: %s", left.Type())
+	}
+
+	lowIndex, highIndex := 0, length
+	if low != nil {
+		bound := sliceBound(low, length)
+		if isError(bound) {
+			return bound
+		}
+		lowIndex = int(bound.(*object.Integer).Value)
+	}
+	if high != nil {
+		bound := sliceBound(high, length)
+		if isError(bound) {
+			return bound
+		}
+		highIndex = int(bound.(*object.Integer).Value)
+	}
+	if lowIndex > highIndex {
+		return newError("invalid slice indices: %d > %d", lowIndex, highIndex)
+	}
+
+	switch left := left.(type) {
+	case *object.Array:
+		elements := make([]object.Object, highIndex-lowIndex)
+		copy(elements, left.Elements[lowIndex:highIndex])
+		return &object.Array{Elements: elements}
+	default:
+		runes := []rune(left.(*object.String).Value)
+		return &object.String{Value: string(runes[lowIndex:highIndex])}
+	}
+}
+
+// sliceBound checks a slice bound, negative bounds count from the end and a
+// bound may equal length.
+func sliceBound(val object.Object, length int) object.Object {
+	if val.Type() != object.INTEGER_OBJ {
+		return newError("slice index must be INTEGER, got %s", val.Type())
+	}
+	integer, ok := val.(*object.Integer)
+	if !ok {
+		return newError("slice bounds out of range: %s (length %d)", val.Inspect(), length)
+	}
+
+	bound := integer.Value
+	if bound < 0 {
+		bound += int64(length)
+	}
+	if bound < 0 || bound > int64(length) {
+		return newError("slice bounds out of range: %d (length %d)", integer.Value, length)
+	}
+	return &object.Integer{Value: bound}
+}
+
+func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
+	var result []object.Object
+
+	for _, e := range exps {
+		evaluated := Eval(e, env)
+		if isError(evaluated) {
+			return []object.Object{evaluated}
+		}
+		result = append(result, evaluated)
+	}
+
+	return result
+}
+
+func applyFunction(fn object.Object, args []object.Object) object.Object {
+	switch fn := fn.(type) {
+	case *object.Function:
+		if len(args) != len(fn.Parameters) {
+			return newError("wrong number of arguments. got=%d, want=%d", len(args), len(fn.Parameters))
+		}
+		extendedEnv := extendFunctionEnv(fn, args)
+		// the body runs directly in the call scope, parameters are locals of
+		// the body and cannot be redeclared by it
+		evaluated := evalBlockStatement(fn.Body, extendedEnv)
+		switch evaluated.(type) {
+		case *object.Break, *object.Continue:
+			return newError("%s outside of a loop", evaluated.Inspect())
+		}
+		return unwrapReturnValue(evaluated)
+	case *object.Builtin:
+		return fn.Fn(args...)
+	default:
+		return newError("not a function: %s", fn.Type())
+	}
+}
+
+func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
+	env := object.NewEnclosedEnvironment(fn.Env)
+
+	for i, param := range fn.Parameters {
+		env.Set(param.Value, args[i])
+	}
+
+	return env
+}
+
+func unwrapReturnValue(obj object.Object) object.Object {
+	if returnValue, ok := obj.(*object.ReturnValue); ok {
+		return returnValue.Value
+	}
+	if obj == nil {
+		return NULL
+	}
+	return obj
+}
+
+func nativeBoolToBooleanObject(input bool) *object.Boolean {
+	if input {
+		return TRUE
+	}
+	return FALSE
+}
+
+func isTruthy(obj object.Object) bool {
+	switch obj {
+	case NULL, FALSE:
+		return false
+	default:
+		return true
+	}
+}
+
+func newError(format string, a ...any) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...)}
+}
+
+func isError(obj object.Object) bool {
+	if obj != nil {
+		return obj.Type() == object.ERROR_OBJ
+	}
+	return false
+}
@@ -0,0 +1,954 @@
+package evaluator
+
+import (
+	"testing"
+
+	"github.com/chaitanya-Uike/lemon/lexer"
+	"github.com/chaitanya-Uike/lemon/object"
+	"github.com/chaitanya-Uike/lemon/parser"
+)
+
+func testEval(t *testing.T, input string) object.Object {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		for _, msg := range p.Errors() {
+			t.Errorf("parser error: %q", msg)
+		}
+		t.FailNow()
+	}
+
+	env := object.NewEnvironment()
+	return Eval(program, env)
+}
+
+func TestEvalIntegerExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"5", 5},
+		{"10", 10},
+		{"-5", -5},
+		{"-10", -10},
+		{"5 + 5 + 5 + 5 - 10", 10},
+		{"2 * 2 * 2 * 2 * 2", 32},
+		{"-50 + 100 + -50", 0},
+		{"5 * 2 + 10", 20},
+		{"5 + 2 * 10", 25},
+		{"20 + 2 * -10", 0},
+		{"50 / 2 * 2 + 10", 60},
+		{"2 * (5 + 10)", 30},
+		{"3 * 3 * 3 + 10", 37},
+		{"3 * (3 * 3) + 10", 37},
+		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"7 / 2", 3},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"2 + 7 % 4 * 2", 8},
+		{"12 & 10", 8},
+		{"12 | 10", 14},
+		{"12 ^ 10", 6},
+		{"~5", -6},
+		{"1 << 10", 1024},
+		{"-16 >> 2", -4},
+		{"1 | 2 & 3", 3},
+		{"(1 | 2) & 3", 3},
+		{"flags := 0; flags = flags | 1 << 3; flags & 8", 8},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"5.5", 5.5},
+		{"-2.5", -2.5},
+		{"1.5 + 2.25", 3.75},
+		{"10.0 / 4.0", 2.5},
+		{"2.0 * (1.5 - 0.5)", 2.0},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		testFloatObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestNumericPromotion(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{"1 + 2", 3},
+		{"1 + 2.5", 3.5},
+		{"2.5 + 1", 3.5},
+		{"3 - 0.5", 2.5},
+		{"2 * 1.5", 3.0},
+		{"7 / 2", 3},
+		{"7 / 2.0", 3.5},
+		{"7.0 / 2", 3.5},
+		{"-(1 + 0.5)", -1.5},
+		{"1 / 0.0 > 1000000", true},
+		{"1 < 1.5", true},
+		{"2 > 1.5", true},
+		{"1 == 1.0", true},
+		{"1 != 1.0", false},
+		{"2.0 == 3", false},
+		{"7.5 % 2", 1.5},
+		{"7 % 2.5", 2.0},
+		{"1 <= 1.0", true},
+		{"2.5 >= 3", false},
+		{"0x10 + 0b1", 17},
+		{"1_000 * .5", 500.0},
+		{"1e3 + 1", 1001.0},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case float64:
+			testFloatObject(t, evaluated, expected)
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestBigIntegers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"9223372036854775807 + 1", "9223372036854775808"},
+		{"-9223372036854775807 - 2", "-9223372036854775809"},
+		{"4294967296 * 4294967296", "18446744073709551616"},
+		{"-9223372036854775807 - 1", "-9223372036854775808"},
+		{"(-9223372036854775807 - 1) / -1", "9223372036854775808"},
+		{"-(-9223372036854775807 - 1)", "9223372036854775808"},
+		{"1 << 64", "18446744073709551616"},
+		{"1 << 63 >> 63", "1"},
+		{"99999999999999999999", "99999999999999999999"},
+		{"0xffff_ffff_ffff_ffff_ff", "4722366482869645213695"},
+		{"99999999999999999999 - 99999999999999999998", "1"},
+		{"99999999999999999999 / 3", "33333333333333333333"},
+		{"99999999999999999999 % 10", "9"},
+		{"~99999999999999999999", "-100000000000000000000"},
+		{"99999999999999999999 & 0xff", "255"},
+		{"99999999999999999999 > 1", "true"},
+		{"99999999999999999999 == 99999999999999999999", "true"},
+		{"1e20 == 100000000000000000000", "true"},
+		{"99999999999999999999 + 0.5", "1e+20"},
+		{"n := 1; for i := 0; i < 25; i = i + 1 { n = n * (i + 1) }; n", "15511210043330985984000000"},
+		{"({100000000000000000000: \"big\"})[1e20]", "big"},
+		{"[1, 2][99999999999999999999]", "ERROR: index out of range: 99999999999999999999 (length 2)"},
+		{"[1, 2][99999999999999999999:]", "ERROR: slice bounds out of range: 99999999999999999999 (length 2)"},
+		{"1 << 99999999999", "ERROR: shift count too large: 99999999999"},
+		{"99999999999999999999 / 0", "ERROR: division by zero"},
+		{"int(1e20)", "100000000000000000000"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s - expected %s, got %s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	// results that fit again are plain integers
+	testIntegerObject(t, testEval(t, "(9223372036854775807 + 1) - 1"), 9223372036854775807)
+}
+
+func TestDecimals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"19.99d", "19.99"},
+		{"0.1d + 0.2d", "0.3"},
+		{"0.1d + 0.2d == 0.3d", "true"},
+		{"19.99d * 3", "59.97"},
+		{"3 * 19.99d", "59.97"},
+		{"100d / 3", "33.33333333333333333333"},
+		{"10.00d / 4", "2.50"},
+		{"7 / 2d", "3.5"},
+		{"7.5d % 2", "1.5"},
+		{"-1.50d", "-1.50"},
+		{"1.5d > 1", "true"},
+		{"2d == 2", "true"},
+		{"1.50d == 1.5d", "true"},
+		{"99999999999999999999 + 0.01d", "99999999999999999999.01"},
+		{"1_000.50d", "1000.50"},
+		{"total := 0d; for p in [19.99d, 5.01d, 0.10d] { total = total + p }; total", "25.10"},
+		{"decimal(0.1) + decimal(\"0.2\")", "0.3"},
+		{"decimal(3)", "3"},
+		{"float(2.5d)", "2.5"},
+		{"int(-2.99d)", "-2"},
+		{"str(1.10d)", "1.10"},
+		{"\"total: ${9.90d + 0.10d}\"", "total: 10.00"},
+		{"({2: \"two\"})[2.00d]", "two"},
+		{"({1.5d: \"x\"})[1.50d]", "x"},
+		{"1.5d + 1.5", "ERROR: type mismatch: DECIMAL + FLOAT"},
+		{"1.5 == 1.5d", "ERROR: type mismatch: FLOAT == DECIMAL"},
+		{"1d / 0", "ERROR: division by zero"},
+		{"1d & 1", "ERROR: unknown operator: DECIMAL & INTEGER"},
+		{"~1d", "ERROR: unknown operator: ~DECIMAL"},
+		{"decimal(\"abc\")", "ERROR: could not parse \"abc\" as decimal"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s - expected %s, got %s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestStringExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`"Hello World!"`, "Hello World!"},
+		{`"Hello" + " " + "World!"`, "Hello World!"},
+		{`name := "lemon"; "hi ${name}!"`, "hi lemon!"},
+		{`"${1 + 2} and ${2.5 * 2} and ${1 < 2}"`, "3 and 5.0 and true"},
+		{`x := "in"; "out ${"mid ${x}"} out"`, "out mid in out"},
+		{`"a" == "a"`, true},
+		{`"a" != "b"`, true},
+		{`"a" + "b" == "ab"`, true},
+		{`len("")`, 0},
+		{`len("four")`, 4},
+		{`len("\u{e9}t\u{e9}")`, 3},
+		{`größe := "変数"; größe + "!"`, "変数!"},
+		{`s := "héllo"; s[1]`, "é"},
+		{`len("日本語")`, 3},
+		{`str(12) + str(true)`, "12true"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		switch expected := tt.expected.(type) {
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok {
+				t.Fatalf("Expected *object.String, got %T (%+v)", evaluated, evaluated)
+			}
+			if str.Value != expected {
+				t.Errorf("Expected %q, got %q", expected, str.Value)
+			}
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		}
+	}
+}
+
+func TestArrayLiterals(t *testing.T) {
+	evaluated := testEval(t, "[1, 2 * 2, 3 + 3]")
+
+	result, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("Expected *object.Array, got %T (%+v)", evaluated, evaluated)
+	}
+
+	if len(result.Elements) != 3 {
+		t.Fatalf("Expected %d elements, got %d", 3, len(result.Elements))
+	}
+
+	testIntegerObject(t, result.Elements[0], 1)
+	testIntegerObject(t, result.Elements[1], 4)
+	testIntegerObject(t, result.Elements[2], 6)
+}
+
+func TestIndexAndSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{"[1, 2, 3][0]", 1},
+		{"[1, 2, 3][1]", 2},
+		{"[1, 2, 3][2]", 3},
+		{"i := 0; [1][i]", 1},
+		{"[1, 2, 3][1 + 1]", 3},
+		{"xs := [1, 2, 3]; xs[2]", 3},
+		{"xs := [1, 2, 3]; xs[0] + xs[1] + xs[2]", 6},
+		{"[1, 2, 3][-1]", 3},
+		{"[1, 2, 3][-3]", 1},
+		{"[[1, 2], [3, 4]][1][0]", 3},
+		{"[1, 2, 3, 4][1:3]", "[2, 3]"},
+		{"[1, 2, 3, 4][2:]", "[3, 4]"},
+		{"[1, 2, 3, 4][:2]", "[1, 2]"},
+		{"[1, 2, 3, 4][:]", "[1, 2, 3, 4]"},
+		{"[1, 2, 3, 4][-2:]", "[3, 4]"},
+		{"[1, 2, 3, 4][1:-1]", "[2, 3]"},
+		{"[1, 2, 3, 4][2:2]", "[]"},
+		{"[1, 2, 3, 4][4:]", "[]"},
+		{"[1, 2] + [3]", "[1, 2, 3]"},
+		{"len([1, 2, 3])", 3},
+		{"xs := [1]; ys := push(xs, 2, 3); len(xs) * 10 + len(ys)", 13},
+		{`"héllo"[1]`, "é"},
+		{`"héllo"[-1]`, "o"},
+		{`"héllo"[1:4]`, "éll"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if evaluated.Inspect() != expected {
+				t.Errorf("%s - expected %s, got %s", tt.input, expected, evaluated.Inspect())
+			}
+		}
+	}
+}
+
+func TestHashLiterals(t *testing.T) {
+	input := `two := "two"
+	m := {
+		"one": 10 - 9,
+		two: 1 + 1,
+		"thr" + "ee": 6 / 2,
+		4: 4,
+		true: 5,
+		false: 6,
+		2.5: 7,
+	}
+	m`
+
+	evaluated := testEval(t, input)
+	result, ok := evaluated.(*object.Hash)
+	if !ok {
+		t.Fatalf("Expected *object.Hash, got %T (%+v)", evaluated, evaluated)
+	}
+
+	expected := []struct {
+		key   object.Hashable
+		value int64
+	}{
+		{&object.String{Value: "one"}, 1},
+		{&object.String{Value: "two"}, 2},
+		{&object.String{Value: "three"}, 3},
+		{&object.Integer{Value: 4}, 4},
+		{TRUE, 5},
+		{FALSE, 6},
+		{&object.Float{Value: 2.5}, 7},
+	}
+
+	if result.Len() != len(expected) {
+		t.Fatalf("Expected %d pairs, got %d", len(expected), result.Len())
+	}
+
+	for i, pair := range result.Pairs() {
+		if pair.Key.HashKey() != expected[i].key.HashKey() {
+			t.Errorf("pairs[%d] - expected key %s, got %s", i, expected[i].key.Inspect(), pair.Key.Inspect())
+		}
+		testIntegerObject(t, pair.Value, expected[i].value)
+	}
+}
+
+func TestHashIndexAndAssignment(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`({"foo": 5})["foo"]`, 5},
+		{`({"foo": 5})["bar"]`, nil},
+		{`key := "foo"; ({"foo": 5})[key]`, 5},
+		{`({})["foo"]`, nil},
+		{`({5: 5})[5]`, 5},
+		{`({true: 5})[true]`, 5},
+		{`({1: "int"})[1.0]`, "int"},
+		{`({2.0: "float"})[2]`, "float"},
+		{`m := {}; m["a"] = 1; m["a"] = m["a"] + 1; m["a"]`, 2},
+		{`m := {"a": 1}; m["b"] = 2; m`, `{"a": 1, "b": 2}`},
+		{`m := {"a": 1, "b": 2}; m["a"] = 3; m`, `{"a": 3, "b": 2}`},
+		{`m := {"xs": [1, 2]}; m["xs"][1] = 5; m["xs"]`, "[1, 5]"},
+		{`xs := [1, 2, 3]; xs[0] = 9; xs[-1] = 7; xs`, "[9, 2, 7]"},
+		{`xs := [1]; ys := xs; ys[0] = 2; xs[0]`, 2},
+		{`xs := [1, 2]; xs[0] = xs; xs`, "[[...], 2]"},
+		{`m := {"a": 1}; m["self"] = m; m`, `{"a": 1, "self": {...}}`},
+		{`m := {}; xs := [m]; m["xs"] = xs; [xs, m]`, `[[{"xs": [...]}], {"xs": [{...}]}]`},
+		{`xs := [1]; [xs, xs]`, "[[1], [1]]"},
+		{`len({"a": 1, "b": 2})`, 2},
+		{`keys({"b": 1, "a": 2})`, `[b, a]`},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if evaluated.Inspect() != expected {
+				t.Errorf("%s - expected %s, got %s", tt.input, expected, evaluated.Inspect())
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestEvalBooleanExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"true", true},
+		{"false", false},
+		{"1 < 2", true},
+		{"1 > 2", false},
+		{"1 < 1", false},
+		{"1 == 1", true},
+		{"1 != 1", false},
+		{"1 == 2", false},
+		{"1 != 2", true},
+		{"1.5 < 2.5", true},
+		{"1.5 == 1.5", true},
+		{"true == true", true},
+		{"false == false", true},
+		{"true == false", false},
+		{"true != false", true},
+		{"(1 < 2) == true", true},
+		{"(1 > 2) == true", false},
+		{"1 <= 2", true},
+		{"2 <= 2", true},
+		{"3 <= 2", false},
+		{"1 >= 2", false},
+		{"2 >= 2", true},
+		{"1.5 >= 1.5", true},
+		{"true && true", true},
+		{"true && false", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"1 && \"\"", true},
+		{"!1 || [] == []", false},
+		{"1 < 2 && 2 < 3", true},
+		{"false && 1 / 0", false},
+		{"true || undefined", true},
+		{"xs := [1]; len(xs) > 1 && xs[1] == 2", false},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestBangOperator(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"!true", false},
+		{"!false", true},
+		{"!5", false},
+		{"!!true", true},
+		{"!!false", false},
+		{"!!5", true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestIfElseStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{"if true { 10 }", 10},
+		{"if false { 10 }", nil},
+		{"if 1 { 10 }", 10},
+		{"if 1 < 2 { 10 }", 10},
+		{"if 1 > 2 { 10 }", nil},
+		{"if 1 > 2 { 10 } else { 20 }", 20},
+		{"if 1 < 2 { 10 } else { 20 }", 10},
+		{"if 1 > 2 { 10 } else if 2 > 1 { 20 } else { 30 }", 20},
+		{"if 1 > 2 { 10 } else if 2 > 3 { 20 } else { 30 }", 30},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestReturnStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"return 10", 10},
+		{"return 10; 9", 10},
+		{"return 2 * 5; 9", 10},
+		{"9; return 2 * 5; 9", 10},
+		{`
+		if 10 > 1 {
+			if 10 > 1 {
+				return 10
+			}
+			return 1
+		}`, 10},
+		{"if 1 > 2 { 1 } else if true { return 7 }\n3", 7},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestErrorHandling(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"5 + true", "type mismatch: INTEGER + BOOLEAN"},
+		{"5 + true; 5", "type mismatch: INTEGER + BOOLEAN"},
+		{"-true", "unknown operator: -BOOLEAN"},
+		{"true + false", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"5; true + false; 5", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"if 10 > 1 { true + false }", "unknown operator: BOOLEAN + BOOLEAN"},
+		{`
+		if 10 > 1 {
+			if 10 > 1 {
+				return true + false
+			}
+			return 1
+		}`, "unknown operator: BOOLEAN + BOOLEAN"},
+		{"foobar", "identifier not found: foobar"},
+		{"10 / 0", "division by zero"},
+		{"5(1)", "not a function: INTEGER"},
+		{`"Hello" - "World"`, "unknown operator: STRING - STRING"},
+		{`"a" + 1`, "type mismatch: STRING + INTEGER"},
+		{`"${missing}"`, "identifier not found: missing"},
+		{`len(1)`, "argument to `len` not supported, got INTEGER"},
+		{"[1, 2, 3][3]", "index out of range: 3 (length 3)"},
+		{"[1, 2, 3][-4]", "index out of range: -4 (length 3)"},
+		{`"abc"[5]`, "index out of range: 5 (length 3)"},
+		{"[1, 2][true]", "index operator not supported: ARRAY[BOOLEAN]"},
+		{"1[0]", "index operator not supported: INTEGER[INTEGER]"},
+		{"[1, 2, 3][1:5]", "slice bounds out of range: 5 (length 3)"},
+		{"[1, 2, 3][-4:]", "slice bounds out of range: -4 (length 3)"},
+		{"[1, 2, 3][2:1]", "invalid slice indices: 2 > 1"},
+		{"[1, 2, 3][0.5:]", "slice index must be INTEGER, got FLOAT"},
+		{"5[1:]", "slice operator not supported: INTEGER"},
+		{`({"name": "lemon"})[func(x) { x }]`, "unusable as hash key: FUNCTION"},
+		{`m := {[1]: 2}`, "unusable as hash key: ARRAY"},
+		{`m := {}; m[{}] = 1`, "unusable as hash key: HASH"},
+		{`xs := [1]; xs[1] = 2`, "index out of range: 1 (length 1)"},
+		{`s := "abc"; s[0] = "x"`, "index assignment not supported: STRING"},
+		{"5 % 0", "division by zero"},
+		{"1.5 & 1", "unknown operator: FLOAT & INTEGER"},
+		{"1 << 2.0", "unknown operator: INTEGER << FLOAT"},
+		{"~1.5", "unknown operator: ~FLOAT"},
+		{"~true", "unknown operator: ~BOOLEAN"},
+		{"true | false", "unknown operator: BOOLEAN | BOOLEAN"},
+		{"1 >> -1", "negative shift count: -1"},
+		{"true && 1 + true", "type mismatch: INTEGER + BOOLEAN"},
+		{"false || undefined", "identifier not found: undefined"},
+		{"true <= false", "unknown operator: BOOLEAN <= BOOLEAN"},
+		{"a = 1", "assignment to undeclared identifier: a"},
+		{"a := 1; a := 2", "identifier already declared: a"},
+		{"if true { b := 1 }\nb", "identifier not found: b"},
+		{"if true { b := 1 }\nb = 2", "assignment to undeclared identifier: b"},
+		{"func(x) { x }(1, 2)", "wrong number of arguments. got=2, want=1"},
+		{"1.5 + true", "type mismatch: FLOAT + BOOLEAN"},
+		{"-false", "unknown operator: -BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestDeclareStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"a := 5; a", 5},
+		{"a := 5 * 5; a", 25},
+		{"a := 5; b := a; b", 5},
+		{"a := 5; b := a; c := a + b + 5; c", 15},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(t, tt.input), tt.expected)
+	}
+}
+
+func TestAssignStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"a := 5; a = 10; a", 10},
+		{"a := 5; a = a * 2; a", 10},
+		{"a := 1; if true { a = 2 }\na", 2},
+		{"a := 1; if true { if true { a = a + 2 } }\na", 3},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(t, tt.input), tt.expected)
+	}
+}
+
+func TestBlockScopes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"a := 1; if true { a := 2 }\na", 1},
+		{"a := 1; if true { a := 2; a = 3 }\na", 1},
+		{"a := 1; if true { a := 2; return a }", 2},
+		{"a := 1; if false { } else { b := 2; a = b }\na", 2},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(t, tt.input), tt.expected)
+	}
+}
+
+func TestFunctionObject(t *testing.T) {
+	input := "func(x) { x + 2 }"
+
+	evaluated := testEval(t, input)
+	fn, ok := evaluated.(*object.Function)
+	if !ok {
+		t.Fatalf("Expected *object.Function, got %T (%+v)", evaluated, evaluated)
+	}
+
+	if len(fn.Parameters) != 1 {
+		t.Fatalf("Expected %d parameters, got %d", 1, len(fn.Parameters))
+	}
+
+	if fn.Parameters[0].String() != "x" {
+		t.Fatalf("Expected parameter to be 'x', got %q", fn.Parameters[0])
+	}
+
+	if fn.Body.String() != "{(x + 2)}" {
+		t.Fatalf("Expected body to be %q, got %q", "{(x + 2)}", fn.Body.String())
+	}
+}
+
+func TestFunctionApplication(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"func(x) { x }(5)", 5},
+		{"func(x) { return x }(5)", 5},
+		{"func(x) { x * 2 }(5)", 10},
+		{"func(x, y) { x + y }(5, 5)", 10},
+		{"func(x, y) { x + y }(5 + 5, func(x) { x }(10))", 20},
+		{"func add(x, y) { return x + y }\nadd(2, 3)", 5},
+		{"func fact(n) { if n < 2 { return 1 }\nreturn n * fact(n - 1) }\nfact(5)", 120},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(t, tt.input), tt.expected)
+	}
+}
+
+func TestClosures(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{`
+		func newAdder(x) {
+			return func(y) { x + y }
+		}
+		newAdder(2)(3)`, 5},
+		{`
+		func newCounter() {
+			count := 0
+			return func() {
+				count = count + 1
+				return count
+			}
+		}
+		counter := newCounter()
+		counter()
+		counter()
+		counter()`, 3},
+		{`
+		func newCounter() {
+			count := 0
+			return func() {
+				count = count + 1
+				return count
+			}
+		}
+		a := newCounter()
+		b := newCounter()
+		a(); a(); b()
+		a() * 10 + b()`, 32},
+		{`
+		x := 1
+		get := func() { x }
+		x = 2
+		get()`, 2},
+		{`
+		total := 0
+		func add(n) { total = total + n }
+		add(2); add(3)
+		total`, 5},
+		{`
+		func outer() {
+			x := 1
+			func middle() {
+				func inner() { x = x * 10 }
+				inner()
+			}
+			middle()
+			return x
+		}
+		outer()`, 10},
+		{`
+		x := 1
+		if true {
+			x := 2
+			f := func() { x }
+			x = 3
+			if f() != 3 { return 0 }
+		}
+		x`, 1},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(t, tt.input), tt.expected)
+	}
+}
+
+func TestLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`
+		i := 0
+		while i < 5 { i = i + 1 }
+		i`, 5},
+		{`
+		sum := 0
+		for x in [1, 2, 3] { sum = sum + x }
+		sum`, 6},
+		{`
+		out := ""
+		for c in "héllo" { out = c + out }
+		out`, "olléh"},
+		{`
+		out := ""
+		for k in ({"b": 1, "a": 2}) { out = out + k }
+		out`, "ba"},
+		{`
+		sum := 0
+		for i := 0; i < 5; i = i + 1 { sum = sum + i }
+		sum`, 10},
+		{`
+		i := 0
+		for ;; {
+			i = i + 1
+			if i > 3 { break }
+		}
+		i`, 4},
+		{`
+		sum := 0
+		for x in [1, 2, 3, 4] {
+			if x == 2 { continue }
+			sum = sum + x
+		}
+		sum`, 8},
+		{`
+		n := 0
+		outer: for x in [1, 2, 3] {
+			for y in [1, 2, 3] {
+				if y == 2 { continue outer }
+				if x == 3 { break outer }
+				n = n + 1
+			}
+		}
+		n`, 2},
+		{`
+		func find(xs, target) {
+			i := 0
+			while true {
+				if xs[i] == target { return i }
+				i = i + 1
+			}
+		}
+		find([5, 6, 7], 7)`, 2},
+		{`
+		fs := []
+		for x in [1, 2] { fs = push(fs, func() { x }) }
+		fs[0]() * 10 + fs[1]()`, 12},
+		{`
+		for x in [1] { y := x }
+		y`, "identifier not found: y"},
+		{`
+		for i := 0; i < 1; i = i + 1 {}
+		i`, "identifier not found: i"},
+		{`for x in 5 {}`, "cannot iterate over INTEGER"},
+		{`while 1 + true {}`, "type mismatch: INTEGER + BOOLEAN"},
+		{`xs := [1]; for x in xs { xs = push(xs, x); if len(xs) > 3 { break } }; len(xs)`, 2},
+		{`
+		func f() { x := 1 }
+		while true { f(); break }`, nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if evaluated == nil || evaluated.Inspect() != expected && evaluated.Inspect() != "ERROR: "+expected {
+				t.Errorf("%s - expected %s, got %v", tt.input, expected, evaluated)
+			}
+		default:
+			if evaluated != nil {
+				t.Errorf("%s - expected no value, got %s", tt.input, evaluated.Inspect())
+			}
+		}
+	}
+}
+
+func TestShadowing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{"x := 1; func f(x) { x }\nf(5)", 5},
+		{"x := 1; func f(x) { x = 7 }\nf(5); x", 1},
+		{"x := 1; func f() { x := 2; x }\nf() + x", 3},
+		{"x := 1; f := func() { if true { x := 5 }\nx }; f()", 1},
+		{"func f(x) { x := 2 }\nf(1)", "identifier already declared: x"},
+		{"func f() { 1 }\nfunc f() { 2 }", "identifier already declared: f"},
+		{"func f() { y := 1 }\nf(); y", "identifier not found: y"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("Expected *object.Error, got %T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestBuiltinFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{"int(3.9)", 3},
+		{"int(-3.9)", -3},
+		{"int(7)", 7},
+		{"float(2)", 2.0},
+		{"float(1) / 4", 0.25},
+		{"int(true)", "argument to `int` not supported, got BOOLEAN"},
+		{"float(1, 2)", "wrong number of arguments. got=2, want=1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case float64:
+			testFloatObject(t, evaluated, expected)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("Expected *object.Error, got %T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func testIntegerObject(t *testing.T, obj object.Object, expected int64) {
+	t.Helper()
+	result, ok := obj.(*object.Integer)
+	if !ok {
+		t.Fatalf("Expected *object.Integer, got %T (%+v)", obj, obj)
+	}
+	if result.Value != expected {
+		t.Fatalf("Expected object.Value=%d, got %d", expected, result.Value)
+	}
+}
+
+func testFloatObject(t *testing.T, obj object.Object, expected float64) {
+	t.Helper()
+	result, ok := obj.(*object.Float)
+	if !ok {
+		t.Fatalf("Expected *object.Float, got %T (%+v)", obj, obj)
+	}
+	if result.Value != expected {
+		t.Fatalf("Expected object.Value=%g, got %g", expected, result.Value)
+	}
+}
+
+func testBooleanObject(t *testing.T, obj object.Object, expected bool) {
+	t.Helper()
+	result, ok := obj.(*object.Boolean)
+	if !ok {
+		t.Fatalf("Expected *object.Boolean, got %T (%+v)", obj, obj)
+	}
+	if result.Value != expected {
+		t.Fatalf("Expected object.Value=%t, got %t", expected, result.Value)
+	}
+}
+
+func testNullObject(t *testing.T, obj object.Object) {
+	t.Helper()
+	if obj != NULL {
+		t.Fatalf("Expected NULL, got %T (%+v)", obj, obj)
+	}
+}
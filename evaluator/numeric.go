@@ -0,0 +1,144 @@
+package evaluator
+
+import (
+	"math/big"
+
+	"github.com/chaitanya-Uike/lemon/decimal"
+	"github.com/chaitanya-Uike/lemon/object"
+)
+
+// Mixed arithmetic follows these rules:
+//   - integers stay integers, promoting to big integers when a result
+//     overflows an int64 and back when it fits again
+//   - an integer and a float give a float
+//   - an integer and a decimal give a decimal
+//   - decimals and floats don't mix, one has to be converted with decimal()
+//     or float() first so that precision is never lost silently
+
+// maxShift bounds the shift count of <<, 1 << 1e12 would not fit in memory.
+const maxShift = 1 << 20
+
+func isExact(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.DECIMAL_OBJ
+}
+
+func toBigInt(obj object.Object) *big.Int {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return big.NewInt(obj.Value)
+	case *object.BigInteger:
+		return obj.Value
+	}
+	return nil
+}
+
+func toDecimal(obj object.Object) decimal.Decimal {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return decimal.FromInt64(obj.Value)
+	case *object.BigInteger:
+		return decimal.FromInt(obj.Value)
+	case *object.Decimal:
+		return obj.Value
+	}
+	return decimal.Decimal{}
+}
+
+func evalBigIntegerInfixExpression(operator string, left, right *big.Int) object.Object {
+	switch operator {
+	case "+":
+		return object.NewInteger(new(big.Int).Add(left, right))
+	case "-":
+		return object.NewInteger(new(big.Int).Sub(left, right))
+	case "*":
+		return object.NewInteger(new(big.Int).Mul(left, right))
+	case "/":
+		if right.Sign() == 0 {
+			return newError("division by zero")
+		}
+		return object.NewInteger(new(big.Int).Quo(left, right))
+	case "%":
+		if right.Sign() == 0 {
+			return newError("division by zero")
+		}
+		return object.NewInteger(new(big.Int).Rem(left, right))
+	case "&":
+		return object.NewInteger(new(big.Int).And(left, right))
+	case "|":
+		return object.NewInteger(new(big.Int).Or(left, right))
+	case "^":
+		return object.NewInteger(new(big.Int).Xor(left, right))
+	case "<<":
+		if right.Sign() < 0 {
+			return newError("negative shift count: %s", right)
+		}
+		if !right.IsInt64() || right.Int64() > maxShift {
+			return newError("shift count too large: %s", right)
+		}
+		return object.NewInteger(new(big.Int).Lsh(left, uint(right.Int64())))
+	case ">>":
+		if right.Sign() < 0 {
+			return newError("negative shift count: %s", right)
+		}
+		// shifting by more than the bit length leaves 0 or -1 either way
+		n := uint(left.BitLen())
+		if right.IsInt64() && right.Int64() < int64(n) {
+			n = uint(right.Int64())
+		}
+		return object.NewInteger(new(big.Int).Rsh(left, n))
+	case "<":
+		return nativeBoolToBooleanObject(left.Cmp(right) < 0)
+	case ">":
+		return nativeBoolToBooleanObject(left.Cmp(right) > 0)
+	case "<=":
+		return nativeBoolToBooleanObject(left.Cmp(right) <= 0)
+	case ">=":
+		return nativeBoolToBooleanObject(left.Cmp(right) >= 0)
+	case "==":
+		return nativeBoolToBooleanObject(left.Cmp(right) == 0)
+	case "!=":
+		return nativeBoolToBooleanObject(left.Cmp(right) != 0)
+	default:
+		return newError("unknown operator: %s %s %s", object.INTEGER_OBJ, operator, object.INTEGER_OBJ)
+	}
+}
+
+func evalDecimalInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := toDecimal(left)
+	rightVal := toDecimal(right)
+
+	switch operator {
+	case "+":
+		return &object.Decimal{Value: leftVal.Add(rightVal)}
+	case "-":
+		return &object.Decimal{Value: leftVal.Sub(rightVal)}
+	case "*":
+		return &object.Decimal{Value: leftVal.Mul(rightVal)}
+	case "/":
+		quo, err := leftVal.Quo(rightVal)
+		if err != nil {
+			return newError("division by zero")
+		}
+		return &object.Decimal{Value: quo}
+	case "%":
+		rem, err := leftVal.Rem(rightVal)
+		if err != nil {
+			return newError("division by zero")
+		}
+		return &object.Decimal{Value: rem}
+	case "<":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) < 0)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) > 0)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) <= 0)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) >= 0)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) == 0)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) != 0)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
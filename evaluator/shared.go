@@ -0,0 +1,44 @@
+package evaluator
+
+import "github.com/chaitanya-Uike/lemon/object"
+
+// The functions below expose the operations of the language on values, the
+// bytecode vm runs them too so that both implementations agree on every
+// result and error message. Errors are returned as *object.Error.
+
+func EvalPrefix(operator string, right object.Object) object.Object {
+	return evalPrefixExpression(operator, right)
+}
+
+func EvalInfix(operator string, left, right object.Object) object.Object {
+	return evalInfixExpression(operator, left, right)
+}
+
+func EvalIndex(left, index object.Object) object.Object {
+	return evalIndexExpression(left, index)
+}
+
+// EvalIndexAssignment stores val at index of left, it returns nil unless it
+// fails.
+func EvalIndexAssignment(left, index, val object.Object) object.Object {
+	return evalIndexAssignment(left, index, val)
+}
+
+// EvalSlice slices an array or a string, a nil bound was left out.
+func EvalSlice(left, low, high object.Object) object.Object {
+	return evalSlice(left, low, high)
+}
+
+// IterationItems returns the values a for-in loop over iterable visits.
+func IterationItems(iterable object.Object) ([]object.Object, *object.Error) {
+	return iterationItems(iterable)
+}
+
+func IsTruthy(obj object.Object) bool {
+	return isTruthy(obj)
+}
+
+func LookupBuiltin(name string) (*object.Builtin, bool) {
+	builtin, ok := builtins[name]
+	return builtin, ok
+}
@@ -1,8 +1,14 @@
 package lexer
 
 import (
+	"bufio"
+	"errors"
+	"io"
 	"slices"
+	"strconv"
+	"strings"
 	"unicode"
+	"unicode/utf8"
 
 	"github.com/chaitanya-Uike/lemon/token"
 )
@@ -11,37 +17,154 @@ type Lexer struct {
 	input     string
 	pos       int
 	readPos   int
-	ch        byte
+	ch        rune
 	prevToken *token.Token
+
+	// A lexer created by NewReader only holds a window of its input: input
+	// starts at offset shift of the stream, and everything before mark, the
+	// start of the token being read, is dropped on the next refill.
+	r     *bufio.Reader
+	chunk []byte
+	shift int
+	mark  int
+	err   error
+
+	// invalid is the position of the first byte that is not valid UTF-8
+	// inside the string literal or comment being read, if any.
+	invalid     *token.Position
+	invalidByte byte
+
+	base      int
+	line      int
+	lineStart int
+
+	// doc collects the lines of the /// comment being read, docs maps the
+	// offset of the token following a doc comment to its text.
+	doc     []string
+	docLine int
+	docs    map[int]string
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	l := &Lexer{input: input, line: 1}
+	l.readChar()
+	return l
+}
+
+// NewAt returns a lexer for input that reports positions as if input started
+// at pos in a larger source, e.g. an interpolation inside a string literal.
+func NewAt(input string, pos token.Position) *Lexer {
+	l := &Lexer{input: input, base: pos.Offset, line: pos.Line, lineStart: 1 - pos.Column}
 	l.readChar()
 	return l
 }
 
+// readChar decodes the next rune of the input. A byte that is not valid
+// UTF-8 is read on its own as utf8.RuneError, see isInvalid.
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line++
+		l.lineStart = l.readPos
+	}
 	l.pos = l.readPos
-	if l.readPos >= len(l.input) {
+	l.fill()
+	if l.readPos-l.shift >= len(l.input) {
 		l.ch = 0
 		return
 	}
-	l.ch = l.input[l.readPos]
-	l.readPos++
+	r, width := utf8.DecodeRuneInString(l.input[l.readPos-l.shift:])
+	l.ch = r
+	l.readPos += width
 }
 
-func (l *Lexer) peekChar() byte {
-	if l.readPos >= len(l.input) {
+func (l *Lexer) peekChar() rune {
+	l.fill()
+	if l.readPos-l.shift >= len(l.input) {
 		return 0
 	}
-	return l.input[l.readPos]
+	r, _ := utf8.DecodeRuneInString(l.input[l.readPos-l.shift:])
+	return r
+}
+
+// NewReader returns a lexer that reads its input from r as it goes instead
+// of needing all of it up front. It never looks further ahead than the rune
+// after the current one, so only the token being read and the last chunk
+// read from r are kept in memory. Reading stops at the first error from r,
+// which is then reported by Err, as if the input ended there.
+func NewReader(r io.Reader) *Lexer {
+	l := &Lexer{r: bufio.NewReader(r), chunk: make([]byte, 4096), line: 1}
+	l.readChar()
+	return l
+}
+
+// Err returns the error that stopped reading the input of a lexer created by
+// NewReader, io.EOF is not an error.
+func (l *Lexer) Err() error {
+	if errors.Is(l.err, io.EOF) {
+		return nil
+	}
+	return l.err
+}
+
+// fill makes sure the window holds the rune after the current one, unless
+// the input ends before it.
+func (l *Lexer) fill() {
+	for l.r != nil && l.err == nil && l.readPos+2*utf8.UTFMax > l.shift+len(l.input) {
+		n, err := l.r.Read(l.chunk)
+		l.input = l.input[l.mark-l.shift:] + string(l.chunk[:n])
+		l.shift = l.mark
+		l.err = err
+	}
+}
+
+// text returns the input between the offsets from and to. The window of a
+// reader lexer is shared by many tokens, so its text is copied out to not
+// keep the whole window alive.
+func (l *Lexer) text(from, to int) string {
+	s := l.input[from-l.shift : to-l.shift]
+	if l.r != nil {
+		return strings.Clone(s)
+	}
+	return s
+}
+
+// isInvalid reports whether the current rune is an invalid byte rather than
+// a U+FFFD written out in the source.
+func (l *Lexer) isInvalid() bool {
+	return l.ch == utf8.RuneError && l.readPos-l.pos == 1
+}
+
+// checkEncoding records the current position if it holds an invalid byte
+// and no earlier one was found.
+func (l *Lexer) checkEncoding() {
+	if l.invalid == nil && l.isInvalid() {
+		pos := l.position()
+		l.invalid = &pos
+		l.invalidByte = l.input[l.pos-l.shift]
+	}
+}
+
+// invalidToken returns an ILLEGAL token for the invalid byte recorded by
+// checkEncoding, its literal is the byte itself.
+func (l *Lexer) invalidToken() token.Token {
+	pos := *l.invalid
+	l.invalid = nil
+	tok := token.Token{Type: token.ILLEGAL, Literal: string([]byte{l.invalidByte}), Pos: pos}
+	tok.End = token.Position{Offset: pos.Offset + 1, Line: pos.Line, Column: pos.Column + 1}
+	l.prevToken = &tok
+	return tok
+}
+
+func (l *Lexer) position() token.Position {
+	return token.Position{Offset: l.base + l.pos, Line: l.line, Column: l.pos - l.lineStart + 1}
 }
 
 func (l *Lexer) NextToken() token.Token {
 	var tok token.Token
 
 	l.skipWhitespace()
+	l.mark = l.pos
+	start := l.position()
 
 	switch l.ch {
 	case '=':
@@ -52,6 +175,14 @@ func (l *Lexer) NextToken() token.Token {
 		} else {
 			tok = newToken(token.ASSIGN, l.ch)
 		}
+	case ':':
+		if l.peekChar() == '=' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.DECLARE, Literal: string(ch) + string(l.ch)}
+		} else {
+			tok = newToken(token.COLON, l.ch)
+		}
 	case '+':
 		tok = newToken(token.PLUS, l.ch)
 	case '-':
@@ -59,7 +190,38 @@ func (l *Lexer) NextToken() token.Token {
 	case '*':
 		tok = newToken(token.ASTERISK, l.ch)
 	case '/':
-		tok = newToken(token.SLASH, l.ch)
+		switch l.peekChar() {
+		case '/':
+			l.skipLineComment()
+			if l.invalid != nil {
+				return l.invalidToken()
+			}
+			return l.NextToken()
+		case '*':
+			multiline, ok := l.skipBlockComment()
+			if l.invalid != nil {
+				return l.invalidToken()
+			}
+			if !ok {
+				tok = token.Token{Type: token.ILLEGAL, Literal: "/*", Pos: start}
+				tok.End = token.Position{Offset: start.Offset + 2, Line: start.Line, Column: start.Column + 2}
+				l.prevToken = &tok
+				return tok
+			}
+			// a comment spanning lines ends the line it started on
+			if multiline && l.shouldInsertSemicolon() {
+				tok = newToken(token.SEMICOLON, ';')
+				tok.Pos = start
+				tok.End = token.Position{Offset: start.Offset + 1, Line: start.Line, Column: start.Column + 1}
+				l.prevToken = &tok
+				return tok
+			}
+			return l.NextToken()
+		default:
+			tok = newToken(token.SLASH, l.ch)
+		}
+	case '%':
+		tok = newToken(token.PERCENT, l.ch)
 	case '!':
 		if l.peekChar() == '=' {
 			ch := l.ch
@@ -69,9 +231,51 @@ func (l *Lexer) NextToken() token.Token {
 			tok = newToken(token.BANG, l.ch)
 		}
 	case '<':
-		tok = newToken(token.LT, l.ch)
+		switch l.peekChar() {
+		case '=':
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.LT_EQ, Literal: string(ch) + string(l.ch)}
+		case '<':
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.SHL, Literal: string(ch) + string(l.ch)}
+		default:
+			tok = newToken(token.LT, l.ch)
+		}
 	case '>':
-		tok = newToken(token.GT, l.ch)
+		switch l.peekChar() {
+		case '=':
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.GT_EQ, Literal: string(ch) + string(l.ch)}
+		case '>':
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.SHR, Literal: string(ch) + string(l.ch)}
+		default:
+			tok = newToken(token.GT, l.ch)
+		}
+	case '&':
+		if l.peekChar() == '&' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.AND, Literal: string(ch) + string(l.ch)}
+		} else {
+			tok = newToken(token.AMPERSAND, l.ch)
+		}
+	case '|':
+		if l.peekChar() == '|' {
+			ch := l.ch
+			l.readChar()
+			tok = token.Token{Type: token.OR, Literal: string(ch) + string(l.ch)}
+		} else {
+			tok = newToken(token.PIPE, l.ch)
+		}
+	case '^':
+		tok = newToken(token.CARET, l.ch)
+	case '~':
+		tok = newToken(token.TILDE, l.ch)
 	case '(':
 		tok = newToken(token.LPAREN, l.ch)
 	case ')':
@@ -80,8 +284,33 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.LBRACE, l.ch)
 	case '}':
 		tok = newToken(token.RBRACE, l.ch)
+	case '[':
+		tok = newToken(token.LBRACKET, l.ch)
+	case ']':
+		tok = newToken(token.RBRACKET, l.ch)
 	case ',':
 		tok = newToken(token.COMMA, l.ch)
+	case '?':
+		tok = newToken(token.QUESTION, l.ch)
+	case '.':
+		if isDigit(l.peekChar()) {
+			tok.Literal, tok.Type = l.readNumber()
+			return l.finishToken(tok, start)
+		}
+		tok = newToken(token.ILLEGAL, l.ch)
+	case '"':
+		str, ok := l.readString()
+		if l.invalid != nil {
+			if ok {
+				l.readChar()
+			}
+			return l.invalidToken()
+		}
+		if ok {
+			tok = token.Token{Type: token.STRING, Literal: str}
+		} else {
+			tok = token.Token{Type: token.ILLEGAL, Literal: "\"" + str}
+		}
 	case '\n':
 		if l.shouldInsertSemicolon() {
 			tok = newToken(token.SEMICOLON, ';')
@@ -102,59 +331,220 @@ func (l *Lexer) NextToken() token.Token {
 	default:
 		if isLetter(l.ch) {
 			tok.Literal, tok.Type = l.readIdentifier()
-			l.prevToken = &tok
-			return tok
-		} else if unicode.IsDigit(rune(l.ch)) {
+			return l.finishToken(tok, start)
+		} else if isDigit(l.ch) {
 			tok.Literal, tok.Type = l.readNumber()
-			l.prevToken = &tok
-			return tok
+			return l.finishToken(tok, start)
+		} else if l.isInvalid() {
+			tok = token.Token{Type: token.ILLEGAL, Literal: l.text(l.pos, l.readPos)}
 		} else {
 			tok = newToken(token.ILLEGAL, l.ch)
 		}
 	}
 	l.readChar()
+	return l.finishToken(tok, start)
+}
+
+// finishToken records the span of tok, which started at start and ends at the
+// current position. Tokens never span lines, so the end column is derived
+// from the start.
+func (l *Lexer) finishToken(tok token.Token, start token.Position) token.Token {
+	width := l.base + l.pos - start.Offset
+	tok.Pos = start
+	tok.End = token.Position{Offset: start.Offset + width, Line: start.Line, Column: start.Column + width}
 	l.prevToken = &tok
+
+	if l.doc != nil {
+		if tok.Type != token.EOF && start.Line == l.docLine+1 {
+			if l.docs == nil {
+				l.docs = make(map[int]string)
+			}
+			l.docs[start.Offset] = strings.Join(l.doc, "\n")
+		}
+		l.doc = nil
+	}
+
 	return tok
 }
 
-func newToken(tokenType token.TokenType, ch byte) token.Token {
+// Doc returns the /// comment on the lines directly above the token starting
+// at pos, without the slashes, or "" if there is none.
+func (l *Lexer) Doc(pos token.Position) string {
+	return l.docs[pos.Offset]
+}
+
+func newToken(tokenType token.TokenType, ch rune) token.Token {
 	return token.Token{Type: tokenType, Literal: string(ch)}
 }
 
-func isLetter(ch byte) bool {
-	return unicode.IsLetter(rune(ch)) || ch == '_'
+// Identifiers follow Go's rule: a letter followed by letters and digits,
+// where a letter is '_' or anything in Unicode category L and a digit is
+// anything in category Nd, so größe, 変数 and x٣ are all identifiers. Number
+// literals on the other hand only use the ASCII digits.
+func isLetter(ch rune) bool {
+	return unicode.IsLetter(ch) || ch == '_'
 }
 
 func (l *Lexer) readIdentifier() (string, token.TokenType) {
 	pos := l.pos
-	for isLetter(l.ch) {
+	for isLetter(l.ch) || unicode.IsDigit(l.ch) {
 		l.readChar()
 	}
-	ident := l.input[pos:l.pos]
+	ident := l.text(pos, l.pos)
 	return ident, token.LookupIdent(ident)
 }
 
+func isDigit(ch rune) bool {
+	return '0' <= ch && ch <= '9'
+}
+
+// readNumber reads a number literal using Go's syntax: 0x, 0o and 0b
+// prefixes (a bare leading 0 is octal too), '_' between digits, and floats
+// with a fraction and/or an exponent, the integer part may be omitted.
+// A decimal number followed by a d, like 19.99d, is a DECIMAL literal.
+//
+// Everything that could belong to the literal is consumed before it is
+// checked, so 1.2.3 or 0xZZ become a single ILLEGAL token rather than being
+// split into several valid ones.
 func (l *Lexer) readNumber() (string, token.TokenType) {
 	pos := l.pos
-	isFloat := false
+	prefixed := l.ch == '0' && strings.ContainsRune("xXoObB", l.peekChar())
 
-	for unicode.IsDigit(rune(l.ch)) {
+	for isLetter(l.ch) || isDigit(l.ch) || l.ch == '.' {
+		exponent := !prefixed && (l.ch == 'e' || l.ch == 'E')
 		l.readChar()
+		if exponent && (l.ch == '+' || l.ch == '-') {
+			l.readChar()
+		}
 	}
 
-	if l.ch == '.' {
-		isFloat = true
-		l.readChar()
+	literal := l.text(pos, l.pos)
+
+	if !prefixed && strings.HasSuffix(literal, "d") {
+		if _, err := strconv.ParseFloat(literal[:len(literal)-1], 64); errors.Is(err, strconv.ErrSyntax) {
+			return literal, token.ILLEGAL
+		}
+		return literal, token.DECIMAL
+	}
+
+	if prefixed || !strings.ContainsAny(literal, ".eE") {
+		if _, err := strconv.ParseInt(literal, 0, 64); errors.Is(err, strconv.ErrSyntax) {
+			return literal, token.ILLEGAL
+		}
+		return literal, token.INT
+	}
 
-		for unicode.IsDigit(rune(l.ch)) {
+	if _, err := strconv.ParseFloat(literal, 64); errors.Is(err, strconv.ErrSyntax) {
+		return literal, token.ILLEGAL
+	}
+	return literal, token.FLOAT
+}
+
+// readString reads a string literal starting at the opening quote and returns
+// its raw contents, escapes and ${...} interpolations are left for the parser
+// to decode. The lexer only has to know where they end so that a quote inside
+// an escape or an interpolation does not terminate the literal.
+func (l *Lexer) readString() (string, bool) {
+	pos := l.pos + 1
+	for {
+		l.readChar()
+		l.checkEncoding()
+		switch l.ch {
+		case '"':
+			return l.text(pos, l.pos), true
+		case 0, '\n':
+			return l.text(pos, l.pos), false
+		case '\\':
 			l.readChar()
+			if l.ch == 0 {
+				return l.text(pos, l.pos), false
+			}
+		case '$':
+			if l.peekChar() == '{' {
+				l.readChar()
+				if !l.skipInterpolation() {
+					return l.text(pos, l.pos), false
+				}
+			}
+		}
+	}
+}
+
+func (l *Lexer) skipInterpolation() bool {
+	depth := 1
+	for {
+		l.readChar()
+		l.checkEncoding()
+		switch l.ch {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return true
+			}
+		case '"':
+			if _, ok := l.readString(); !ok {
+				return false
+			}
+		case 0, '\n':
+			return false
 		}
 	}
+}
 
-	if isFloat {
-		return l.input[pos:l.pos], token.FLOAT
+// skipLineComment skips a // comment up to the end of the line. Lines that
+// start with /// and no other token form a doc comment for the token on the
+// line that follows them.
+func (l *Lexer) skipLineComment() {
+	pos := l.pos
+	firstOnLine := l.prevToken == nil || l.prevToken.End.Line < l.line
+
+	for l.ch != '\n' && l.ch != 0 {
+		l.checkEncoding()
+		l.readChar()
 	}
-	return l.input[pos:l.pos], token.INT
+
+	text := l.text(pos, l.pos)
+	if !firstOnLine || !strings.HasPrefix(text, "///") || strings.HasPrefix(text, "////") {
+		return
+	}
+
+	if l.doc != nil && l.docLine != l.line-1 {
+		l.doc = nil
+	}
+	text = strings.TrimPrefix(text[3:], " ")
+	l.doc = append(l.doc, strings.TrimRight(text, " \t\r"))
+	l.docLine = l.line
+}
+
+// skipBlockComment skips a /* */ comment, which may contain nested ones. It
+// reports whether the comment spanned more than one line and whether it was
+// terminated.
+func (l *Lexer) skipBlockComment() (bool, bool) {
+	line := l.line
+	depth := 0
+
+	for l.ch != 0 {
+		// nothing of a block comment is kept, however long it is
+		l.mark = l.pos
+		l.checkEncoding()
+		switch {
+		case l.ch == '/' && l.peekChar() == '*':
+			depth++
+			l.readChar()
+		case l.ch == '*' && l.peekChar() == '/':
+			depth--
+			l.readChar()
+			if depth == 0 {
+				l.readChar()
+				return l.line > line, true
+			}
+		}
+		l.readChar()
+	}
+
+	return l.line > line, false
 }
 
 func (l *Lexer) skipWhitespace() {
@@ -173,12 +563,17 @@ func (l *Lexer) shouldInsertSemicolon() bool {
 
 		token.INT,
 		token.FLOAT,
+		token.DECIMAL,
+		token.STRING,
 		token.TRUE,
 		token.FALSE,
 
 		token.RETURN,
+		token.BREAK,
+		token.CONTINUE,
 
 		token.RPAREN,
+		token.RBRACKET,
 	}
 
 	return slices.Contains(closingTypes, l.prevToken.Type)
@@ -1,7 +1,11 @@
 package lexer
 
 import (
+	"errors"
+	"io"
+	"strings"
 	"testing"
+	"testing/iotest"
 
 	"github.com/chaitanya-Uike/lemon/token"
 )
@@ -17,7 +21,7 @@ func TestNextToken(t *testing.T) {
 	result = add(five, ten)
 
 	float = result + 2.5;
-	!-/*5
+	!-/ *5
 	5 < 10 > 5
 
 	if 5 < 10 {
@@ -28,6 +32,15 @@ func TestNextToken(t *testing.T) {
 
 	10 == 10
 	10 != 9
+	a <= b >= c % 2
+	a && b || !c
+	a & b | c ^ ~d << 1 >> 2
+	x := 1
+	"foobar"
+	"foo \"bar\""
+	"sum: ${add(1, len("}"))}!"
+	[1, 2][0:1]
+	n: int? := 1
 	return 1234121`
 
 	tests := []struct {
@@ -106,6 +119,62 @@ func TestNextToken(t *testing.T) {
 		{token.NOT_EQ, "!="},
 		{token.INT, "9"},
 		{token.SEMICOLON, ";"},
+		{token.IDENT, "a"},
+		{token.LT_EQ, "<="},
+		{token.IDENT, "b"},
+		{token.GT_EQ, ">="},
+		{token.IDENT, "c"},
+		{token.PERCENT, "%"},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "a"},
+		{token.AND, "&&"},
+		{token.IDENT, "b"},
+		{token.OR, "||"},
+		{token.BANG, "!"},
+		{token.IDENT, "c"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "a"},
+		{token.AMPERSAND, "&"},
+		{token.IDENT, "b"},
+		{token.PIPE, "|"},
+		{token.IDENT, "c"},
+		{token.CARET, "^"},
+		{token.TILDE, "~"},
+		{token.IDENT, "d"},
+		{token.SHL, "<<"},
+		{token.INT, "1"},
+		{token.SHR, ">>"},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.DECLARE, ":="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.STRING, "foobar"},
+		{token.SEMICOLON, ";"},
+		{token.STRING, `foo \"bar\"`},
+		{token.SEMICOLON, ";"},
+		{token.STRING, `sum: ${add(1, len("}"))}!`},
+		{token.SEMICOLON, ";"},
+		{token.LBRACKET, "["},
+		{token.INT, "1"},
+		{token.COMMA, ","},
+		{token.INT, "2"},
+		{token.RBRACKET, "]"},
+		{token.LBRACKET, "["},
+		{token.INT, "0"},
+		{token.COLON, ":"},
+		{token.INT, "1"},
+		{token.RBRACKET, "]"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "n"},
+		{token.COLON, ":"},
+		{token.IDENT, "int"},
+		{token.QUESTION, "?"},
+		{token.DECLARE, ":="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
 		{token.RETURN, "return"},
 		{token.INT, "1234121"},
 		{token.SEMICOLON, ";"},
@@ -125,3 +194,435 @@ func TestNextToken(t *testing.T) {
 		}
 	}
 }
+
+func TestUnterminatedString(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedLiteral string
+	}{
+		{`"abc`, `"abc`},
+		{"\"abc\ndef\"", `"abc`},
+		{`"abc\`, `"abc\`},
+		{`"${ "x" `, `"${ "x" `},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+		if tok.Type != token.ILLEGAL {
+			t.Fatalf("Expected %q to lex as ILLEGAL, got %q", tt.input, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("Expected literal %q, got %q", tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestTokenPositions(t *testing.T) {
+	input := "x := 10\n  if x >= 1 {\n\treturn \"hi\"\n}"
+
+	tests := []struct {
+		expectedType token.TokenType
+		expectedPos  token.Position
+		expectedEnd  token.Position
+	}{
+		{token.IDENT, token.Position{Offset: 0, Line: 1, Column: 1}, token.Position{Offset: 1, Line: 1, Column: 2}},
+		{token.DECLARE, token.Position{Offset: 2, Line: 1, Column: 3}, token.Position{Offset: 4, Line: 1, Column: 5}},
+		{token.INT, token.Position{Offset: 5, Line: 1, Column: 6}, token.Position{Offset: 7, Line: 1, Column: 8}},
+		{token.SEMICOLON, token.Position{Offset: 7, Line: 1, Column: 8}, token.Position{Offset: 8, Line: 1, Column: 9}},
+		{token.IF, token.Position{Offset: 10, Line: 2, Column: 3}, token.Position{Offset: 12, Line: 2, Column: 5}},
+		{token.IDENT, token.Position{Offset: 13, Line: 2, Column: 6}, token.Position{Offset: 14, Line: 2, Column: 7}},
+		{token.GT_EQ, token.Position{Offset: 15, Line: 2, Column: 8}, token.Position{Offset: 17, Line: 2, Column: 10}},
+		{token.INT, token.Position{Offset: 18, Line: 2, Column: 11}, token.Position{Offset: 19, Line: 2, Column: 12}},
+		{token.LBRACE, token.Position{Offset: 20, Line: 2, Column: 13}, token.Position{Offset: 21, Line: 2, Column: 14}},
+		{token.RETURN, token.Position{Offset: 23, Line: 3, Column: 2}, token.Position{Offset: 29, Line: 3, Column: 8}},
+		{token.STRING, token.Position{Offset: 30, Line: 3, Column: 9}, token.Position{Offset: 34, Line: 3, Column: 13}},
+		{token.SEMICOLON, token.Position{Offset: 34, Line: 3, Column: 13}, token.Position{Offset: 35, Line: 3, Column: 14}},
+		{token.RBRACE, token.Position{Offset: 35, Line: 4, Column: 1}, token.Position{Offset: 36, Line: 4, Column: 2}},
+		{token.EOF, token.Position{Offset: 36, Line: 4, Column: 2}, token.Position{Offset: 36, Line: 4, Column: 2}},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Pos != tt.expectedPos {
+			t.Fatalf("tests[%d] - pos wrong. expected=%+v, got=%+v", i, tt.expectedPos, tok.Pos)
+		}
+		if tok.End != tt.expectedEnd {
+			t.Fatalf("tests[%d] - end wrong. expected=%+v, got=%+v", i, tt.expectedEnd, tok.End)
+		}
+	}
+}
+
+func TestNewAtPositions(t *testing.T) {
+	l := NewAt("a +\nb", token.Position{Offset: 10, Line: 3, Column: 5})
+
+	expected := []token.Position{
+		{Offset: 10, Line: 3, Column: 5},
+		{Offset: 12, Line: 3, Column: 7},
+		{Offset: 14, Line: 4, Column: 1},
+	}
+
+	for i, pos := range expected {
+		tok := l.NextToken()
+		if tok.Pos != pos {
+			t.Fatalf("tokens[%d] - pos wrong. expected=%+v, got=%+v", i, pos, tok.Pos)
+		}
+	}
+}
+
+func TestLoopKeywords(t *testing.T) {
+	input := "for x in xs {\n\tbreak\n\tcontinue outer\n}\nwhile"
+
+	expected := []token.TokenType{
+		token.FOR, token.IDENT, token.IN, token.IDENT, token.LBRACE,
+		token.BREAK, token.SEMICOLON,
+		token.CONTINUE, token.IDENT, token.SEMICOLON,
+		token.RBRACE,
+		token.WHILE,
+		token.EOF,
+	}
+
+	l := New(input)
+	for i, tt := range expected {
+		tok := l.NextToken()
+		if tok.Type != tt {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt, tok.Type)
+		}
+	}
+}
+
+func TestNumberLiterals(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{"42", token.INT, "42"},
+		{"1_000_000", token.INT, "1_000_000"},
+		{"0xFF", token.INT, "0xFF"},
+		{"0X_ff", token.INT, "0X_ff"},
+		{"0o755", token.INT, "0o755"},
+		{"0b1010_1010", token.INT, "0b1010_1010"},
+		{"2.5", token.FLOAT, "2.5"},
+		{"1.", token.FLOAT, "1."},
+		{".5", token.FLOAT, ".5"},
+		{"1e9", token.FLOAT, "1e9"},
+		{"2.5E-3", token.FLOAT, "2.5E-3"},
+		{"6.02e+23", token.FLOAT, "6.02e+23"},
+		{"1_000.000_1", token.FLOAT, "1_000.000_1"},
+		{"19.99d", token.DECIMAL, "19.99d"},
+		{"5d", token.DECIMAL, "5d"},
+		{".5d", token.DECIMAL, ".5d"},
+		{"1e3d", token.DECIMAL, "1e3d"},
+		{"0x1d", token.INT, "0x1d"},
+		{"1.5dd", token.ILLEGAL, "1.5dd"},
+		{"1d5", token.ILLEGAL, "1d5"},
+		{"99999999999999999999", token.INT, "99999999999999999999"},
+		{"1.2.3", token.ILLEGAL, "1.2.3"},
+		{"0xZZ", token.ILLEGAL, "0xZZ"},
+		{"0x", token.ILLEGAL, "0x"},
+		{"0b102", token.ILLEGAL, "0b102"},
+		{"09", token.ILLEGAL, "09"},
+		{"1__0", token.ILLEGAL, "1__0"},
+		{"1_", token.ILLEGAL, "1_"},
+		{"1e", token.ILLEGAL, "1e"},
+		{"1e+", token.ILLEGAL, "1e+"},
+		{"12abc", token.ILLEGAL, "12abc"},
+		{"0x1.5", token.ILLEGAL, "0x1.5"},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Errorf("%q - tokentype wrong. expected=%q, got=%q", tt.input, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Errorf("%q - literal wrong. expected=%q, got=%q", tt.input, tt.expectedLiteral, tok.Literal)
+		}
+		if next := l.NextToken(); next.Type != token.SEMICOLON && next.Type != token.EOF {
+			t.Errorf("%q - expected the literal to be a single token, got %q after it", tt.input, next.Literal)
+		}
+	}
+}
+
+func TestNumberFollowedByOperator(t *testing.T) {
+	input := "0x1e-1 1e-1 x-1"
+
+	expected := []token.TokenType{
+		token.INT, token.MINUS, token.INT,
+		token.FLOAT,
+		token.IDENT, token.MINUS, token.INT,
+	}
+
+	l := New(input)
+	for i, tt := range expected {
+		tok := l.NextToken()
+		if tok.Type != tt {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt, tok.Type)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []token.TokenType
+	}{
+		{"x // comment\ny", []token.TokenType{token.IDENT, token.SEMICOLON, token.IDENT, token.SEMICOLON}},
+		{"x // comment", []token.TokenType{token.IDENT, token.SEMICOLON}},
+		{"// only a comment", []token.TokenType{}},
+		{"x /* a */ + y", []token.TokenType{token.IDENT, token.PLUS, token.IDENT, token.SEMICOLON}},
+		{"x /* a /* nested */ b */ y", []token.TokenType{token.IDENT, token.IDENT, token.SEMICOLON}},
+		{"x /* spans\nlines */ y", []token.TokenType{token.IDENT, token.SEMICOLON, token.IDENT, token.SEMICOLON}},
+		{"x + /* spans\nlines */ y", []token.TokenType{token.IDENT, token.PLUS, token.IDENT, token.SEMICOLON}},
+		{"x / y", []token.TokenType{token.IDENT, token.SLASH, token.IDENT, token.SEMICOLON}},
+		{"x /* open", []token.TokenType{token.IDENT, token.ILLEGAL}},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		for i, expected := range tt.expected {
+			tok := l.NextToken()
+			if tok.Type != expected {
+				t.Fatalf("%q tests[%d] - tokentype wrong. expected=%q, got=%q", tt.input, i, expected, tok.Type)
+			}
+		}
+		if tok := l.NextToken(); tok.Type != token.EOF && tok.Type != token.ILLEGAL {
+			t.Fatalf("%q - expected EOF, got=%q", tt.input, tok.Type)
+		}
+	}
+}
+
+func TestUnterminatedBlockComment(t *testing.T) {
+	l := New("x /* a /* b */")
+	l.NextToken()
+
+	tok := l.NextToken()
+	if tok.Type != token.ILLEGAL || tok.Literal != "/*" {
+		t.Fatalf("Expected ILLEGAL \"/*\", got %s %q", tok.Type, tok.Literal)
+	}
+	if tok.Pos.Offset != 2 || tok.End.Offset != 4 {
+		t.Fatalf("Expected span 2-4, got %d-%d", tok.Pos.Offset, tok.End.Offset)
+	}
+}
+
+func TestDocComments(t *testing.T) {
+	input := `/// Adds two numbers.
+///   Indented line.
+func add(a, b) {}
+
+/// Detached.
+
+func sub(a, b) {}
+//// Not a doc.
+func mul(a, b) {}
+x /// trailing, not a doc
+func div(a, b) {}`
+
+	expected := map[string]string{
+		"add": "Adds two numbers.\n  Indented line.",
+		"sub": "",
+		"mul": "",
+		"div": "",
+	}
+
+	l := New(input)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		if tok.Type != token.FUNC {
+			continue
+		}
+		fn := tok
+		name := l.NextToken().Literal
+		if doc := l.Doc(fn.Pos); doc != expected[name] {
+			t.Errorf("%s - expected doc %q, got %q", name, expected[name], doc)
+		}
+	}
+}
+
+func TestUnicodeIdentifiers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []token.Token
+	}{
+		{"größe := 1", []token.Token{{Type: token.IDENT, Literal: "größe"}, {Type: token.DECLARE, Literal: ":="}, {Type: token.INT, Literal: "1"}}},
+		{"変数 + x٣", []token.Token{{Type: token.IDENT, Literal: "変数"}, {Type: token.PLUS, Literal: "+"}, {Type: token.IDENT, Literal: "x٣"}}},
+		{"_a1 a_b", []token.Token{{Type: token.IDENT, Literal: "_a1"}, {Type: token.IDENT, Literal: "a_b"}}},
+		{"1x", []token.Token{{Type: token.ILLEGAL, Literal: "1x"}}},
+		{"٣", []token.Token{{Type: token.ILLEGAL, Literal: "٣"}}},
+		{"a € b", []token.Token{{Type: token.IDENT, Literal: "a"}, {Type: token.ILLEGAL, Literal: "€"}, {Type: token.IDENT, Literal: "b"}}},
+		{`"héllo, 世界"`, []token.Token{{Type: token.STRING, Literal: "héllo, 世界"}}},
+		{"\"�\"", []token.Token{{Type: token.STRING, Literal: "�"}}},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		for i, expected := range tt.expected {
+			tok := l.NextToken()
+			if tok.Type != expected.Type || tok.Literal != expected.Literal {
+				t.Fatalf("%q tests[%d] - expected %s %q, got %s %q", tt.input, i, expected.Type, expected.Literal, tok.Type, tok.Literal)
+			}
+		}
+	}
+}
+
+func TestUnicodePositions(t *testing.T) {
+	l := New("größe := \"ä\" + 変数")
+
+	expected := []struct {
+		pos token.Position
+		end token.Position
+	}{
+		{token.Position{Offset: 0, Line: 1, Column: 1}, token.Position{Offset: 7, Line: 1, Column: 8}},
+		{token.Position{Offset: 8, Line: 1, Column: 9}, token.Position{Offset: 10, Line: 1, Column: 11}},
+		{token.Position{Offset: 11, Line: 1, Column: 12}, token.Position{Offset: 15, Line: 1, Column: 16}},
+		{token.Position{Offset: 16, Line: 1, Column: 17}, token.Position{Offset: 17, Line: 1, Column: 18}},
+		{token.Position{Offset: 18, Line: 1, Column: 19}, token.Position{Offset: 24, Line: 1, Column: 25}},
+	}
+
+	for i, tt := range expected {
+		tok := l.NextToken()
+		if tok.Pos != tt.pos || tok.End != tt.end {
+			t.Fatalf("tests[%d] - %q expected span %+v-%+v, got %+v-%+v", i, tok.Literal, tt.pos, tt.end, tok.Pos, tok.End)
+		}
+	}
+}
+
+func TestInvalidUTF8(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected token.Position
+	}{
+		{"x \xff y", token.Position{Offset: 2, Line: 1, Column: 3}},
+		{"x\n\"ab\xc3\"", token.Position{Offset: 5, Line: 2, Column: 4}},
+		{"x // \xe6\x97\ny", token.Position{Offset: 5, Line: 1, Column: 6}},
+		{"/* \n \x80 */", token.Position{Offset: 5, Line: 2, Column: 2}},
+		{"\"${\"\xff\"}\"", token.Position{Offset: 4, Line: 1, Column: 5}},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+		for tok.Type != token.ILLEGAL && tok.Type != token.EOF {
+			tok = l.NextToken()
+		}
+
+		if tok.Type != token.ILLEGAL || len(tok.Literal) != 1 {
+			t.Fatalf("%q - expected ILLEGAL byte, got %s %q", tt.input, tok.Type, tok.Literal)
+		}
+		if tok.Pos != tt.expected {
+			t.Fatalf("%q - expected position %+v, got %+v", tt.input, tt.expected, tok.Pos)
+		}
+	}
+}
+
+var readerInputs = []string{
+	"five = 5\nten = 10\nadd = func(x, y) {\n\tx + y\n}\nresult = add(five, ten)\n",
+	"x := 0x_1F + 1_000 * .5e-3 / 19.99d\n// comment\ny := x >= 1 && x << 2 || !true\n",
+	"/// doc line\nfunc f(größe) { return \"héllo ${größe + \"}\"} 世界\" }\n",
+	"/* a /* nested\n */ block */ x /* spans\nlines */ y",
+	"x := \"unterminated\ny := 1 € 2\n\"a\xffb\" /* open",
+	"",
+}
+
+func TestReaderMatchesString(t *testing.T) {
+	for _, input := range readerInputs {
+		readers := map[string]io.Reader{
+			"reader":     strings.NewReader(input),
+			"one byte":   iotest.OneByteReader(strings.NewReader(input)),
+			"half":       iotest.HalfReader(strings.NewReader(input)),
+			"data error": iotest.DataErrReader(strings.NewReader(input)),
+		}
+
+		for name, r := range readers {
+			expected := New(input)
+			l := NewReader(r)
+			for i := 0; ; i++ {
+				want, got := expected.NextToken(), l.NextToken()
+				if got != want {
+					t.Fatalf("%s %q tests[%d] - expected %+v, got %+v", name, input, i, want, got)
+				}
+				if l.Doc(got.Pos) != expected.Doc(want.Pos) {
+					t.Fatalf("%s %q tests[%d] - expected doc %q, got %q", name, input, i, expected.Doc(want.Pos), l.Doc(got.Pos))
+				}
+				if want.Type == token.EOF {
+					break
+				}
+			}
+			if err := l.Err(); err != nil {
+				t.Fatalf("%s %q - unexpected error %v", name, input, err)
+			}
+		}
+	}
+}
+
+func TestReaderLongTokens(t *testing.T) {
+	ident := strings.Repeat("a", 10000)
+	str := strings.Repeat("é", 5000)
+	input := ident + " /* " + strings.Repeat("x", 10000) + " */ \"" + str + "\""
+
+	l := NewReader(iotest.HalfReader(strings.NewReader(input)))
+
+	expected := []token.Token{
+		{Type: token.IDENT, Literal: ident},
+		{Type: token.STRING, Literal: str},
+		{Type: token.SEMICOLON, Literal: ";"},
+		{Type: token.EOF, Literal: ""},
+	}
+
+	for i, tt := range expected {
+		tok := l.NextToken()
+		if tok.Type != tt.Type || tok.Literal != tt.Literal {
+			t.Fatalf("tests[%d] - expected %s of length %d, got %s of length %d", i, tt.Type, len(tt.Literal), tok.Type, len(tok.Literal))
+		}
+	}
+}
+
+func TestReaderError(t *testing.T) {
+	errRead := errors.New("read failed")
+	r := io.MultiReader(strings.NewReader("x + y"), iotest.ErrReader(errRead))
+
+	l := NewReader(r)
+
+	expected := []token.TokenType{token.IDENT, token.PLUS, token.IDENT, token.SEMICOLON, token.EOF}
+	for i, tt := range expected {
+		tok := l.NextToken()
+		if tok.Type != tt {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt, tok.Type)
+		}
+	}
+
+	if !errors.Is(l.Err(), errRead) {
+		t.Fatalf("Expected error %v, got %v", errRead, l.Err())
+	}
+}
+
+func benchmarkInput() string {
+	return strings.Repeat(readerInputs[0]+readerInputs[1]+readerInputs[2], 1000)
+}
+
+func BenchmarkLexer(b *testing.B) {
+	input := benchmarkInput()
+	b.SetBytes(int64(len(input)))
+
+	for b.Loop() {
+		l := New(input)
+		for l.NextToken().Type != token.EOF {
+		}
+	}
+}
+
+func BenchmarkReaderLexer(b *testing.B) {
+	input := benchmarkInput()
+	b.SetBytes(int64(len(input)))
+
+	for b.Loop() {
+		l := NewReader(strings.NewReader(input))
+		for l.NextToken().Type != token.EOF {
+		}
+	}
+}
@@ -1,14 +1,44 @@
 package main
 
 import (
+	"bytes"
+	"flag"
 	"fmt"
 	"os"
 	"os/user"
+	"strings"
 
+	"github.com/chaitanya-Uike/lemon/ast"
+	"github.com/chaitanya-Uike/lemon/checker"
+	"github.com/chaitanya-Uike/lemon/compiler"
+	"github.com/chaitanya-Uike/lemon/diagnostic"
+	"github.com/chaitanya-Uike/lemon/evaluator"
+	"github.com/chaitanya-Uike/lemon/lexer"
+	"github.com/chaitanya-Uike/lemon/object"
+	"github.com/chaitanya-Uike/lemon/optimizer"
+	"github.com/chaitanya-Uike/lemon/parser"
 	"github.com/chaitanya-Uike/lemon/repl"
+	"github.com/chaitanya-Uike/lemon/resolver"
+	"github.com/chaitanya-Uike/lemon/vm"
 )
 
+const usage = `usage:
+  lemon                                         start the repl
+  lemon [-strict] file.lm                       run a script
+  lemon file.lmc                                run a compiled script
+  lemon build [-O] [-strict] file.lm [-o out]   compile a script to bytecode
+  lemon disasm [-O] file                        list the bytecode of a script
+  lemon check file.lm                           type check a script
+
+  -O optimizes the script before compiling it
+  -strict type checks the script first and stops on type errors
+`
+
 func main() {
+	if len(os.Args) > 1 {
+		os.Exit(run(os.Args[1:]))
+	}
+
 	user, err := user.Current()
 	if err != nil {
 		panic(err)
@@ -18,3 +48,225 @@ func main() {
 	fmt.Printf("Feel free to type in commands\n")
 	repl.Start(os.Stdin, os.Stdout)
 }
+
+func run(args []string) int {
+	switch args[0] {
+	case "build":
+		return buildCommand(args[1:])
+	case "disasm":
+		return disasmCommand(args[1:])
+	case "check":
+		return checkCommand(args[1:])
+	case "-h", "-help", "--help":
+		fmt.Print(usage)
+		return 0
+	}
+	return runCommand(args)
+}
+
+func runCommand(args []string) int {
+	fs := flag.NewFlagSet("lemon", flag.ContinueOnError)
+	strict := fs.Bool("strict", false, "type check the script first")
+	files, err := parseFlags(fs, args)
+	if err != nil {
+		return 2
+	}
+	if len(files) != 1 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+	return runFile(files[0], *strict)
+}
+
+func runFile(filename string, strict bool) int {
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if bytes.HasPrefix(src, []byte(compiler.Magic)) {
+		return runBytecode(filename, src)
+	}
+
+	program, ok := parse(filename, src, strict)
+	if !ok {
+		return 1
+	}
+
+	result := evaluator.Eval(program, object.NewEnvironment())
+	if errObj, ok := result.(*object.Error); ok {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", filename, errObj.Inspect())
+		return 1
+	}
+	return 0
+}
+
+func runBytecode(filename string, data []byte) int {
+	bytecode := &compiler.Bytecode{}
+	if err := bytecode.UnmarshalBinary(data); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", filename, err)
+		return 1
+	}
+
+	machine := vm.New(bytecode)
+	if err := machine.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: ERROR: %s\n", filename, err)
+		return 1
+	}
+	return 0
+}
+
+func buildCommand(args []string) int {
+	fs := flag.NewFlagSet("build", flag.ContinueOnError)
+	output := fs.String("o", "", "write the bytecode to `file`, by default the script name with an .lmc extension")
+	optimize := fs.Bool("O", false, "optimize the script")
+	strict := fs.Bool("strict", false, "type check the script first")
+	files, err := parseFlags(fs, args)
+	if err != nil {
+		return 2
+	}
+	if len(files) != 1 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
+	filename := files[0]
+	if *output == "" {
+		*output = strings.TrimSuffix(filename, ".lm") + ".lmc"
+	}
+
+	bytecode, ok := compileFile(filename, *optimize, *strict)
+	if !ok {
+		return 1
+	}
+	data, err := bytecode.MarshalBinary()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", filename, err)
+		return 1
+	}
+	if err := os.WriteFile(*output, data, 0o644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// disasmCommand lists a compiled script, a source file is compiled first.
+func disasmCommand(args []string) int {
+	fs := flag.NewFlagSet("disasm", flag.ContinueOnError)
+	optimize := fs.Bool("O", false, "optimize the script")
+	files, err := parseFlags(fs, args)
+	if err != nil {
+		return 2
+	}
+	if len(files) != 1 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+	filename := files[0]
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	bytecode := &compiler.Bytecode{}
+	if strings.HasSuffix(filename, ".lmc") || bytes.HasPrefix(data, []byte(compiler.Magic)) {
+		if err := bytecode.UnmarshalBinary(data); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", filename, err)
+			return 1
+		}
+	} else {
+		var ok bool
+		if bytecode, ok = compileFile(filename, *optimize, false); !ok {
+			return 1
+		}
+	}
+
+	fmt.Print(bytecode.Disassemble())
+	return 0
+}
+
+// checkCommand reports the type errors of a script without running it.
+func checkCommand(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
+	src, err := os.ReadFile(args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if _, ok := parse(args[0], src, true); !ok {
+		return 1
+	}
+	return 0
+}
+
+func compileFile(filename string, optimize, strict bool) (*compiler.Bytecode, bool) {
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return nil, false
+	}
+
+	program, ok := parse(filename, src, strict)
+	if !ok {
+		return nil, false
+	}
+
+	if optimize {
+		optimizer.Optimize(program, optimizer.All)
+	}
+
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: ERROR: %s\n", filename, err)
+		return nil, false
+	}
+
+	bytecode := comp.Bytecode()
+	bytecode.Source = filename
+	return bytecode, true
+}
+
+// parse parses and resolves src, type checking it too when strict,
+// rendering its diagnostics if there are any.
+func parse(filename string, src []byte, strict bool) (*ast.Program, bool) {
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+
+	diagnostics := p.Diagnostics()
+	if len(diagnostics) == 0 {
+		diagnostics = resolver.Resolve(program).Diagnostics
+	}
+	if len(diagnostics) == 0 && strict {
+		diagnostics = checker.Check(program).Diagnostics
+	}
+	if len(diagnostics) != 0 {
+		r := diagnostic.NewRenderer(string(src), filename, diagnostic.IsTerminal(os.Stderr))
+		r.RenderAll(os.Stderr, diagnostics)
+		return nil, false
+	}
+	return program, true
+}
+
+// parseFlags parses args allowing flags after the file names, which the flag
+// package stops at, and returns the file names.
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	var files []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return files, nil
+		}
+		files = append(files, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
@@ -0,0 +1,57 @@
+package object
+
+// Environment maps names to values for a single scope. Scopes are chained
+// through outer: the program has the root environment, every block, if
+// branch and function call gets one enclosed by the scope it runs in, and a
+// function value keeps the environment it was created in so its body can
+// reach (and mutate) the variables it closes over.
+type Environment struct {
+	store map[string]Object
+	outer *Environment
+}
+
+func NewEnvironment() *Environment {
+	return &Environment{store: make(map[string]Object)}
+}
+
+func NewEnclosedEnvironment(outer *Environment) *Environment {
+	env := NewEnvironment()
+	env.outer = outer
+	return env
+}
+
+func (e *Environment) Get(name string) (Object, bool) {
+	obj, ok := e.store[name]
+	if !ok && e.outer != nil {
+		return e.outer.Get(name)
+	}
+	return obj, ok
+}
+
+func (e *Environment) Set(name string, val Object) Object {
+	e.store[name] = val
+	return val
+}
+
+// Declare binds name in this environment only, it fails if the name is
+// already bound here. Bindings in outer environments are shadowed.
+func (e *Environment) Declare(name string, val Object) bool {
+	if _, ok := e.store[name]; ok {
+		return false
+	}
+	e.store[name] = val
+	return true
+}
+
+// Assign rebinds name in the nearest environment that declares it, it fails
+// if no environment in the chain does.
+func (e *Environment) Assign(name string, val Object) bool {
+	if _, ok := e.store[name]; ok {
+		e.store[name] = val
+		return true
+	}
+	if e.outer != nil {
+		return e.outer.Assign(name, val)
+	}
+	return false
+}
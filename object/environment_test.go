@@ -0,0 +1,47 @@
+package object
+
+import "testing"
+
+func TestEnvironmentChain(t *testing.T) {
+	global := NewEnvironment()
+	global.Declare("a", &Integer{Value: 1})
+
+	inner := NewEnclosedEnvironment(global)
+
+	if val, ok := inner.Get("a"); !ok || val.(*Integer).Value != 1 {
+		t.Fatalf("Expected inner to see outer binding a=1, got %v (%t)", val, ok)
+	}
+
+	if !inner.Declare("a", &Integer{Value: 2}) {
+		t.Fatalf("Expected inner declaration to shadow outer binding")
+	}
+	if inner.Declare("a", &Integer{Value: 3}) {
+		t.Fatalf("Expected redeclaration in the same scope to fail")
+	}
+
+	if val, _ := global.Get("a"); val.(*Integer).Value != 1 {
+		t.Fatalf("Expected shadowing to leave outer binding untouched, got %d", val.(*Integer).Value)
+	}
+
+	if !inner.Assign("a", &Integer{Value: 4}) {
+		t.Fatalf("Expected assignment to a declared name to succeed")
+	}
+	if val, _ := inner.Get("a"); val.(*Integer).Value != 4 {
+		t.Fatalf("Expected inner a=4, got %d", val.(*Integer).Value)
+	}
+	if val, _ := global.Get("a"); val.(*Integer).Value != 1 {
+		t.Fatalf("Expected assignment to hit the nearest scope, outer a changed to %d", val.(*Integer).Value)
+	}
+
+	global.Declare("b", &Integer{Value: 1})
+	if !inner.Assign("b", &Integer{Value: 5}) {
+		t.Fatalf("Expected assignment to reach the outer scope")
+	}
+	if val, _ := global.Get("b"); val.(*Integer).Value != 5 {
+		t.Fatalf("Expected outer b=5, got %d", val.(*Integer).Value)
+	}
+
+	if inner.Assign("c", &Integer{Value: 1}) {
+		t.Fatalf("Expected assignment to an undeclared name to fail")
+	}
+}
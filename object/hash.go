@@ -0,0 +1,145 @@
+package object
+
+import (
+	"bytes"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// HashKey identifies a key of a hash. Keys that fit in 64 bits are kept in
+// Value, the others in Text so that distinct keys never collide.
+type HashKey struct {
+	Type  ObjectType
+	Value uint64
+	Text  string
+}
+
+// Hashable is implemented by the objects that can be used as hash keys.
+// Objects that compare equal with == must produce the same HashKey.
+type Hashable interface {
+	Object
+	HashKey() HashKey
+}
+
+func (i *Integer) HashKey() HashKey {
+	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
+}
+
+func (bi *BigInteger) HashKey() HashKey {
+	return bigIntHashKey(bi.Value)
+}
+
+// bigIntHashKey is the key of any integral number. Those outside int64 can
+// still equal a float, they share its key when the conversion is exact.
+func bigIntHashKey(i *big.Int) HashKey {
+	if i.IsInt64() {
+		return HashKey{Type: INTEGER_OBJ, Value: uint64(i.Int64())}
+	}
+	if f, acc := new(big.Float).SetInt(i).Float64(); acc == big.Exact {
+		return HashKey{Type: FLOAT_OBJ, Value: math.Float64bits(f)}
+	}
+	return HashKey{Type: INTEGER_OBJ, Text: i.String()}
+}
+
+// HashKey of an integral decimal is that of the equal integer, 2d == 2.
+func (d *Decimal) HashKey() HashKey {
+	if d.Value.IsInteger() {
+		return bigIntHashKey(d.Value.Int())
+	}
+	return HashKey{Type: d.Type(), Text: d.Value.Normalize().String()}
+}
+
+// HashKey of an integral float is the key of the equal integer, 1 == 1.0 so
+// both must find the same entry.
+func (f *Float) HashKey() HashKey {
+	if f.Value == math.Trunc(f.Value) && f.Value >= math.MinInt64 && f.Value < math.MaxInt64 {
+		return HashKey{Type: INTEGER_OBJ, Value: uint64(int64(f.Value))}
+	}
+	return HashKey{Type: f.Type(), Value: math.Float64bits(f.Value)}
+}
+
+func (b *Boolean) HashKey() HashKey {
+	var value uint64
+	if b.Value {
+		value = 1
+	}
+	return HashKey{Type: b.Type(), Value: value}
+}
+
+func (s *String) HashKey() HashKey {
+	return HashKey{Type: s.Type(), Text: s.Value}
+}
+
+type HashPair struct {
+	Key   Hashable
+	Value Object
+}
+
+// Hash is a map that remembers insertion order, which is the order its pairs
+// are inspected and iterated in.
+type Hash struct {
+	pairs map[HashKey]HashPair
+	keys  []HashKey
+}
+
+func NewHash() *Hash {
+	return &Hash{pairs: make(map[HashKey]HashPair)}
+}
+
+func (h *Hash) Type() ObjectType { return HASH_OBJ }
+func (h *Hash) Inspect() string  { return h.inspect(make(map[Object]bool)) }
+
+func (h *Hash) inspect(visiting map[Object]bool) string {
+	if visiting[h] {
+		return "{...}"
+	}
+	visiting[h] = true
+	defer delete(visiting, h)
+
+	var out bytes.Buffer
+
+	pairs := []string{}
+	for _, pair := range h.Pairs() {
+		pairs = append(pairs, inspectKey(pair.Key)+": "+inspect(pair.Value, visiting))
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+func (h *Hash) Get(key Hashable) (Object, bool) {
+	pair, ok := h.pairs[key.HashKey()]
+	return pair.Value, ok
+}
+
+func (h *Hash) Set(key Hashable, value Object) {
+	hk := key.HashKey()
+	if existing, ok := h.pairs[hk]; ok {
+		h.pairs[hk] = HashPair{Key: existing.Key, Value: value}
+		return
+	}
+	h.pairs[hk] = HashPair{Key: key, Value: value}
+	h.keys = append(h.keys, hk)
+}
+
+func (h *Hash) Len() int { return len(h.keys) }
+
+// Pairs returns the pairs of h in insertion order.
+func (h *Hash) Pairs() []HashPair {
+	pairs := make([]HashPair, 0, len(h.keys))
+	for _, hk := range h.keys {
+		pairs = append(pairs, h.pairs[hk])
+	}
+	return pairs
+}
+
+func inspectKey(key Hashable) string {
+	if s, ok := key.(*String); ok {
+		return `"` + s.Value + `"`
+	}
+	return key.Inspect()
+}
@@ -0,0 +1,109 @@
+package object
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/chaitanya-Uike/lemon/decimal"
+)
+
+func TestStringHashKey(t *testing.T) {
+	hello1 := &String{Value: "Hello World"}
+	hello2 := &String{Value: "Hello World"}
+	diff1 := &String{Value: "My name is johnny"}
+	diff2 := &String{Value: "My name is johnny"}
+
+	if hello1.HashKey() != hello2.HashKey() {
+		t.Errorf("strings with same content have different hash keys")
+	}
+
+	if diff1.HashKey() != diff2.HashKey() {
+		t.Errorf("strings with same content have different hash keys")
+	}
+
+	if hello1.HashKey() == diff1.HashKey() {
+		t.Errorf("strings with different content have same hash keys")
+	}
+}
+
+// TestHashKeysDontCollide checks that strings are keyed by their content
+// rather than by a hash of it, which two strings could share.
+func TestHashKeysDontCollide(t *testing.T) {
+	s := &String{Value: "costarring"}
+	if key := s.HashKey(); key != (HashKey{Type: STRING_OBJ, Text: "costarring"}) {
+		t.Errorf("Expected the key to hold the string, got %+v", key)
+	}
+
+	huge, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	if key := (&BigInteger{Value: huge}).HashKey(); key.Text != huge.String() {
+		t.Errorf("Expected the key to hold the integer, got %+v", key)
+	}
+}
+
+func TestNumericHashKeys(t *testing.T) {
+	if (&Integer{Value: 1}).HashKey() != (&Float{Value: 1}).HashKey() {
+		t.Errorf("1 and 1.0 compare equal but have different hash keys")
+	}
+
+	if (&Float{Value: 0}).HashKey() != (&Float{Value: -0.0}).HashKey() {
+		t.Errorf("0.0 and -0.0 compare equal but have different hash keys")
+	}
+
+	if (&Integer{Value: 1}).HashKey() == (&Float{Value: 1.5}).HashKey() {
+		t.Errorf("1 and 1.5 have the same hash key")
+	}
+
+	if (&Integer{Value: 1}).HashKey() == (&Boolean{Value: true}).HashKey() {
+		t.Errorf("1 and true have the same hash key")
+	}
+}
+
+func TestHashOrder(t *testing.T) {
+	h := NewHash()
+	h.Set(&String{Value: "b"}, &Integer{Value: 1})
+	h.Set(&String{Value: "a"}, &Integer{Value: 2})
+	h.Set(&String{Value: "b"}, &Integer{Value: 3})
+
+	if h.Len() != 2 {
+		t.Fatalf("Expected %d pairs, got %d", 2, h.Len())
+	}
+
+	if h.Inspect() != `{"b": 3, "a": 2}` {
+		t.Fatalf("Expected pairs in insertion order, got %s", h.Inspect())
+	}
+
+	if val, ok := h.Get(&String{Value: "a"}); !ok || val.(*Integer).Value != 2 {
+		t.Fatalf("Expected a=2, got %v (%t)", val, ok)
+	}
+
+	if _, ok := h.Get(&String{Value: "c"}); ok {
+		t.Fatalf("Expected missing key to not be found")
+	}
+}
+
+func TestBigAndDecimalHashKeys(t *testing.T) {
+	big20, _ := new(big.Int).SetString("100000000000000000000", 10)
+	odd, _ := new(big.Int).SetString("100000000000000000001", 10)
+
+	two, _ := decimal.Parse("2.00")
+	half, _ := decimal.Parse("0.5")
+	halfPadded, _ := decimal.Parse("0.50")
+
+	tests := []struct {
+		name  string
+		a, b  Hashable
+		equal bool
+	}{
+		{"big and equal float", &BigInteger{Value: big20}, &Float{Value: 1e20}, true},
+		{"big and its negation", &BigInteger{Value: odd}, &BigInteger{Value: new(big.Int).Neg(odd)}, false},
+		{"integral decimal and integer", &Decimal{Value: two}, &Integer{Value: 2}, true},
+		{"decimals with different scales", &Decimal{Value: half}, &Decimal{Value: halfPadded}, true},
+		{"decimal and float", &Decimal{Value: half}, &Float{Value: 0.5}, false},
+	}
+
+	for _, tt := range tests {
+		if (tt.a.HashKey() == tt.b.HashKey()) != tt.equal {
+			t.Errorf("%s - expected equal keys to be %t", tt.name, tt.equal)
+		}
+	}
+}
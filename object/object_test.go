@@ -0,0 +1,27 @@
+package object
+
+import "testing"
+
+func TestInspect(t *testing.T) {
+	tests := []struct {
+		obj      Object
+		expected string
+	}{
+		{&Integer{Value: 42}, "42"},
+		{&Integer{Value: -7}, "-7"},
+		{&Float{Value: 2}, "2.0"},
+		{&Float{Value: 2.5}, "2.5"},
+		{&Float{Value: -0.125}, "-0.125"},
+		{&Float{Value: 1e21}, "1e+21"},
+		{&Boolean{Value: true}, "true"},
+		{&Null{}, "null"},
+		{&ReturnValue{Value: &Integer{Value: 1}}, "1"},
+		{&Error{Message: "boom"}, "ERROR: boom"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.obj.Inspect(); got != tt.expected {
+			t.Errorf("Expected %T.Inspect()=%q, got %q", tt.obj, tt.expected, got)
+		}
+	}
+}
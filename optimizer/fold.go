@@ -0,0 +1,146 @@
+package optimizer
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/chaitanya-Uike/lemon/ast"
+	"github.com/chaitanya-Uike/lemon/evaluator"
+	"github.com/chaitanya-Uike/lemon/object"
+	"github.com/chaitanya-Uike/lemon/token"
+)
+
+// The folds compute values with the evaluator's own operators, so a folded
+// expression has exactly the value it would have had at runtime.
+
+func foldPrefix(pe *ast.PrefixExpression) ast.Expression {
+	right, ok := constant(pe.Expression)
+	if !ok {
+		return pe
+	}
+	return literal(evaluator.EvalPrefix(pe.Operator, right), pe)
+}
+
+func foldInfix(ie *ast.InfixExpression) ast.Expression {
+	left, ok := constant(ie.Left)
+	if !ok {
+		return ie
+	}
+
+	if ie.Operator == "&&" || ie.Operator == "||" {
+		// the left operand alone may decide the result, the right one is
+		// then never evaluated
+		if evaluator.IsTruthy(left) == (ie.Operator == "||") {
+			return literal(boolean(evaluator.IsTruthy(left)), ie)
+		}
+		right, ok := constant(ie.Right)
+		if !ok {
+			return ie
+		}
+		return literal(boolean(evaluator.IsTruthy(right)), ie)
+	}
+
+	right, ok := constant(ie.Right)
+	if !ok {
+		return ie
+	}
+	return literal(evaluator.EvalInfix(ie.Operator, left, right), ie)
+}
+
+// constant returns the value of a literal expression.
+func constant(exp ast.Expression) (object.Object, bool) {
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral:
+		if exp.Big != nil {
+			return object.NewInteger(exp.Big), true
+		}
+		return &object.Integer{Value: exp.Value}, true
+	case *ast.FloatLiteral:
+		return &object.Float{Value: exp.Value}, true
+	case *ast.DecimalLiteral:
+		return &object.Decimal{Value: exp.Value}, true
+	case *ast.StringLiteral:
+		return &object.String{Value: exp.Value}, true
+	case *ast.BooleanLiteral:
+		return boolean(exp.Value), true
+	}
+	return nil, false
+}
+
+func boolean(b bool) object.Object {
+	if b {
+		return evaluator.TRUE
+	}
+	return evaluator.FALSE
+}
+
+// literal returns the literal for val that replaces exp, or exp itself when
+// val can't be written as a literal or is an error left for runtime.
+func literal(val object.Object, exp ast.Expression) ast.Expression {
+	tok := token.Token{Pos: exp.Pos(), End: exp.End()}
+
+	switch val := val.(type) {
+	case *object.Integer:
+		tok.Type, tok.Literal = token.INT, val.Inspect()
+		return &ast.IntegerLiteral{Token: tok, Value: val.Value}
+	case *object.BigInteger:
+		tok.Type, tok.Literal = token.INT, val.Inspect()
+		return &ast.IntegerLiteral{Token: tok, Big: val.Value}
+	case *object.Float:
+		// infinities and NaN have no literal
+		if math.IsInf(val.Value, 0) || math.IsNaN(val.Value) {
+			return exp
+		}
+		tok.Type, tok.Literal = token.FLOAT, val.Inspect()
+		return &ast.FloatLiteral{Token: tok, Value: val.Value}
+	case *object.Decimal:
+		tok.Type, tok.Literal = token.DECIMAL, val.Inspect()+"d"
+		return &ast.DecimalLiteral{Token: tok, Value: val.Value}
+	case *object.String:
+		if !utf8.ValidString(val.Value) {
+			return exp
+		}
+		tok.Type, tok.Literal = token.STRING, escape(val.Value)
+		return &ast.StringLiteral{Token: tok, Value: val.Value}
+	case *object.Boolean:
+		tok.Type, tok.Literal = token.FALSE, "false"
+		if val.Value {
+			tok.Type, tok.Literal = token.TRUE, "true"
+		}
+		return &ast.BooleanLiteral{Token: tok, Value: val.Value}
+	}
+	return exp
+}
+
+// escape writes s the way it is written between the quotes of a string
+// literal, with the escapes the parser decodes. Dollar signs are always
+// escaped so that they can't start an interpolation.
+func escape(s string) string {
+	var out strings.Builder
+	for _, r := range s {
+		switch r {
+		case '\\':
+			out.WriteString(`\\`)
+		case '"':
+			out.WriteString(`\"`)
+		case '$':
+			out.WriteString(`\$`)
+		case '\n':
+			out.WriteString(`\n`)
+		case '\t':
+			out.WriteString(`\t`)
+		case '\r':
+			out.WriteString(`\r`)
+		default:
+			if unicode.IsPrint(r) {
+				out.WriteRune(r)
+			} else {
+				fmt.Fprintf(&out, `\u{%x}`, r)
+			}
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,185 @@
+// Package optimizer rewrites a parsed program into a simpler one with the
+// same behaviour, before it is evaluated or compiled.
+package optimizer
+
+import (
+	"github.com/chaitanya-Uike/lemon/ast"
+	"github.com/chaitanya-Uike/lemon/evaluator"
+)
+
+// Options selects the passes to run.
+type Options struct {
+	// FoldConstants replaces prefix and infix expressions over literals by
+	// their value. Expressions that fail, like 1 / 0, are left to fail at
+	// runtime.
+	FoldConstants bool
+	// DeadBranches replaces if statements with a literal condition by the
+	// branch that is taken.
+	DeadBranches bool
+	// Unreachable removes the statements following a return, break or
+	// continue in the same block.
+	Unreachable bool
+}
+
+// All runs every pass.
+var All = Options{FoldConstants: true, DeadBranches: true, Unreachable: true}
+
+// Optimize rewrites program in place. The passes run together in a single
+// walk, bottom up, so a condition folded to a literal also gets its dead
+// branch removed.
+func Optimize(program *ast.Program, opts Options) {
+	o := &optimizer{opts: opts}
+	program.Statements = o.statements(program.Statements)
+}
+
+type optimizer struct {
+	opts Options
+}
+
+func (o *optimizer) statements(stmts []ast.Statement) []ast.Statement {
+	out := stmts[:0]
+	for i, stmt := range stmts {
+		stmt = o.statement(stmt)
+
+		// an empty block does nothing, unless its null is the value of the
+		// list
+		if block, ok := stmt.(*ast.BlockStatement); ok && o.opts.DeadBranches && len(block.Statements) == 0 && i < len(stmts)-1 {
+			continue
+		}
+		out = append(out, stmt)
+
+		if o.opts.Unreachable && isTerminating(stmt) {
+			break
+		}
+	}
+	return out
+}
+
+func isTerminating(stmt ast.Statement) bool {
+	switch stmt.(type) {
+	case *ast.ReturnStatement, *ast.BranchStatement:
+		return true
+	}
+	return false
+}
+
+func (o *optimizer) statement(stmt ast.Statement) ast.Statement {
+	switch stmt := stmt.(type) {
+	case *ast.ExpressionStatement:
+		stmt.Expression = o.expression(stmt.Expression)
+	case *ast.BlockStatement:
+		o.block(stmt)
+	case *ast.IfStatement:
+		return o.ifStatement(stmt)
+	case *ast.ReturnStatement:
+		if stmt.ReturnValue != nil {
+			stmt.ReturnValue = o.expression(stmt.ReturnValue)
+		}
+	case *ast.DeclareStatement:
+		stmt.Value = o.expression(stmt.Value)
+	case *ast.FunctionStatement:
+		o.block(stmt.Function.Body)
+	case *ast.AssignStatement:
+		stmt.Target = o.expression(stmt.Target)
+		stmt.Value = o.expression(stmt.Value)
+	case *ast.WhileStatement:
+		stmt.Condition = o.expression(stmt.Condition)
+		o.block(stmt.Body)
+	case *ast.ForInStatement:
+		stmt.Iterable = o.expression(stmt.Iterable)
+		o.block(stmt.Body)
+	case *ast.ForStatement:
+		if stmt.Init != nil {
+			stmt.Init = o.statement(stmt.Init)
+		}
+		if stmt.Condition != nil {
+			stmt.Condition = o.expression(stmt.Condition)
+		}
+		if stmt.Post != nil {
+			stmt.Post = o.statement(stmt.Post)
+		}
+		o.block(stmt.Body)
+	case *ast.LabeledStatement:
+		stmt.Statement = o.statement(stmt.Statement)
+	}
+	return stmt
+}
+
+func (o *optimizer) block(block *ast.BlockStatement) {
+	block.Statements = o.statements(block.Statements)
+}
+
+// ifStatement returns the branch an if statement with a literal condition
+// takes, it is a block so its declarations stay local to it. With no branch
+// taken that is an empty block, which evaluates to null like the if.
+func (o *optimizer) ifStatement(is *ast.IfStatement) ast.Statement {
+	is.Condition = o.expression(is.Condition)
+	o.block(is.Consequence)
+	if is.Alternate != nil {
+		is.Alternate = o.statement(is.Alternate).(ast.AlternateStatement)
+	}
+
+	if !o.opts.DeadBranches {
+		return is
+	}
+	condition, ok := constant(is.Condition)
+	if !ok {
+		return is
+	}
+
+	switch {
+	case evaluator.IsTruthy(condition):
+		return is.Consequence
+	case is.Alternate != nil:
+		return is.Alternate
+	default:
+		return &ast.BlockStatement{Token: is.Token, Rbrace: is.Consequence.Rbrace}
+	}
+}
+
+func (o *optimizer) expression(exp ast.Expression) ast.Expression {
+	switch exp := exp.(type) {
+	case *ast.PrefixExpression:
+		exp.Expression = o.expression(exp.Expression)
+		if o.opts.FoldConstants {
+			return foldPrefix(exp)
+		}
+	case *ast.InfixExpression:
+		exp.Left = o.expression(exp.Left)
+		exp.Right = o.expression(exp.Right)
+		if o.opts.FoldConstants {
+			return foldInfix(exp)
+		}
+	case *ast.InterpolatedString:
+		for i, part := range exp.Parts {
+			exp.Parts[i] = o.expression(part)
+		}
+	case *ast.FunctionLiteral:
+		o.block(exp.Body)
+	case *ast.CallExpression:
+		exp.Function = o.expression(exp.Function)
+		for i, arg := range exp.Arguments {
+			exp.Arguments[i] = o.expression(arg)
+		}
+	case *ast.ArrayLiteral:
+		for i, element := range exp.Elements {
+			exp.Elements[i] = o.expression(element)
+		}
+	case *ast.HashLiteral:
+		for i, pair := range exp.Pairs {
+			exp.Pairs[i] = ast.HashPair{Key: o.expression(pair.Key), Value: o.expression(pair.Value)}
+		}
+	case *ast.IndexExpression:
+		exp.Left = o.expression(exp.Left)
+		exp.Index = o.expression(exp.Index)
+	case *ast.SliceExpression:
+		exp.Left = o.expression(exp.Left)
+		if exp.Low != nil {
+			exp.Low = o.expression(exp.Low)
+		}
+		if exp.High != nil {
+			exp.High = o.expression(exp.High)
+		}
+	}
+	return exp
+}
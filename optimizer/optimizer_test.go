@@ -0,0 +1,232 @@
+package optimizer
+
+import (
+	"testing"
+
+	"github.com/chaitanya-Uike/lemon/ast"
+	"github.com/chaitanya-Uike/lemon/compiler"
+	"github.com/chaitanya-Uike/lemon/evaluator"
+	"github.com/chaitanya-Uike/lemon/lexer"
+	"github.com/chaitanya-Uike/lemon/object"
+	"github.com/chaitanya-Uike/lemon/parser"
+	"github.com/chaitanya-Uike/lemon/vm"
+)
+
+// testOptimize parses input and runs the passes of opts on it.
+func testOptimize(t *testing.T, input string, opts Options) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		for _, msg := range p.Errors() {
+			t.Errorf("parser error: %q", msg)
+		}
+		t.FailNow()
+	}
+
+	Optimize(program, opts)
+	return program
+}
+
+func TestFoldConstants(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(2 * 60) * 60", "7200"},
+		{"x * (2 + 3)", "(x * 5)"},
+		{"-(1 + 2)", "-3"},
+		{"!true", "false"},
+		{"9223372036854775807 + 1", "9223372036854775808"},
+		{"1 + 0.5", "1.5"},
+		{"4 / 2.0", "2.0"},
+		{"1.0 / 0.0", "(1.0 / 0.0)"},
+		{"x := -1.0 / 0.0", "x := (-1.0 / 0.0)"},
+		{"0.1d + 0.2d", "0.3d"},
+		{`"a" + "b"`, `"ab"`},
+		{`"a" + "\n"`, `"a\n"`},
+		{`"\u{1}" + "é"`, `"\u{1}é"`},
+		{`"$" + "{x}"`, `"\${x}"`},
+		{`"\"" + "\\"`, `"\"\\"`},
+		{"1 < 2 == true", "true"},
+		{"false && f()", "false"},
+		{"1 || f()", "true"},
+		{"true && 0", "true"},
+		{"true && !1", "false"},
+		{"true && f()", "(true && f())"},
+		{"1 / 0", "(1 / 0)"},
+		{"5 + true", "(5 + true)"},
+		{"[1 + 1, {2 * 2: -(-3)}][0]", "([2, {4: 3}][0])"},
+		{`"${1 + 2}"`, `"${1 + 2}"`},
+		{"func(x) { x + 2 * 3 }", "func(x) {(x + 6)}"},
+	}
+
+	for _, tt := range tests {
+		program := testOptimize(t, tt.input, Options{FoldConstants: true})
+
+		if program.String() != tt.expected {
+			t.Errorf("%s - expected %s, got %s", tt.input, tt.expected, program.String())
+		}
+	}
+}
+
+func TestFoldedPositions(t *testing.T) {
+	program := testOptimize(t, "x := 1\ny := (2 *\n 3)", Options{FoldConstants: true})
+
+	value := program.Statements[1].(*ast.DeclareStatement).Value
+	if _, ok := value.(*ast.IntegerLiteral); !ok {
+		t.Fatalf("Expected *ast.IntegerLiteral, got %T", value)
+	}
+	if value.Pos().Line != 2 || value.Pos().Column != 7 || value.End().Line != 3 {
+		t.Errorf("Expected the literal to span 2:7 to line 3, got %v to %v", value.Pos(), value.End())
+	}
+}
+
+func TestFoldedStringsReparse(t *testing.T) {
+	inputs := []string{
+		`"\u{1}" + "é"`,
+		`"$" + "{x}"`,
+		`"\"\\" + "\t\r\n"`,
+		`"\u{7f}" + "\u{2028}" + "\u{1f34b}"`,
+	}
+
+	for _, input := range inputs {
+		expected := evaluate(testOptimize(t, input, Options{}))
+
+		program := testOptimize(t, input, Options{FoldConstants: true})
+		if _, ok := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.StringLiteral); !ok {
+			t.Errorf("%s - expected the string to be folded, got %s", input, program.String())
+			continue
+		}
+
+		reparsed := testOptimize(t, program.String(), Options{})
+		if got := evaluate(reparsed); got != expected {
+			t.Errorf("%s - expected %q after printing %s, got %q", input, expected, program.String(), got)
+		}
+	}
+}
+
+func TestDeadBranches(t *testing.T) {
+	tests := []struct {
+		input    string
+		opts     Options
+		expected string
+	}{
+		{"if true { 1 } else { 2 }", Options{DeadBranches: true}, "{1}"},
+		{"if false { 1 } else { 2 }", Options{DeadBranches: true}, "{2}"},
+		{"if false { 1 } else if x { 2 } else { 3 }", Options{DeadBranches: true}, "if x {2}else {3}"},
+		{"if x { 1 } else if true { 2 } else { 3 }", Options{DeadBranches: true}, "if x {1}else {2}"},
+		{"if false { 1 }", Options{DeadBranches: true}, "{}"},
+		{"if false { 1 }\n2", Options{DeadBranches: true}, "2"},
+		{"if 0 { 1 }\n2", Options{DeadBranches: true}, "{1}2"},
+		{"if 1 > 2 { 1 }\n2", Options{DeadBranches: true}, "if (1 > 2) {1}2"},
+		{"if 1 > 2 { 1 }\n2", All, "2"},
+		{"if true { 1 }", Options{}, "if true {1}"},
+	}
+
+	for _, tt := range tests {
+		program := testOptimize(t, tt.input, tt.opts)
+
+		if program.String() != tt.expected {
+			t.Errorf("%s - expected %s, got %s", tt.input, tt.expected, program.String())
+		}
+	}
+}
+
+func TestUnreachable(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"return 1; 2; 3", "return 1"},
+		{"func f() { x := 1; return x; puts(x) }", "func f() {x := 1return x}"},
+		{"while true { break; puts(1) }", "while true {break}"},
+		{"for x in xs { if x { continue; puts(x) }\nputs(x) }", "for x in xs {if x {continue}puts(x)}"},
+		{"if x { return 1 } else { return 2 }\n3", "if x {return 1}else {return 2}3"},
+	}
+
+	for _, tt := range tests {
+		program := testOptimize(t, tt.input, Options{Unreachable: true})
+
+		if program.String() != tt.expected {
+			t.Errorf("%s - expected %s, got %s", tt.input, tt.expected, program.String())
+		}
+	}
+}
+
+// TestOptimizedResults checks that the programs give the same results with
+// any of the passes as without them. The evaluator and the vm only agree on
+// programs the resolver accepts, the inputs have to be such programs.
+func TestOptimizedResults(t *testing.T) {
+	inputs := []string{
+		"(2 * 60) * 60",
+		"x := 3; x * (4 - 1) / 2",
+		"9223372036854775807 + 1 - 1",
+		"0.1d + 0.2d == 0.3d",
+		`"n=${2 * 21}" + "!"`,
+		"1 / 0",
+		"1.0 / 0.0",
+		"x := 0.0 / 0.0; x == x",
+		"5 + true",
+		"-(1 < 2)",
+		"false && 1 / 0",
+		"true || 1 / 0",
+		"true && 1 / 0",
+		"if 1 > 2 { 10 }",
+		"5; if false { 10 }",
+		"if 2 > 1 { x := 1 }\nx",
+		"if true { 1 } else if 1 / 0 { 2 }",
+		"if false { 1 } else if true { 2 } else { 3 }",
+		"return 2 * 5; 9",
+		"func f(n) { if n < 2 { return n }\nreturn f(n - 1) + f(n - 2); 0 }\nf(10)",
+		"func f() { return g(); func g() { 1 } }\nf()",
+		"sum := 0\nfor i := 0; i < 1 + 4; i = i + 1 { if i == 2 * 1 { continue; sum = 100 }\nsum = sum + i }\nsum",
+		"n := 0\nouter: while true { while true { n = n + 1; break outer; n = 10 } }\nn",
+		"xs := [1 + 1, 2 * 2]; xs[0 + 1] = 3 - 3; xs[-2 + 1:]",
+		`m := {"a" + "b": 1 < 2}; m["ab"]`,
+	}
+
+	passes := []Options{
+		{FoldConstants: true},
+		{DeadBranches: true},
+		{Unreachable: true},
+		All,
+	}
+
+	for _, input := range inputs {
+		expected := evaluate(testOptimize(t, input, Options{}))
+
+		for _, opts := range passes {
+			program := testOptimize(t, input, opts)
+
+			if evaluated := evaluate(program); evaluated != expected {
+				t.Errorf("%s with %+v - expected %s, got %s", input, opts, expected, evaluated)
+			}
+			if executed := execute(program); executed != expected {
+				t.Errorf("%s with %+v on the vm - expected %s, got %s", input, opts, expected, executed)
+			}
+		}
+	}
+}
+
+func evaluate(program *ast.Program) string {
+	result := evaluator.Eval(program, object.NewEnvironment())
+	if result == nil {
+		return "null"
+	}
+	return result.Inspect()
+}
+
+func execute(program *ast.Program) string {
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		return "ERROR: " + err.Error()
+	}
+
+	machine := vm.New(comp.Bytecode())
+	if err := machine.Run(); err != nil {
+		return "ERROR: " + err.Error()
+	}
+	return machine.Result().Inspect()
+}
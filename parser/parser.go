@@ -1,10 +1,17 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
+	"math/big"
+	"slices"
 	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/chaitanya-Uike/lemon/ast"
+	"github.com/chaitanya-Uike/lemon/decimal"
+	"github.com/chaitanya-Uike/lemon/diagnostic"
 	"github.com/chaitanya-Uike/lemon/lexer"
 	"github.com/chaitanya-Uike/lemon/token"
 )
@@ -12,25 +19,58 @@ import (
 const (
 	_ int = iota
 	LOWEST
+	LOGICAL_OR
+	LOGICAL_AND
 	EQUALS
 	LESSGREATER
 	SUM
 	PRODUCT
 	PREFIX
 	CALL
+	INDEX
 )
 
+// bitwise operators share the tiers of the arithmetic ones like in Go,
+// | and ^ bind like + while &, << and >> bind like *
 var precedences = map[token.TokenType]int{
-	token.EQ:       EQUALS,
-	token.NOT_EQ:   EQUALS,
-	token.LT:       LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.ASTERISK: PRODUCT,
-	token.SLASH:    PRODUCT,
+	token.OR:        LOGICAL_OR,
+	token.AND:       LOGICAL_AND,
+	token.EQ:        EQUALS,
+	token.NOT_EQ:    EQUALS,
+	token.LT:        LESSGREATER,
+	token.GT:        LESSGREATER,
+	token.LT_EQ:     LESSGREATER,
+	token.GT_EQ:     LESSGREATER,
+	token.PLUS:      SUM,
+	token.MINUS:     SUM,
+	token.PIPE:      SUM,
+	token.CARET:     SUM,
+	token.ASTERISK:  PRODUCT,
+	token.SLASH:     PRODUCT,
+	token.PERCENT:   PRODUCT,
+	token.AMPERSAND: PRODUCT,
+	token.SHL:       PRODUCT,
+	token.SHR:       PRODUCT,
+	token.LPAREN:    CALL,
+	token.LBRACKET:  INDEX,
 }
 
+// diagnostic codes reported by the parser
+const (
+	CodeUnexpectedToken = "P001"
+	CodeNoPrefixParseFn = "P002"
+	CodeInvalidNumber   = "P003"
+	CodeInvalidString   = "P004"
+	CodeIllegalToken    = "P005"
+	CodeInvalidElse     = "P006"
+	CodeInvalidAssign   = "P007"
+	CodeInvalidBranch   = "P008"
+	CodeInvalidLabel    = "P009"
+	CodeInvalidComment  = "P010"
+	CodeInvalidEncoding = "P011"
+	CodeInvalidType     = "P012"
+)
+
 type (
 	parsePrefixFn func() ast.Expression
 	parseInfixFn  func(ast.Expression) ast.Expression
@@ -45,7 +85,25 @@ type Parser struct {
 	parsePrefixFns map[token.TokenType]parsePrefixFn
 	parseInfixFns  map[token.TokenType]parseInfixFn
 
-	errors []string
+	diagnostics []*diagnostic.Diagnostic
+
+	// panicking is set by the first error inside a statement, further errors
+	// are dropped until the parser resynchronises at the next statement.
+	panicking bool
+	// closedEarly is set when an operand was expected but a closing brace
+	// was found, that brace still terminates the enclosing block.
+	closedEarly bool
+	lastErrLine int
+
+	// noHashLiteral is set while parsing the header of an if or loop
+	// statement, where '{' opens the body and can't start a map literal.
+	// Parentheses, brackets and blocks nested in the header lift the
+	// restriction again.
+	noHashLiteral bool
+
+	// loops holds the labels of the loops enclosing the current statement,
+	// "" for unlabelled ones, so that break and continue can be checked.
+	loops []string
 }
 
 func New(l *lexer.Lexer) *Parser {
@@ -55,7 +113,7 @@ func New(l *lexer.Lexer) *Parser {
 		parsePrefixFns: make(map[token.TokenType]parsePrefixFn),
 		parseInfixFns:  make(map[token.TokenType]parseInfixFn),
 
-		errors: []string{},
+		diagnostics: []*diagnostic.Diagnostic{},
 	}
 	p.nextToken()
 	p.nextToken()
@@ -63,21 +121,39 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefixFn(token.IDENT, p.parseIdentifier)
 	p.registerPrefixFn(token.INT, p.parseInteger)
 	p.registerPrefixFn(token.FLOAT, p.parseFloat)
+	p.registerPrefixFn(token.DECIMAL, p.parseDecimal)
+	p.registerPrefixFn(token.STRING, p.parseStringLiteral)
 	p.registerPrefixFn(token.TRUE, p.parseBooleanLiteral)
 	p.registerPrefixFn(token.FALSE, p.parseBooleanLiteral)
 
 	p.registerPrefixFn(token.BANG, p.parsePrefixExpression)
 	p.registerPrefixFn(token.MINUS, p.parsePrefixExpression)
+	p.registerPrefixFn(token.TILDE, p.parsePrefixExpression)
 	p.registerPrefixFn(token.LPAREN, p.parseGroupedExpression)
+	p.registerPrefixFn(token.FUNC, p.parseFunctionLiteral)
+	p.registerPrefixFn(token.LBRACKET, p.parseArrayLiteral)
+	p.registerPrefixFn(token.LBRACE, p.parseHashLiteral)
 
 	p.registerInfixFn(token.PLUS, p.parseInfixExpression)
 	p.registerInfixFn(token.MINUS, p.parseInfixExpression)
 	p.registerInfixFn(token.ASTERISK, p.parseInfixExpression)
 	p.registerInfixFn(token.SLASH, p.parseInfixExpression)
+	p.registerInfixFn(token.PERCENT, p.parseInfixExpression)
 	p.registerInfixFn(token.EQ, p.parseInfixExpression)
 	p.registerInfixFn(token.NOT_EQ, p.parseInfixExpression)
 	p.registerInfixFn(token.LT, p.parseInfixExpression)
 	p.registerInfixFn(token.GT, p.parseInfixExpression)
+	p.registerInfixFn(token.LT_EQ, p.parseInfixExpression)
+	p.registerInfixFn(token.GT_EQ, p.parseInfixExpression)
+	p.registerInfixFn(token.AND, p.parseInfixExpression)
+	p.registerInfixFn(token.OR, p.parseInfixExpression)
+	p.registerInfixFn(token.AMPERSAND, p.parseInfixExpression)
+	p.registerInfixFn(token.PIPE, p.parseInfixExpression)
+	p.registerInfixFn(token.CARET, p.parseInfixExpression)
+	p.registerInfixFn(token.SHL, p.parseInfixExpression)
+	p.registerInfixFn(token.SHR, p.parseInfixExpression)
+	p.registerInfixFn(token.LPAREN, p.parseCallExpression)
+	p.registerInfixFn(token.LBRACKET, p.parseIndexExpression)
 
 	return p
 }
@@ -103,18 +179,66 @@ func (p *Parser) ParseProgram() *ast.Program {
 		if stmt != nil {
 			program.Statements = append(program.Statements, stmt)
 		}
+		p.closedEarly = false
 		p.nextToken()
 	}
 
 	return program
 }
 
+// parseStatement parses a single statement, if that fails the parser skips
+// ahead to the end of the statement and the part it could not make sense of
+// is kept as an *ast.BadStatement.
 func (p *Parser) parseStatement() ast.Statement {
+	start := p.curToken
+
+	stmt := p.parseStatementKind()
+	if !p.panicking {
+		return stmt
+	}
+
+	p.synchronize()
+	if stmt == nil {
+		return &ast.BadStatement{From: start, To: p.curToken}
+	}
+	return stmt
+}
+
+func (p *Parser) parseStatementKind() ast.Statement {
 	switch p.curToken.Type {
+	case token.SEMICOLON:
+		return nil
+	case token.LBRACE:
+		return p.parseBlockStatement()
 	case token.IF:
-		return p.parseIfStatement()
+		if stmt := p.parseIfStatement(); stmt != nil {
+			return stmt
+		}
+		return nil
+	case token.WHILE:
+		if stmt := p.parseWhileStatement(""); stmt != nil {
+			return stmt
+		}
+		return nil
+	case token.FOR:
+		return p.parseForStatement("")
+	case token.BREAK, token.CONTINUE:
+		return p.parseBranchStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.IDENT:
+		if p.peekTokenIs(token.DECLARE) {
+			return p.parseDeclareStatement()
+		}
+		if p.peekTokenIs(token.COLON) {
+			return p.parseLabeledStatement()
+		}
+		return p.parseExpressionStatement()
+	case token.FUNC:
+		if p.peekTokenIs(token.IDENT) {
+			return p.parseFunctionStatement()
+		}
+		return p.parseExpressionStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -125,6 +249,63 @@ func (p *Parser) parseExpressionStatement() ast.Statement {
 
 	stmt.Expression = p.parseExpression(LOWEST)
 
+	if p.peekTokenIs(token.ASSIGN) {
+		p.nextToken()
+		return p.parseAssignStatement(stmt.Expression)
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// parseDeclareStatement parses both `x := value` and the annotated form
+// `x: type := value`.
+func (p *Parser) parseDeclareStatement() ast.Statement {
+	name := &ast.IdentifierLiteral{Token: p.curToken, Value: p.curToken.Literal}
+
+	var typ ast.TypeExpression
+	if p.peekTokenIs(token.COLON) {
+		var ok bool
+		if typ, ok = p.parseAnnotation(); !ok {
+			return nil
+		}
+	}
+	if !p.expectPeek(token.DECLARE) {
+		return nil
+	}
+	return p.parseDeclaration(name, typ)
+}
+
+// parseDeclaration parses the rest of a declaration from its := token.
+func (p *Parser) parseDeclaration(name *ast.IdentifierLiteral, typ ast.TypeExpression) ast.Statement {
+	stmt := &ast.DeclareStatement{Token: p.curToken, Name: name, Type: typ}
+	p.nextToken()
+
+	stmt.Value = p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseAssignStatement(target ast.Expression) ast.Statement {
+	switch target.(type) {
+	case *ast.IdentifierLiteral, *ast.IndexExpression:
+	case *ast.BadExpression:
+	default:
+		p.report(CodeInvalidAssign, diagnostic.Span{Start: target.Pos(), End: target.End()}, "cannot assign to %s", target)
+	}
+
+	stmt := &ast.AssignStatement{Token: p.curToken, Target: target}
+	p.nextToken()
+
+	stmt.Value = p.parseExpression(LOWEST)
+
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
@@ -136,8 +317,8 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 	prefixFn := p.parsePrefixFns[p.curToken.Type]
 
 	if prefixFn == nil {
-		p.errors = append(p.errors, fmt.Sprintf("prefix parse function for %q [%s] not found", p.curToken.Literal, p.curToken.Type))
-		return nil
+		p.noPrefixParseFnError()
+		return &ast.BadExpression{From: p.curToken, To: p.curToken}
 	}
 
 	leftExp := prefixFn()
@@ -170,10 +351,14 @@ func (p *Parser) parseIdentifier() ast.Expression {
 
 func (p *Parser) parseInteger() ast.Expression {
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		if big, ok := new(big.Int).SetString(p.curToken.Literal, 0); ok {
+			return &ast.IntegerLiteral{Token: p.curToken, Big: big}
+		}
+	}
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
-		return nil
+		p.report(CodeInvalidNumber, diagnostic.SpanOf(p.curToken), "could not parse %q as integer", p.curToken.Literal)
+		return &ast.BadExpression{From: p.curToken, To: p.curToken}
 	}
 	return &ast.IntegerLiteral{Token: p.curToken, Value: value}
 }
@@ -181,13 +366,21 @@ func (p *Parser) parseInteger() ast.Expression {
 func (p *Parser) parseFloat() ast.Expression {
 	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as float", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
-		return nil
+		p.report(CodeInvalidNumber, diagnostic.SpanOf(p.curToken), "could not parse %q as float", p.curToken.Literal)
+		return &ast.BadExpression{From: p.curToken, To: p.curToken}
 	}
 	return &ast.FloatLiteral{Token: p.curToken, Value: value}
 }
 
+func (p *Parser) parseDecimal() ast.Expression {
+	value, err := decimal.Parse(strings.TrimSuffix(p.curToken.Literal, "d"))
+	if err != nil {
+		p.report(CodeInvalidNumber, diagnostic.SpanOf(p.curToken), "could not parse %q as decimal", p.curToken.Literal)
+		return &ast.BadExpression{From: p.curToken, To: p.curToken}
+	}
+	return &ast.DecimalLiteral{Token: p.curToken, Value: value}
+}
+
 func (p *Parser) parseBooleanLiteral() ast.Expression {
 	return &ast.BooleanLiteral{Token: p.curToken, Value: p.curTokenIs(token.TRUE)}
 }
@@ -206,12 +399,14 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 }
 
 func (p *Parser) parseGroupedExpression() ast.Expression {
+	lparen := p.curToken
 	p.nextToken()
 
+	defer p.allowHashLiteral()()
 	exp := p.parseExpression(LOWEST)
 
 	if !p.expectPeek(token.RPAREN) {
-		return nil
+		return &ast.BadExpression{From: lparen, To: p.curToken}
 	}
 
 	return exp
@@ -220,6 +415,13 @@ func (p *Parser) parseGroupedExpression() ast.Expression {
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	stmt := &ast.ReturnStatement{Token: p.curToken}
 
+	if p.peekTokenIs(token.SEMICOLON) || p.peekTokenIs(token.RBRACE) || p.peekTokenIs(token.EOF) {
+		if p.peekTokenIs(token.SEMICOLON) {
+			p.nextToken()
+		}
+		return stmt
+	}
+
 	p.nextToken()
 
 	stmt.ReturnValue = p.parseExpression(LOWEST)
@@ -233,9 +435,8 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 
 func (p *Parser) parseIfStatement() *ast.IfStatement {
 	stmt := &ast.IfStatement{Token: p.curToken}
-	p.nextToken()
 
-	stmt.Condition = p.parseExpression(LOWEST)
+	stmt.Condition = p.parseCondition()
 
 	if !p.expectPeek(token.LBRACE) {
 		return nil
@@ -251,18 +452,190 @@ func (p *Parser) parseIfStatement() *ast.IfStatement {
 			stmt.Alternate = p.parseBlockStatement()
 		} else if p.peekTokenIs(token.IF) {
 			p.nextToken()
-			stmt.Alternate = p.parseIfStatement()
+			alternate := p.parseIfStatement()
+			if alternate == nil {
+				return nil
+			}
+			stmt.Alternate = alternate
 		} else {
-			p.errors = append(p.errors, "Expected either an block statement or an if statement after else")
+			d := p.report(CodeInvalidElse, diagnostic.SpanOf(p.peekToken), "Expected either an block statement or an if statement after else")
+			d.Notes = append(d.Notes, "else must be followed by { or if on the same line")
+			return nil
+		}
+	}
+
+	return stmt
+}
+
+// parseCondition parses the condition following an if or while keyword.
+func (p *Parser) parseCondition() ast.Expression {
+	if p.peekTokenIs(token.LBRACE) {
+		p.report(CodeNoPrefixParseFn, diagnostic.SpanOf(p.peekToken), "missing condition in %s statement", p.curToken.Literal)
+		return &ast.BadExpression{From: p.peekToken, To: p.peekToken}
+	}
+
+	p.nextToken()
+	return p.parseHeaderExpression()
+}
+
+func (p *Parser) parseWhileStatement(label string) *ast.WhileStatement {
+	stmt := &ast.WhileStatement{Token: p.curToken}
+
+	stmt.Condition = p.parseCondition()
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody(label)
+
+	return stmt
+}
+
+// parseForStatement parses both `for x in iterable {...}` and the three
+// clause form `for init; cond; post {...}`.
+func (p *Parser) parseForStatement(label string) ast.Statement {
+	tok := p.curToken
+
+	prev := p.noHashLiteral
+	p.noHashLiteral = true
+	defer func() { p.noHashLiteral = prev }()
+
+	var init ast.Statement
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	} else {
+		p.nextToken()
+
+		if p.curTokenIs(token.IDENT) && p.peekTokenIs(token.IN) {
+			return p.parseForInStatement(tok, label)
+		}
+
+		init = p.parseSimpleStatement()
+		if !p.curTokenIs(token.SEMICOLON) && !p.expectPeek(token.SEMICOLON) {
 			return nil
 		}
 	}
 
+	stmt := &ast.ForStatement{Token: tok, Init: init}
+
+	if !p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+		stmt.Condition = p.parseExpression(LOWEST)
+	}
+	if !p.expectPeek(token.SEMICOLON) {
+		return nil
+	}
+
+	if !p.peekTokenIs(token.LBRACE) {
+		p.nextToken()
+		stmt.Post = p.parseExpressionStatement()
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody(label)
+
+	return stmt
+}
+
+func (p *Parser) parseForInStatement(tok token.Token, label string) ast.Statement {
+	stmt := &ast.ForInStatement{Token: tok}
+	stmt.Variable = &ast.IdentifierLiteral{Token: p.curToken, Value: p.curToken.Literal}
+
+	p.nextToken()
+	p.nextToken()
+	stmt.Iterable = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody(label)
+
+	return stmt
+}
+
+// parseSimpleStatement parses the statements allowed in a for clause.
+func (p *Parser) parseSimpleStatement() ast.Statement {
+	if p.curTokenIs(token.IDENT) && (p.peekTokenIs(token.DECLARE) || p.peekTokenIs(token.COLON)) {
+		return p.parseDeclareStatement()
+	}
+	return p.parseExpressionStatement()
+}
+
+func (p *Parser) parseLoopBody(label string) *ast.BlockStatement {
+	p.loops = append(p.loops, label)
+	defer func() { p.loops = p.loops[:len(p.loops)-1] }()
+
+	return p.parseBlockStatement()
+}
+
+func (p *Parser) parseLabeledStatement() ast.Statement {
+	label := &ast.IdentifierLiteral{Token: p.curToken, Value: p.curToken.Literal}
+	p.nextToken()
+
+	// a type after the colon makes it an annotated declaration
+	if startsType(p.peekToken.Type) {
+		p.nextToken()
+		typ := p.parseType()
+		if typ == nil || !p.expectPeek(token.DECLARE) {
+			return nil
+		}
+		return p.parseDeclaration(label, typ)
+	}
+
+	if slices.Contains(p.loops, label.Value) {
+		p.report(CodeInvalidLabel, diagnostic.SpanOf(label.Token), "label %s already defined", label.Value)
+		return nil
+	}
+
+	var stmt ast.Statement
+	switch {
+	case p.peekTokenIs(token.WHILE):
+		p.nextToken()
+		if while := p.parseWhileStatement(label.Value); while != nil {
+			stmt = while
+		}
+	case p.peekTokenIs(token.FOR):
+		p.nextToken()
+		stmt = p.parseForStatement(label.Value)
+	default:
+		p.report(CodeInvalidLabel, diagnostic.SpanOf(label.Token), "label %s must be followed by a loop", label.Value)
+	}
+
+	if stmt == nil {
+		return nil
+	}
+	return &ast.LabeledStatement{Label: label, Statement: stmt}
+}
+
+func (p *Parser) parseBranchStatement() ast.Statement {
+	stmt := &ast.BranchStatement{Token: p.curToken}
+
+	if p.peekTokenIs(token.IDENT) {
+		p.nextToken()
+		stmt.Label = &ast.IdentifierLiteral{Token: p.curToken, Value: p.curToken.Literal}
+	}
+
+	switch {
+	case len(p.loops) == 0:
+		p.report(CodeInvalidBranch, diagnostic.Span{Start: stmt.Pos(), End: stmt.End()}, "%s outside of a loop", stmt.Token.Literal)
+	case stmt.Label != nil && !slices.Contains(p.loops, stmt.Label.Value):
+		p.report(CodeInvalidBranch, diagnostic.SpanOf(stmt.Label.Token), "%s label not defined: %s", stmt.Token.Literal, stmt.Label.Value)
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
 	return stmt
 }
 
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.curToken, Statements: []ast.Statement{}}
+	defer p.allowHashLiteral()()
 
 	p.nextToken()
 
@@ -271,12 +644,253 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 		if stmt != nil {
 			block.Statements = append(block.Statements, stmt)
 		}
+		if p.closedEarly {
+			p.closedEarly = false
+			break
+		}
 		p.nextToken()
 	}
+	block.Rbrace = p.curToken
 
 	return block
 }
 
+func (p *Parser) parseFunctionStatement() ast.Statement {
+	stmt := &ast.FunctionStatement{Token: p.curToken, Doc: p.l.Doc(p.curToken.Pos)}
+
+	p.nextToken()
+	stmt.Name = &ast.IdentifierLiteral{Token: p.curToken, Value: p.curToken.Literal}
+
+	fn := &ast.FunctionLiteral{Token: stmt.Token}
+	if !p.parseFunctionSignatureAndBody(fn) {
+		return nil
+	}
+	stmt.Function = fn
+
+	return stmt
+}
+
+func (p *Parser) parseFunctionLiteral() ast.Expression {
+	fn := &ast.FunctionLiteral{Token: p.curToken}
+	if !p.parseFunctionSignatureAndBody(fn) {
+		return &ast.BadExpression{From: fn.Token, To: p.curToken}
+	}
+	return fn
+}
+
+func (p *Parser) parseFunctionSignatureAndBody(fn *ast.FunctionLiteral) bool {
+	if !p.expectPeek(token.LPAREN) {
+		return false
+	}
+
+	if !p.parseFunctionParameters(fn) {
+		return false
+	}
+
+	if p.peekTokenIs(token.COLON) {
+		var ok bool
+		if fn.ReturnType, ok = p.parseAnnotation(); !ok {
+			return false
+		}
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return false
+	}
+
+	// loops don't extend into function bodies
+	loops := p.loops
+	p.loops = nil
+	fn.Body = p.parseBlockStatement()
+	p.loops = loops
+	return true
+}
+
+// parseFunctionParameters parses the parameters of fn, each with an
+// optional annotation.
+func (p *Parser) parseFunctionParameters(fn *ast.FunctionLiteral) bool {
+	fn.Parameters = []*ast.IdentifierLiteral{}
+
+	if p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+		return true
+	}
+
+	for {
+		if !p.expectPeek(token.IDENT) {
+			return false
+		}
+		fn.Parameters = append(fn.Parameters, &ast.IdentifierLiteral{Token: p.curToken, Value: p.curToken.Literal})
+
+		var typ ast.TypeExpression
+		if p.peekTokenIs(token.COLON) {
+			var ok bool
+			if typ, ok = p.parseAnnotation(); !ok {
+				return false
+			}
+		}
+		fn.ParameterTypes = append(fn.ParameterTypes, typ)
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	return p.expectPeek(token.RPAREN)
+}
+
+func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
+	exp := &ast.CallExpression{Token: p.curToken, Function: function}
+	exp.Arguments = p.parseExpressionList(token.RPAREN)
+	exp.Rparen = p.curToken
+	return exp
+}
+
+func (p *Parser) parseArrayLiteral() ast.Expression {
+	array := &ast.ArrayLiteral{Token: p.curToken}
+	array.Elements = p.parseExpressionList(token.RBRACKET)
+	array.Rbracket = p.curToken
+	return array
+}
+
+// parseIndexExpression parses both xs[i] and the slice forms xs[a:b], xs[a:],
+// xs[:b] and xs[:].
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	tok := p.curToken
+	defer p.allowHashLiteral()()
+
+	var low ast.Expression
+	if !p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		low = p.parseExpression(LOWEST)
+	}
+
+	if !p.peekTokenIs(token.COLON) {
+		exp := &ast.IndexExpression{Token: tok, Left: left, Index: low}
+		p.expectPeek(token.RBRACKET)
+		exp.Rbracket = p.curToken
+		return exp
+	}
+
+	p.nextToken()
+	exp := &ast.SliceExpression{Token: tok, Left: left, Low: low}
+	if !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		exp.High = p.parseExpression(LOWEST)
+	}
+	p.expectPeek(token.RBRACKET)
+	exp.Rbracket = p.curToken
+	return exp
+}
+
+func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
+	list := []ast.Expression{}
+	defer p.allowHashLiteral()()
+
+	if p.peekTokenIs(end) {
+		p.nextToken()
+		return list
+	}
+
+	p.nextToken()
+	list = append(list, p.parseExpression(LOWEST))
+
+	for p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		if p.peekTokenIs(end) {
+			break
+		}
+		p.nextToken()
+		list = append(list, p.parseExpression(LOWEST))
+	}
+
+	p.expectPeek(end)
+
+	return list
+}
+
+func (p *Parser) parseHashLiteral() ast.Expression {
+	hash := &ast.HashLiteral{Token: p.curToken, Pairs: []ast.HashPair{}}
+
+	if p.noHashLiteral {
+		d := p.report(CodeNoPrefixParseFn, diagnostic.SpanOf(p.curToken), "expected expression, found '{'")
+		d.Notes = append(d.Notes, "a map literal in the header of an if or loop statement has to be wrapped in parentheses")
+		hash.Rbrace = p.curToken
+		return &ast.BadExpression{From: hash.Token, To: hash.Token}
+	}
+
+	// on errors skip to the brace closing the literal so that it isn't
+	// mistaken for the end of an enclosing block
+	bad := func() ast.Expression {
+		if p.closedEarly {
+			p.closedEarly = false
+		} else {
+			p.skipToClosingBrace()
+		}
+		return &ast.BadExpression{From: hash.Token, To: p.curToken}
+	}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		key := p.parseExpression(LOWEST)
+		if p.closedEarly || !p.expectPeek(token.COLON) {
+			return bad()
+		}
+
+		p.nextToken()
+		value := p.parseExpression(LOWEST)
+		if p.closedEarly {
+			return bad()
+		}
+
+		hash.Pairs = append(hash.Pairs, ast.HashPair{Key: key, Value: value})
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return bad()
+		}
+	}
+
+	p.nextToken()
+	hash.Rbrace = p.curToken
+
+	return hash
+}
+
+func (p *Parser) skipToClosingBrace() {
+	depth := 1
+	for !p.curTokenIs(token.EOF) {
+		if p.peekTokenIs(token.LBRACE) {
+			depth++
+		} else if p.peekTokenIs(token.RBRACE) {
+			depth--
+			if depth == 0 {
+				p.nextToken()
+				return
+			}
+		}
+		p.nextToken()
+	}
+}
+
+// parseHeaderExpression parses the expression between a statement keyword
+// and the '{' opening its body.
+func (p *Parser) parseHeaderExpression() ast.Expression {
+	prev := p.noHashLiteral
+	p.noHashLiteral = true
+	defer func() { p.noHashLiteral = prev }()
+
+	return p.parseExpression(LOWEST)
+}
+
+// allowHashLiteral lifts the header restriction until the returned function
+// is called.
+func (p *Parser) allowHashLiteral() func() {
+	prev := p.noHashLiteral
+	p.noHashLiteral = false
+	return func() { p.noHashLiteral = prev }
+}
+
 func (p *Parser) curTokenIs(t token.TokenType) bool {
 	return p.curToken.Type == t
 }
@@ -294,13 +908,122 @@ func (p *Parser) expectPeek(t token.TokenType) bool {
 	return false
 }
 
+// Errors returns the parse errors as "line:col: message" strings, use
+// Diagnostics for the structured form.
 func (p *Parser) Errors() []string {
-	return p.errors
+	errors := make([]string, 0, len(p.diagnostics))
+	for _, d := range p.diagnostics {
+		errors = append(errors, d.Error())
+	}
+	return errors
+}
+
+func (p *Parser) Diagnostics() []*diagnostic.Diagnostic {
+	return p.diagnostics
+}
+
+// report records an error unless it is likely to be a consequence of an
+// earlier one: while panicking or on the same line as the previous error.
+// The returned diagnostic can still be amended by the caller either way.
+func (p *Parser) report(code string, span diagnostic.Span, format string, a ...any) *diagnostic.Diagnostic {
+	d := &diagnostic.Diagnostic{
+		Severity: diagnostic.Error,
+		Code:     code,
+		Span:     span,
+		Message:  fmt.Sprintf(format, a...),
+	}
+
+	if p.panicking || span.Start.Line == p.lastErrLine {
+		p.panicking = true
+		return d
+	}
+
+	p.panicking = true
+	p.lastErrLine = span.Start.Line
+	p.diagnostics = append(p.diagnostics, d)
+	return d
+}
+
+// synchronize skips the rest of a statement that failed to parse. It stops
+// with the statement's last token in curToken, like a successful parse does,
+// at a semicolon or just before a closing brace or a token that can only
+// start a new statement.
+func (p *Parser) synchronize() {
+	p.panicking = false
+
+	if p.closedEarly {
+		return
+	}
+
+	for !p.curTokenIs(token.SEMICOLON) && !p.curTokenIs(token.EOF) {
+		if p.peekTokenIs(token.RBRACE) || p.peekTokenIs(token.EOF) || isStatementKeyword(p.peekToken.Type) {
+			return
+		}
+		p.nextToken()
+	}
+}
+
+func isStatementKeyword(t token.TokenType) bool {
+	switch t {
+	case token.IF, token.WHILE, token.FOR, token.BREAK, token.CONTINUE, token.RETURN:
+		return true
+	}
+	return false
 }
 
 func (p *Parser) peekError(t token.TokenType) {
-	msg := fmt.Sprintf("expected next token to be %s, got %s instead", t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	d := p.report(CodeUnexpectedToken, diagnostic.SpanOf(p.peekToken), "expected next token to be %s, got %s instead", t, p.peekToken.Type)
+
+	switch t {
+	case token.RPAREN, token.RBRACE, token.LPAREN, token.LBRACE, token.COMMA:
+		at := p.curToken.End
+		d.Fix = &diagnostic.Fix{
+			Message:     fmt.Sprintf("insert %q after %q", t, p.curToken.Literal),
+			Span:        diagnostic.Span{Start: at, End: at},
+			Replacement: string(t),
+		}
+	}
+}
+
+func (p *Parser) noPrefixParseFnError() {
+	span := diagnostic.SpanOf(p.curToken)
+
+	if p.curTokenIs(token.RBRACE) {
+		p.closedEarly = true
+	}
+
+	if p.curTokenIs(token.ILLEGAL) {
+		if !utf8.ValidString(p.curToken.Literal) {
+			p.report(CodeInvalidEncoding, span, "invalid UTF-8 encoding: byte %#x", p.curToken.Literal[0])
+			return
+		}
+		if strings.HasPrefix(p.curToken.Literal, "\"") {
+			d := p.report(CodeInvalidString, span, "unterminated string literal")
+			d.Notes = append(d.Notes, "string literals cannot span multiple lines")
+			return
+		}
+		if p.curToken.Literal == "/*" {
+			p.report(CodeInvalidComment, span, "unterminated block comment")
+			return
+		}
+		if isNumberLiteral(p.curToken.Literal) {
+			p.report(CodeInvalidNumber, span, "malformed number literal %q", p.curToken.Literal)
+			return
+		}
+		p.report(CodeIllegalToken, span, "illegal character %q", p.curToken.Literal)
+		return
+	}
+
+	p.report(CodeNoPrefixParseFn, span, "prefix parse function for %q [%s] not found", p.curToken.Literal, p.curToken.Type)
+}
+
+// isNumberLiteral reports whether an ILLEGAL token is a malformed number, the
+// lexer reads those in one piece, starting with a digit or a '.' and a digit.
+func isNumberLiteral(literal string) bool {
+	if len(literal) > 1 && literal[0] == '.' {
+		literal = literal[1:]
+	}
+	return literal[0] >= '0' && literal[0] <= '9'
 }
 
 func (p *Parser) peekPrecedence() int {
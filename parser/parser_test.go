@@ -2,6 +2,7 @@ package parser
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/chaitanya-Uike/lemon/ast"
@@ -172,6 +173,7 @@ func TestPrefixExpressions(t *testing.T) {
 	}{
 		{"!5", "!", 5},
 		{"-15", "-", 15},
+		{"~15", "~", 15},
 		{"!true", "!", true},
 		{"!false", "!", false},
 	}
@@ -236,6 +238,16 @@ func TestInfixExpression(t *testing.T) {
 		{"5 < 5", 5, "<", 5},
 		{"5 == 5", 5, "==", 5},
 		{"5 != 5", 5, "!=", 5},
+		{"5 % 5", 5, "%", 5},
+		{"5 <= 5", 5, "<=", 5},
+		{"5 >= 5", 5, ">=", 5},
+		{"true && false", true, "&&", false},
+		{"true || false", true, "||", false},
+		{"5 & 5", 5, "&", 5},
+		{"5 | 5", 5, "|", 5},
+		{"5 ^ 5", 5, "^", 5},
+		{"5 << 5", 5, "<<", 5},
+		{"5 >> 5", 5, ">>", 5},
 		{"true == true", true, "==", true},
 		{"true != false", true, "!=", false},
 		{"false == false", false, "==", false},
@@ -369,6 +381,94 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"!(true == true)",
 			"(!(true == true))",
 		},
+		{
+			"a + add(b * c) + d",
+			"((a + add((b * c))) + d)",
+		},
+		{
+			"add(a, b, 1, 2 * 3, 4 + 5, add(6, 7 * 8))",
+			"add(a, b, 1, (2 * 3), (4 + 5), add(6, (7 * 8)))",
+		},
+		{
+			"add(a + b + c * d / f + g)",
+			"add((((a + b) + ((c * d) / f)) + g))",
+		},
+		{
+			"-f(x)",
+			"(-f(x))",
+		},
+		{
+			"f(x)(y)",
+			"f(x)(y)",
+		},
+		{
+			"a * [1, 2, 3, 4][b * c] * d",
+			"((a * ([1, 2, 3, 4][(b * c)])) * d)",
+		},
+		{
+			"add(a * b[2], b[1], 2 * [1, 2][1])",
+			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
+		},
+		{
+			"f(x)[0]",
+			"(f(x)[0])",
+		},
+		{
+			"-xs[1:n - 1]",
+			"(-(xs[1:(n - 1)]))",
+		},
+		{
+			"a % b * c",
+			"((a % b) * c)",
+		},
+		{
+			"a + b % c",
+			"(a + (b % c))",
+		},
+		{
+			"a <= b == b >= c",
+			"((a <= b) == (b >= c))",
+		},
+		{
+			"a || b && c",
+			"(a || (b && c))",
+		},
+		{
+			"a && b || c && d",
+			"((a && b) || (c && d))",
+		},
+		{
+			"a == b && c != d",
+			"((a == b) && (c != d))",
+		},
+		{
+			"!a && b < c || d",
+			"(((!a) && (b < c)) || d)",
+		},
+		{
+			"a | b & c",
+			"(a | (b & c))",
+		},
+		{
+			"a ^ b << 2 + c",
+			"((a ^ (b << 2)) + c)",
+		},
+		{
+			"a & b == c",
+			"((a & b) == c)",
+		},
+		{
+			"1 << n - 1",
+			"((1 << n) - 1)",
+		},
+		{
+			"~a & ~b",
+			"((~a) & (~b))",
+		},
+		{
+			"a & b && c | d",
+			"((a & b) && (c | d))",
+		},
 	}
 
 	for _, tt := range tests {
@@ -621,3 +721,1212 @@ func TestIfElseIfStatement(t *testing.T) {
 		t.Fatalf("Expected else intLiteral.Value to be %d, got %d", 30, elseIntLiteral.Value)
 	}
 }
+
+func TestBareReturnStatement(t *testing.T) {
+	input := `
+	return
+	return;`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("Expected %d statements, got %d", 2, len(program.Statements))
+	}
+
+	for _, stmt := range program.Statements {
+		returnStmt, ok := stmt.(*ast.ReturnStatement)
+		if !ok {
+			t.Fatalf("Expected *ast.ReturnStatement, got %T", stmt)
+		}
+		if returnStmt.ReturnValue != nil {
+			t.Fatalf("Expected ReturnValue to be nil, got %s", returnStmt.ReturnValue)
+		}
+	}
+}
+
+func TestFunctionLiteralParsing(t *testing.T) {
+	input := `func(x, y) { x + y }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("Expected %d statements, got %d", 1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Expected *ast.ExpressionStatement, got %T", program.Statements[0])
+	}
+
+	function, ok := stmt.Expression.(*ast.FunctionLiteral)
+	if !ok {
+		t.Fatalf("Expected *ast.FunctionLiteral, got %T", stmt.Expression)
+	}
+
+	if len(function.Parameters) != 2 {
+		t.Fatalf("Expected %d parameters, got %d", 2, len(function.Parameters))
+	}
+
+	testLiteralExpression(t, function.Parameters[0], "x")
+	testLiteralExpression(t, function.Parameters[1], "y")
+
+	if len(function.Body.Statements) != 1 {
+		t.Fatalf("Expected %d statements in body, got %d", 1, len(function.Body.Statements))
+	}
+
+	bodyStmt, ok := function.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Expected body statement to be *ast.ExpressionStatement, got %T", function.Body.Statements[0])
+	}
+
+	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
+}
+
+func TestFunctionParameterParsing(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedParams []string
+	}{
+		{input: "func() {}", expectedParams: []string{}},
+		{input: "func(x) {}", expectedParams: []string{"x"}},
+		{input: "func(x, y, z) {}", expectedParams: []string{"x", "y", "z"}},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		function := stmt.Expression.(*ast.FunctionLiteral)
+
+		if len(function.Parameters) != len(tt.expectedParams) {
+			t.Fatalf("Expected %d parameters, got %d", len(tt.expectedParams), len(function.Parameters))
+		}
+
+		for i, ident := range tt.expectedParams {
+			testLiteralExpression(t, function.Parameters[i], ident)
+		}
+	}
+}
+
+func TestFunctionStatementParsing(t *testing.T) {
+	input := `
+	func add(a, b) {
+		return a + b
+	}
+	add(1, 2)`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("Expected %d statements, got %d", 2, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.FunctionStatement)
+	if !ok {
+		t.Fatalf("Expected *ast.FunctionStatement, got %T", program.Statements[0])
+	}
+
+	testIdentiferLiteral(t, stmt.Name, "add")
+
+	if len(stmt.Function.Parameters) != 2 {
+		t.Fatalf("Expected %d parameters, got %d", 2, len(stmt.Function.Parameters))
+	}
+	testLiteralExpression(t, stmt.Function.Parameters[0], "a")
+	testLiteralExpression(t, stmt.Function.Parameters[1], "b")
+
+	if len(stmt.Function.Body.Statements) != 1 {
+		t.Fatalf("Expected %d statements in body, got %d", 1, len(stmt.Function.Body.Statements))
+	}
+
+	if stmt.String() != "func add(a, b) {return (a + b)}" {
+		t.Fatalf("Unexpected stmt.String(), got %q", stmt.String())
+	}
+
+	call, ok := program.Statements[1].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Expected *ast.ExpressionStatement, got %T", program.Statements[1])
+	}
+	if _, ok := call.Expression.(*ast.CallExpression); !ok {
+		t.Fatalf("Expected *ast.CallExpression, got %T", call.Expression)
+	}
+}
+
+func TestCallExpressionParsing(t *testing.T) {
+	input := "add(1, 2 * 3, 4 + 5)"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("Expected %d statements, got %d", 1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Expected *ast.ExpressionStatement, got %T", program.Statements[0])
+	}
+
+	exp, ok := stmt.Expression.(*ast.CallExpression)
+	if !ok {
+		t.Fatalf("Expected *ast.CallExpression, got %T", stmt.Expression)
+	}
+
+	testIdentiferLiteral(t, exp.Function, "add")
+
+	if len(exp.Arguments) != 3 {
+		t.Fatalf("Expected %d arguments, got %d", 3, len(exp.Arguments))
+	}
+
+	testLiteralExpression(t, exp.Arguments[0], 1)
+	testInfixExpression(t, exp.Arguments[1], 2, "*", 3)
+	testInfixExpression(t, exp.Arguments[2], 4, "+", 5)
+}
+
+func TestDeclareStatements(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedIdent string
+		expectedValue any
+	}{
+		{"x := 5", "x", 5},
+		{"y := true", "y", true},
+		{"foobar := y", "foobar", "y"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("Expected %d statements, got %d", 1, len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.DeclareStatement)
+		if !ok {
+			t.Fatalf("Expected *ast.DeclareStatement, got %T", program.Statements[0])
+		}
+
+		testIdentiferLiteral(t, stmt.Name, tt.expectedIdent)
+		testLiteralExpression(t, stmt.Value, tt.expectedValue)
+	}
+}
+
+func TestAssignStatements(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedIdent  string
+		expectedString string
+	}{
+		{"x = 5", "x", "x = 5"},
+		{"y = a + b * c", "y", "y = (a + (b * c))"},
+		{"add = func(a, b) { a + b }", "add", "add = func(a, b) {(a + b)}"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("Expected %d statements, got %d", 1, len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.AssignStatement)
+		if !ok {
+			t.Fatalf("Expected *ast.AssignStatement, got %T", program.Statements[0])
+		}
+
+		testIdentiferLiteral(t, stmt.Target, tt.expectedIdent)
+
+		if stmt.String() != tt.expectedString {
+			t.Fatalf("Expected stmt.String()=%q, got %q", tt.expectedString, stmt.String())
+		}
+	}
+}
+
+func TestStringLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"hello world"`, "hello world"},
+		{`""`, ""},
+		{`"a\nb\tc"`, "a\nb\tc"},
+		{`"say \"hi\""`, `say "hi"`},
+		{`"back\\slash"`, `back\slash`},
+		{`"\u{48}\u{e9}\u{1F600}"`, "H\u00e9\U0001F600"},
+		{`"cost: \${x}"`, "cost: ${x}"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.StringLiteral)
+		if !ok {
+			t.Fatalf("Expected *ast.StringLiteral, got %T", stmt.Expression)
+		}
+
+		if literal.Value != tt.expected {
+			t.Errorf("Expected literal.Value=%q, got %q", tt.expected, literal.Value)
+		}
+	}
+}
+
+func TestInterpolatedStringParsing(t *testing.T) {
+	input := `"a ${x + 1} b ${f("}")}"`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	str, ok := stmt.Expression.(*ast.InterpolatedString)
+	if !ok {
+		t.Fatalf("Expected *ast.InterpolatedString, got %T", stmt.Expression)
+	}
+
+	expected := []string{`"a "`, "(x + 1)", `" b "`, `f("}")`}
+	if len(str.Parts) != len(expected) {
+		t.Fatalf("Expected %d parts, got %d", len(expected), len(str.Parts))
+	}
+
+	for i, part := range str.Parts {
+		if part.String() != expected[i] {
+			t.Errorf("parts[%d] - expected %q, got %q", i, expected[i], part.String())
+		}
+	}
+}
+
+func TestStringLiteralErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"\q"`, `1:2: unknown escape sequence \q in string literal`},
+		{`"\u{110000}"`, `1:2: invalid unicode escape \u{110000} in string literal`},
+		{`"\u48"`, `1:2: invalid unicode escape in string literal, expected \u{...}`},
+		{`"${}"`, "1:4: empty interpolation in string literal"},
+		{`"${a b}"`, `1:6: unexpected "b" in string interpolation`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Fatalf("Expected parser errors for %s, got none", tt.input)
+		}
+		if errors[0] != tt.expected {
+			t.Errorf("Expected error %q, got %q", tt.expected, errors[0])
+		}
+	}
+}
+
+func TestNodePositions(t *testing.T) {
+	input := "total := add(1, 2)\nif total > 2 {\n  \"sum ${total + 1}\"\n}"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	declare := program.Statements[0].(*ast.DeclareStatement)
+	call := declare.Value.(*ast.CallExpression)
+	ifStmt := program.Statements[1].(*ast.IfStatement)
+	str := ifStmt.Consequence.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.InterpolatedString)
+	interp := str.Parts[1]
+
+	tests := []struct {
+		node        ast.Node
+		expectedPos string
+		expectedEnd string
+	}{
+		{program, "1:1", "4:2"},
+		{declare, "1:1", "1:19"},
+		{call, "1:10", "1:19"},
+		{call.Arguments[1], "1:17", "1:18"},
+		{ifStmt, "2:1", "4:2"},
+		{ifStmt.Condition, "2:4", "2:13"},
+		{ifStmt.Consequence, "2:14", "4:2"},
+		{str, "3:3", "3:21"},
+		{str.Parts[0], "3:4", "3:8"},
+		{interp, "3:10", "3:19"},
+	}
+
+	for i, tt := range tests {
+		if tt.node.Pos().String() != tt.expectedPos {
+			t.Errorf("tests[%d] - %T pos wrong. expected=%s, got=%s", i, tt.node, tt.expectedPos, tt.node.Pos())
+		}
+		if tt.node.End().String() != tt.expectedEnd {
+			t.Errorf("tests[%d] - %T end wrong. expected=%s, got=%s", i, tt.node, tt.expectedEnd, tt.node.End())
+		}
+	}
+}
+
+func TestErrorPositions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"add(1, 2", "1:9: expected next token to be ), got ; instead"},
+		{"add(1,", "1:7: prefix parse function for \"\" [EOF] not found"},
+		{"x := 1\nfunc f(a b) {}", "2:10: expected next token to be ), got IDENT instead"},
+		{"1 +\n  )", "2:3: prefix parse function for \")\" [)] not found"},
+		{"größe := 1 + \xff", "1:16: invalid UTF-8 encoding: byte 0xff"},
+		{"x := \"ok\"\ny := \"a\xc3\"", "2:8: invalid UTF-8 encoding: byte 0xc3"},
+		{"x := 1 € 2", "1:8: illegal character \"€\""},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Fatalf("Expected parser errors for %q, got none", tt.input)
+		}
+		if errors[0] != tt.expected {
+			t.Errorf("Expected error %q, got %q", tt.expected, errors[0])
+		}
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	input := "f(1, 2\n\"abc"
+
+	l := lexer.New(input)
+	p := New(l)
+	p.ParseProgram()
+
+	diagnostics := p.Diagnostics()
+	if len(diagnostics) != 2 {
+		t.Fatalf("Expected %d diagnostics, got %d: %v", 2, len(diagnostics), p.Errors())
+	}
+
+	missing := diagnostics[0]
+	if missing.Code != CodeUnexpectedToken {
+		t.Errorf("Expected code %s, got %s", CodeUnexpectedToken, missing.Code)
+	}
+	if missing.Span.Start.String() != "1:7" {
+		t.Errorf("Expected span to start at 1:7, got %s", missing.Span.Start)
+	}
+	if missing.Fix == nil || missing.Fix.Replacement != ")" || missing.Fix.Span.Start.String() != "1:7" {
+		t.Errorf("Expected fix inserting \")\" at 1:7, got %+v", missing.Fix)
+	}
+
+	unterminated := diagnostics[1]
+	if unterminated.Code != CodeInvalidString {
+		t.Errorf("Expected code %s, got %s", CodeInvalidString, unterminated.Code)
+	}
+	if unterminated.Message != "unterminated string literal" {
+		t.Errorf("Expected unterminated string message, got %q", unterminated.Message)
+	}
+	if unterminated.Span.Start.String() != "2:1" || unterminated.Span.End.String() != "2:5" {
+		t.Errorf("Expected span 2:1-2:5, got %s-%s", unterminated.Span.Start, unterminated.Span.End)
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input              string
+		expectedErrors     []string
+		expectedStatements []string
+	}{
+		{
+			"x := 1 +;\ny := 2",
+			[]string{`1:9: prefix parse function for ";" [;] not found`},
+			[]string{"x := (1 + <bad expression>)", "y := 2"},
+		},
+		{
+			"func f() {\n  x + \n}\ny := 2",
+			[]string{`3:1: prefix parse function for "}" [}] not found`},
+			[]string{"func f() {(x + <bad expression>)}", "y := 2"},
+		},
+		{
+			"if x == 1\n{ y }\nz",
+			[]string{"1:10: expected next token to be {, got ; instead"},
+			[]string{"<bad statement>", "{y}", "z"},
+		},
+		{
+			"add(1, 2\nx := )(\nreturn x",
+			[]string{
+				"1:9: expected next token to be ), got ; instead",
+				`2:6: prefix parse function for ")" [)] not found`,
+			},
+			[]string{"add(1, 2)", "x := <bad expression>", "return x"},
+		},
+		{
+			"a := ) ) ) ) )\nb := 1",
+			[]string{`1:6: prefix parse function for ")" [)] not found`},
+			[]string{"a := <bad expression>", "b := 1"},
+		},
+		{
+			"if a { b := }\nc",
+			[]string{`1:13: prefix parse function for "}" [}] not found`},
+			[]string{"if a {b := <bad expression>}", "c"},
+		},
+		{
+			"x := (1 + 2\ny := \"\\q\" + 1\nz := 3",
+			[]string{
+				"1:12: expected next token to be ), got ; instead",
+				`2:7: unknown escape sequence \q in string literal`,
+			},
+			[]string{"x := <bad expression>", "y := (<bad expression> + 1)", "z := 3"},
+		},
+		{
+			"}\nx",
+			[]string{`1:1: prefix parse function for "}" [}] not found`},
+			[]string{"<bad expression>", "x"},
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != len(tt.expectedErrors) {
+			t.Errorf("%q - expected %d errors, got %d: %q", tt.input, len(tt.expectedErrors), len(errors), errors)
+			continue
+		}
+		for i, msg := range tt.expectedErrors {
+			if errors[i] != msg {
+				t.Errorf("%q - errors[%d] expected %q, got %q", tt.input, i, msg, errors[i])
+			}
+		}
+
+		if len(program.Statements) != len(tt.expectedStatements) {
+			t.Errorf("%q - expected %d statements, got %d: %q", tt.input, len(tt.expectedStatements), len(program.Statements), program.String())
+			continue
+		}
+		for i, expected := range tt.expectedStatements {
+			if program.Statements[i].String() != expected {
+				t.Errorf("%q - statements[%d] expected %q, got %q", tt.input, i, expected, program.Statements[i].String())
+			}
+		}
+	}
+}
+
+func TestBadNodeSpans(t *testing.T) {
+	input := "x := 1\nif x\n  y := 2\n"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 1 {
+		t.Fatalf("Expected %d error, got %d: %q", 1, len(p.Errors()), p.Errors())
+	}
+
+	bad, ok := program.Statements[1].(*ast.BadStatement)
+	if !ok {
+		t.Fatalf("Expected *ast.BadStatement, got %T", program.Statements[1])
+	}
+
+	if bad.Pos().String() != "2:1" || bad.End().String() != "2:6" {
+		t.Fatalf("Expected bad statement to span 2:1-2:6, got %s-%s", bad.Pos(), bad.End())
+	}
+
+	if _, ok := program.Statements[2].(*ast.DeclareStatement); !ok {
+		t.Fatalf("Expected parsing to resume with *ast.DeclareStatement, got %T", program.Statements[2])
+	}
+}
+
+func TestArrayLiteralParsing(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3]"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	array, ok := stmt.Expression.(*ast.ArrayLiteral)
+	if !ok {
+		t.Fatalf("Expected *ast.ArrayLiteral, got %T", stmt.Expression)
+	}
+
+	if len(array.Elements) != 3 {
+		t.Fatalf("Expected %d elements, got %d", 3, len(array.Elements))
+	}
+
+	testIntegerLiteral(t, array.Elements[0], 1)
+	testInfixExpression(t, array.Elements[1], 2, "*", 2)
+	testInfixExpression(t, array.Elements[2], 3, "+", 3)
+
+	if array.Pos().String() != "1:1" || array.End().String() != "1:18" {
+		t.Fatalf("Expected array to span 1:1-1:18, got %s-%s", array.Pos(), array.End())
+	}
+}
+
+func TestEmptyArrayLiteralParsing(t *testing.T) {
+	l := lexer.New("[]")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	array, ok := stmt.Expression.(*ast.ArrayLiteral)
+	if !ok {
+		t.Fatalf("Expected *ast.ArrayLiteral, got %T", stmt.Expression)
+	}
+
+	if len(array.Elements) != 0 {
+		t.Fatalf("Expected no elements, got %d", len(array.Elements))
+	}
+}
+
+func TestIndexExpressionParsing(t *testing.T) {
+	input := "myArray[1 + 1]"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	indexExp, ok := stmt.Expression.(*ast.IndexExpression)
+	if !ok {
+		t.Fatalf("Expected *ast.IndexExpression, got %T", stmt.Expression)
+	}
+
+	testIdentiferLiteral(t, indexExp.Left, "myArray")
+	testInfixExpression(t, indexExp.Index, 1, "+", 1)
+}
+
+func TestSliceExpressionParsing(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedLow  any
+		expectedHigh any
+	}{
+		{"xs[1:2]", 1, 2},
+		{"xs[1:]", 1, nil},
+		{"xs[:2]", nil, 2},
+		{"xs[:]", nil, nil},
+		{"xs[a:n]", "a", "n"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		slice, ok := stmt.Expression.(*ast.SliceExpression)
+		if !ok {
+			t.Fatalf("Expected *ast.SliceExpression, got %T", stmt.Expression)
+		}
+
+		testIdentiferLiteral(t, slice.Left, "xs")
+
+		if tt.expectedLow == nil {
+			if slice.Low != nil {
+				t.Fatalf("Expected no low bound, got %s", slice.Low)
+			}
+		} else {
+			testLiteralExpression(t, slice.Low, tt.expectedLow)
+		}
+
+		if tt.expectedHigh == nil {
+			if slice.High != nil {
+				t.Fatalf("Expected no high bound, got %s", slice.High)
+			}
+		} else {
+			testLiteralExpression(t, slice.High, tt.expectedHigh)
+		}
+	}
+}
+
+func TestHashLiteralParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`m := {}`, "m := {}"},
+		{`m := {"one": 1, "two": 2, "three": 3}`, `m := {"one": 1, "two": 2, "three": 3}`},
+		{`m := {"a": 1, 2: true, false: 2.5}`, `m := {"a": 1, 2: true, false: 2.5}`},
+		{`m := {"one": 0 + 1, "two": 10 - 8}`, `m := {"one": (0 + 1), "two": (10 - 8)}`},
+		{"m := {\n\t\"a\": 1,\n\t\"b\": [1, 2,],\n}", `m := {"a": 1, "b": [1, 2]}`},
+		{`f({"a": 1}["a"])`, `f(({"a": 1}["a"]))`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("%s - expected %d statement, got %d", tt.input, 1, len(program.Statements))
+		}
+
+		if program.String() != tt.expected {
+			t.Errorf("Expected %q, got %q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestHashLiteralPairs(t *testing.T) {
+	l := lexer.New(`m := {"one": 1, "two": 2}`)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.DeclareStatement)
+	hash, ok := stmt.Value.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("Expected *ast.HashLiteral, got %T", stmt.Value)
+	}
+
+	if len(hash.Pairs) != 2 {
+		t.Fatalf("Expected %d pairs, got %d", 2, len(hash.Pairs))
+	}
+
+	expectedKeys := []string{"one", "two"}
+	for i, pair := range hash.Pairs {
+		key, ok := pair.Key.(*ast.StringLiteral)
+		if !ok {
+			t.Fatalf("Expected key to be *ast.StringLiteral, got %T", pair.Key)
+		}
+		if key.Value != expectedKeys[i] {
+			t.Errorf("Expected key %q, got %q", expectedKeys[i], key.Value)
+		}
+		testIntegerLiteral(t, pair.Value, int64(i+1))
+	}
+}
+
+func TestBraceDisambiguation(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"if x { 1 }", "if x {1}"},
+		{"if ({\"a\": true})[\"a\"] { 1 }", `if ({"a": true}["a"]) {1}`},
+		{"if has({\"a\": 1}, k) { 1 }", `if has({"a": 1}, k) {1}`},
+		{"if xs[{1: 0}[1]] { 1 }", "if (xs[({1: 0}[1])]) {1}"},
+		{"if f(func() { return {} }) { 1 }", "if f(func() {return {}}) {1}"},
+		{"{ x := {} }", "{x := {}}"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("Expected %q, got %q", tt.expected, program.String())
+		}
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{"if { 1 }", "1:4: missing condition in if statement"},
+		{"if x == {} { 1 }", "1:9: expected expression, found '{'"},
+	}
+
+	for _, tt := range errorTests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != 1 || errors[0] != tt.expected {
+			t.Errorf("%q - expected error %q, got %q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestIndexAssignStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`m["a"] = 1`, `(m["a"]) = 1`},
+		{`xs[i + 1] = xs[i]`, `(xs[(i + 1)]) = (xs[i])`},
+		{`m["a"][0] = f(1)`, `((m["a"])[0]) = f(1)`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.AssignStatement)
+		if !ok {
+			t.Fatalf("Expected *ast.AssignStatement, got %T", program.Statements[0])
+		}
+		if _, ok := stmt.Target.(*ast.IndexExpression); !ok {
+			t.Fatalf("Expected target to be *ast.IndexExpression, got %T", stmt.Target)
+		}
+
+		if stmt.String() != tt.expected {
+			t.Errorf("Expected %q, got %q", tt.expected, stmt.String())
+		}
+	}
+}
+
+func TestInvalidAssignTarget(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"f() = 1", "1:1: cannot assign to f()"},
+		{"1 + 2 = 3", "1:1: cannot assign to (1 + 2)"},
+		{"xs[1:2] = ys", "1:1: cannot assign to (xs[1:2])"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != 1 || errors[0] != tt.expected {
+			t.Errorf("%q - expected error %q, got %q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestHashLiteralRecovery(t *testing.T) {
+	input := "func f() {\n\tm := {\"a\" 1, \"b\": 2}\n\treturn m\n}\ng := 1"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) != 1 || errors[0] != "2:12: expected next token to be :, got INT instead" {
+		t.Fatalf("Expected a single missing colon error, got %q", errors)
+	}
+
+	expected := "func f() {m := <bad expression>return m}g := 1"
+	if program.String() != expected {
+		t.Fatalf("Expected %q, got %q", expected, program.String())
+	}
+}
+
+func TestWhileStatement(t *testing.T) {
+	input := `while x < y { x = x + 1 }`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("Expected %d Statementes, got %d", 1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.WhileStatement)
+	if !ok {
+		t.Fatalf("Expected statement of type *ast.WhileStatement, got %T", program.Statements[0])
+	}
+
+	testInfixExpression(t, stmt.Condition, "x", "<", "y")
+
+	if len(stmt.Body.Statements) != 1 {
+		t.Fatalf("Expected %d statements in body, got %d", 1, len(stmt.Body.Statements))
+	}
+
+	if stmt.String() != "while (x < y) {x = (x + 1)}" {
+		t.Fatalf("Expected %q, got %q", "while (x < y) {x = (x + 1)}", stmt.String())
+	}
+}
+
+func TestForInStatement(t *testing.T) {
+	input := `for x in [1, 2] { puts(x) }`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("Expected %d Statementes, got %d", 1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ForInStatement)
+	if !ok {
+		t.Fatalf("Expected statement of type *ast.ForInStatement, got %T", program.Statements[0])
+	}
+
+	testIdentiferLiteral(t, stmt.Variable, "x")
+
+	if _, ok := stmt.Iterable.(*ast.ArrayLiteral); !ok {
+		t.Fatalf("Expected iterable to be *ast.ArrayLiteral, got %T", stmt.Iterable)
+	}
+
+	if len(stmt.Body.Statements) != 1 {
+		t.Fatalf("Expected %d statements in body, got %d", 1, len(stmt.Body.Statements))
+	}
+}
+
+func TestForStatement(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"for i := 0; i < 10; i = i + 1 { puts(i) }", "for i := 0; (i < 10); i = (i + 1) {puts(i)}"},
+		{"for i = 0; i < 10; i = i + 1 {}", "for i = 0; (i < 10); i = (i + 1) {}"},
+		{"for ; i < 10; {}", "for ; (i < 10); {}"},
+		{"for ;; { break }", "for ; ; {break}"},
+		{"for f(); ; g() {}", "for f(); ; g() {}"},
+		{"for x in {\"a\": 1} {}", ""},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		if tt.expected == "" {
+			// a map literal in a loop header has to be parenthesised
+			if len(p.Errors()) == 0 {
+				t.Errorf("%q - expected errors", tt.input)
+			}
+			continue
+		}
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("%q - expected %d statements, got %d", tt.input, 1, len(program.Statements))
+		}
+
+		stmt, ok := program.Statements[0].(*ast.ForStatement)
+		if !ok {
+			t.Fatalf("%q - expected *ast.ForStatement, got %T", tt.input, program.Statements[0])
+		}
+
+		if stmt.String() != tt.expected {
+			t.Errorf("Expected %q, got %q", tt.expected, stmt.String())
+		}
+	}
+}
+
+func TestBranchStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"while true { break }", "while true {break}"},
+		{"while true { continue\n}", "while true {continue}"},
+		{"outer: while true { for x in xs { break outer } }", "outer: while true {for x in xs {break outer}}"},
+		{"outer: for x in xs {\n\tfor y in ys {\n\t\tcontinue outer\n\t}\n}", "outer: for x in xs {for y in ys {continue outer}}"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("%q - expected %d statements, got %d", tt.input, 1, len(program.Statements))
+		}
+
+		if program.String() != tt.expected {
+			t.Errorf("Expected %q, got %q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestBranchStatementErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"break", "1:1: break outside of a loop"},
+		{"if true { continue }", "1:11: continue outside of a loop"},
+		{"while true { func() { break } }", "1:23: break outside of a loop"},
+		{"while true { break outer }", "1:20: break label not defined: outer"},
+		{"outer: if true {}", "1:1: label outer must be followed by a loop"},
+		{"a: while true { a: while true {} }", "1:17: label a already defined"},
+		{"while {}", "1:7: missing condition in while statement"},
+		{"for i := 0; i < 3 {}", "1:19: expected next token to be ;, got { instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != 1 {
+			t.Errorf("%q - expected %d errors, got %d: %q", tt.input, 1, len(errors), errors)
+			continue
+		}
+		if errors[0] != tt.expected {
+			t.Errorf("%q - expected %q, got %q", tt.input, tt.expected, errors[0])
+		}
+	}
+}
+
+func TestTypeAnnotations(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x: int := 5", "x: int := 5"},
+		{"outer: x := 1", "outer: x := 1"},
+		{"xs: [string] := []", "xs: [string] := []"},
+		{"m: {string: [int]} := {}", "m: {string: [int]} := {}"},
+		{"n: int? := f()", "n: int? := f()"},
+		{"f: func(int, int): bool := g", "f: func(int, int): bool := g"},
+		{"f: func() := g", "f: func() := g"},
+		{"g: func(): int? := h", "g: func(): int? := h"},
+		{"func add(a: int, b: int): int { a + b }", "func add(a: int, b: int): int {(a + b)}"},
+		{"func f(a, b: [int]) { a }", "func f(a, b: [int]) {a}"},
+		{"func f(): {string: int} { x }", "func f(): {string: int} {x}"},
+		{"func f(): func(): int { g }", "func f(): func(): int {g}"},
+		{"func(x: int?): int { x }", "func(x: int?): int {x}"},
+		{"for i: int := 0; i < 3; i = i + 1 { }", "for i: int := 0; (i < 3); i = (i + 1) {}"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("%q - expected %d statements, got %d", tt.input, 1, len(program.Statements))
+		}
+
+		if program.String() != tt.expected {
+			t.Errorf("Expected %q, got %q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestTypeAnnotationNodes(t *testing.T) {
+	program := New(lexer.New("func f(a: [int], b): {string: int?} { }")).ParseProgram()
+	fn := program.Statements[0].(*ast.FunctionStatement).Function
+
+	if len(fn.ParameterTypes) != 2 {
+		t.Fatalf("Expected 2 parameter types, got %d", len(fn.ParameterTypes))
+	}
+	list, ok := fn.ParameterTypes[0].(*ast.ListType)
+	if !ok {
+		t.Fatalf("Expected *ast.ListType, got %T", fn.ParameterTypes[0])
+	}
+	if named, ok := list.Elem.(*ast.NamedType); !ok || named.Name != "int" {
+		t.Errorf("Expected the element type int, got %s", list.Elem)
+	}
+	if fn.ParameterTypes[1] != nil {
+		t.Errorf("Expected no type for b, got %s", fn.ParameterTypes[1])
+	}
+
+	m, ok := fn.ReturnType.(*ast.MapType)
+	if !ok {
+		t.Fatalf("Expected *ast.MapType, got %T", fn.ReturnType)
+	}
+	if _, ok := m.Value.(*ast.OptionalType); !ok {
+		t.Errorf("Expected *ast.OptionalType, got %T", m.Value)
+	}
+	if m.Pos().Column != 22 || m.End().Column != 36 {
+		t.Errorf("Expected the return type to span columns 22 to 36, got %d to %d", m.Pos().Column, m.End().Column)
+	}
+}
+
+func TestTypeAnnotationErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x: 5 := 1", "1:1: label x must be followed by a loop"},
+		{"func f(a: 1) {}", "1:11: expected a type, got INT instead"},
+		{"func f(): { a }", "1:15: expected next token to be :, got } instead"},
+		{"x: [int := 1", "1:9: expected next token to be ], got := instead"},
+		{"x: int = 1", "1:8: expected next token to be :=, got = instead"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("%q - expected errors, got none", tt.input)
+			continue
+		}
+		if errors[0] != tt.expected {
+			t.Errorf("%q - expected %q, got %q", tt.input, tt.expected, errors[0])
+		}
+	}
+}
+
+func TestNumberLiteralForms(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{"1_000", 1000},
+		{"0xff", 255},
+		{"0o17", 15},
+		{"017", 15},
+		{"0b101", 5},
+		{".5", 0.5},
+		{"1e3", 1000.0},
+		{"2.5E-1", 0.25},
+		{"1_0.2_5", 10.25},
+		{"19.99d", "19.99"},
+		{"1e2d", "100"},
+		{"18446744073709551616", "big:18446744073709551616"},
+		{"0x1_0000_0000_0000_0000", "big:18446744073709551616"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		switch expected := tt.expected.(type) {
+		case int:
+			literal, ok := stmt.Expression.(*ast.IntegerLiteral)
+			if !ok {
+				t.Fatalf("%q - expected *ast.IntegerLiteral, got %T", tt.input, stmt.Expression)
+			}
+			if literal.Value != int64(expected) {
+				t.Errorf("%q - expected %d, got %d", tt.input, expected, literal.Value)
+			}
+		case float64:
+			literal, ok := stmt.Expression.(*ast.FloatLiteral)
+			if !ok {
+				t.Fatalf("%q - expected *ast.FloatLiteral, got %T", tt.input, stmt.Expression)
+			}
+			if literal.Value != expected {
+				t.Errorf("%q - expected %g, got %g", tt.input, expected, literal.Value)
+			}
+		case string:
+			if big, ok := strings.CutPrefix(expected, "big:"); ok {
+				literal, ok := stmt.Expression.(*ast.IntegerLiteral)
+				if !ok {
+					t.Fatalf("%q - expected *ast.IntegerLiteral, got %T", tt.input, stmt.Expression)
+				}
+				if literal.Big == nil || literal.Big.String() != big {
+					t.Errorf("%q - expected big value %s, got %v", tt.input, big, literal.Big)
+				}
+				break
+			}
+			literal, ok := stmt.Expression.(*ast.DecimalLiteral)
+			if !ok {
+				t.Fatalf("%q - expected *ast.DecimalLiteral, got %T", tt.input, stmt.Expression)
+			}
+			if literal.Value.String() != expected {
+				t.Errorf("%q - expected %s, got %s", tt.input, expected, literal.Value.String())
+			}
+		}
+
+		if stmt.String() != tt.input {
+			t.Errorf("Expected literal to be printed as written %q, got %q", tt.input, stmt.String())
+		}
+	}
+}
+
+func TestMalformedNumberLiterals(t *testing.T) {
+	tests := []struct {
+		input        string
+		expected     string
+		expectedCode string
+	}{
+		{"x := 1.2.3", `1:6: malformed number literal "1.2.3"`, CodeInvalidNumber},
+		{"x := 0xZZ + 1", `1:6: malformed number literal "0xZZ"`, CodeInvalidNumber},
+		{"f(1, .5.)", `1:6: malformed number literal ".5."`, CodeInvalidNumber},
+		{"x := 1 . 2", `1:8: illegal character "."`, CodeIllegalToken},
+		{"x := 1 /* open", `1:8: unterminated block comment`, CodeInvalidComment},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != 1 {
+			t.Errorf("%q - expected %d errors, got %d: %q", tt.input, 1, len(errors), errors)
+			continue
+		}
+		if errors[0] != tt.expected {
+			t.Errorf("%q - expected %q, got %q", tt.input, tt.expected, errors[0])
+		}
+		if p.Diagnostics()[0].Code != tt.expectedCode {
+			t.Errorf("%q - expected code %s, got %s", tt.input, tt.expectedCode, p.Diagnostics()[0].Code)
+		}
+	}
+}
+
+func TestDocComments(t *testing.T) {
+	input := `
+/// Returns the sum
+/// of a and b.
+func add(a, b) { a + b }
+
+// not a doc comment
+func sub(a, b) { a - b }
+`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	expected := []string{"Returns the sum\nof a and b.", ""}
+	if len(program.Statements) != len(expected) {
+		t.Fatalf("Expected %d statements, got %d", len(expected), len(program.Statements))
+	}
+
+	for i, doc := range expected {
+		stmt, ok := program.Statements[i].(*ast.FunctionStatement)
+		if !ok {
+			t.Fatalf("Expected *ast.FunctionStatement, got %T", program.Statements[i])
+		}
+		if stmt.Doc != doc {
+			t.Errorf("Expected doc %q, got %q", doc, stmt.Doc)
+		}
+	}
+}
+
+func TestUnicodeIdentifierStatements(t *testing.T) {
+	input := `größe := 変数 + x٣`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if program.String() != "größe := (変数 + x٣)" {
+		t.Fatalf("Expected %q, got %q", "größe := (変数 + x٣)", program.String())
+	}
+}
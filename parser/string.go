@@ -0,0 +1,186 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/chaitanya-Uike/lemon/ast"
+	"github.com/chaitanya-Uike/lemon/diagnostic"
+	"github.com/chaitanya-Uike/lemon/lexer"
+	"github.com/chaitanya-Uike/lemon/token"
+)
+
+// parseStringLiteral decodes the raw contents of a STRING token. Escape
+// sequences are resolved here and every ${...} is parsed as an expression with
+// a parser of its own, a literal without interpolations stays a plain
+// *ast.StringLiteral.
+func (p *Parser) parseStringLiteral() ast.Expression {
+	raw := p.curToken.Literal
+
+	// raw starts right after the opening quote, string literals never span
+	// lines so an index into raw maps to a column directly
+	start := p.curToken.Pos
+	rawPos := func(i int) token.Position {
+		return token.Position{Offset: start.Offset + 1 + i, Line: start.Line, Column: start.Column + 1 + i}
+	}
+	rawSpan := func(from, to int) diagnostic.Span {
+		return diagnostic.Span{Start: rawPos(from), End: rawPos(min(to, len(raw)))}
+	}
+
+	var (
+		parts    []ast.Expression
+		segment  strings.Builder
+		segStart int
+		interped bool
+	)
+
+	flushSegment := func(end int) {
+		if segment.Len() == 0 {
+			return
+		}
+		tok := token.Token{Type: token.STRING, Literal: raw[segStart:end], Pos: rawPos(segStart), End: rawPos(end)}
+		parts = append(parts, &ast.StringLiteral{Token: tok, Value: segment.String()})
+		segment.Reset()
+	}
+
+	for i := 0; i < len(raw); {
+		switch {
+		case raw[i] == '\\':
+			r, n, err := decodeEscape(raw[i:])
+			if err != nil {
+				p.report(CodeInvalidString, rawSpan(i, i+2), "%s", err)
+				return &ast.BadExpression{From: p.curToken, To: p.curToken}
+			}
+			segment.WriteRune(r)
+			i += n
+		case raw[i] == '$' && i+1 < len(raw) && raw[i+1] == '{':
+			end := interpolationEnd(raw, i+2)
+			if end < 0 {
+				p.report(CodeInvalidString, rawSpan(i, len(raw)), "unterminated interpolation in string literal")
+				return &ast.BadExpression{From: p.curToken, To: p.curToken}
+			}
+
+			flushSegment(i)
+			exp := p.parseInterpolation(raw[i+2:end], rawPos(i+2))
+			if exp == nil {
+				return &ast.BadExpression{From: p.curToken, To: p.curToken}
+			}
+			parts = append(parts, exp)
+			interped = true
+
+			i = end + 1
+			segStart = i
+		default:
+			segment.WriteByte(raw[i])
+			i++
+		}
+	}
+
+	if !interped {
+		return &ast.StringLiteral{Token: p.curToken, Value: segment.String()}
+	}
+
+	flushSegment(len(raw))
+	return &ast.InterpolatedString{Token: p.curToken, Parts: parts}
+}
+
+func (p *Parser) parseInterpolation(src string, pos token.Position) ast.Expression {
+	sub := New(lexer.NewAt(src, pos))
+	if sub.curTokenIs(token.EOF) {
+		p.report(CodeInvalidString, diagnostic.Span{Start: pos, End: pos}, "empty interpolation in string literal")
+		return nil
+	}
+
+	exp := sub.parseExpression(LOWEST)
+	if sub.peekTokenIs(token.SEMICOLON) {
+		sub.nextToken()
+	}
+	if !sub.peekTokenIs(token.EOF) {
+		sub.report(CodeUnexpectedToken, diagnostic.SpanOf(sub.peekToken), "unexpected %q in string interpolation", sub.peekToken.Literal)
+	}
+
+	if len(sub.diagnostics) != 0 {
+		p.diagnostics = append(p.diagnostics, sub.diagnostics...)
+		p.panicking = true
+		return nil
+	}
+	return exp
+}
+
+// decodeEscape decodes the escape sequence at the start of s and reports how
+// many bytes of s it consumed.
+func decodeEscape(s string) (rune, int, error) {
+	if len(s) < 2 {
+		return 0, 0, fmt.Errorf("unterminated escape sequence in string literal")
+	}
+
+	switch s[1] {
+	case 'n':
+		return '\n', 2, nil
+	case 't':
+		return '\t', 2, nil
+	case 'r':
+		return '\r', 2, nil
+	case '"':
+		return '"', 2, nil
+	case '\\':
+		return '\\', 2, nil
+	case '$':
+		return '$', 2, nil
+	case 'u':
+		end := strings.IndexByte(s, '}')
+		if len(s) < 3 || s[2] != '{' || end < 0 {
+			return 0, 0, fmt.Errorf("invalid unicode escape in string literal, expected \\u{...}")
+		}
+		digits := s[3:end]
+		value, err := strconv.ParseUint(digits, 16, 32)
+		if err != nil || len(digits) == 0 || len(digits) > 6 || !utf8.ValidRune(rune(value)) {
+			return 0, 0, fmt.Errorf("invalid unicode escape \\u{%s} in string literal", digits)
+		}
+		return rune(value), end + 1, nil
+	default:
+		return 0, 0, fmt.Errorf("unknown escape sequence \\%c in string literal", s[1])
+	}
+}
+
+// interpolationEnd returns the index of the '}' closing the interpolation
+// whose body starts at i, skipping nested braces and string literals.
+func interpolationEnd(raw string, i int) int {
+	depth := 1
+	for ; i < len(raw); i++ {
+		switch raw[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		case '"':
+			if i = stringEnd(raw, i+1); i < 0 {
+				return -1
+			}
+		}
+	}
+	return -1
+}
+
+func stringEnd(raw string, i int) int {
+	for ; i < len(raw); i++ {
+		switch raw[i] {
+		case '"':
+			return i
+		case '\\':
+			i++
+		case '$':
+			if i+1 < len(raw) && raw[i+1] == '{' {
+				if i = interpolationEnd(raw, i+2); i < 0 {
+					return -1
+				}
+			}
+		}
+	}
+	return -1
+}
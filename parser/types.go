@@ -0,0 +1,105 @@
+package parser
+
+import (
+	"github.com/chaitanya-Uike/lemon/ast"
+	"github.com/chaitanya-Uike/lemon/diagnostic"
+	"github.com/chaitanya-Uike/lemon/token"
+)
+
+// parseType parses a type annotation starting at the current token, which
+// is left on the last token of the type:
+//
+//	int   [int]   {string: int}   func(int, int): int   int?
+func (p *Parser) parseType() ast.TypeExpression {
+	var typ ast.TypeExpression
+
+	switch p.curToken.Type {
+	case token.IDENT:
+		typ = &ast.NamedType{Token: p.curToken, Name: p.curToken.Literal}
+	case token.LBRACKET:
+		list := &ast.ListType{Token: p.curToken}
+		p.nextToken()
+		if list.Elem = p.parseType(); list.Elem == nil || !p.expectPeek(token.RBRACKET) {
+			return nil
+		}
+		list.Rbracket = p.curToken
+		typ = list
+	case token.LBRACE:
+		m := &ast.MapType{Token: p.curToken}
+		p.nextToken()
+		if m.Key = p.parseType(); m.Key == nil || !p.expectPeek(token.COLON) {
+			return nil
+		}
+		p.nextToken()
+		if m.Value = p.parseType(); m.Value == nil || !p.expectPeek(token.RBRACE) {
+			return nil
+		}
+		m.Rbrace = p.curToken
+		typ = m
+	case token.FUNC:
+		if typ = p.parseFunctionType(); typ == nil {
+			return nil
+		}
+	default:
+		p.report(CodeInvalidType, diagnostic.SpanOf(p.curToken), "expected a type, got %s instead", p.curToken.Type)
+		return nil
+	}
+
+	for p.peekTokenIs(token.QUESTION) {
+		p.nextToken()
+		typ = &ast.OptionalType{Elem: typ, Question: p.curToken}
+	}
+	return typ
+}
+
+func (p *Parser) parseFunctionType() ast.TypeExpression {
+	fn := &ast.FunctionType{Token: p.curToken, Params: []ast.TypeExpression{}}
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	if !p.peekTokenIs(token.RPAREN) {
+		for {
+			p.nextToken()
+			param := p.parseType()
+			if param == nil {
+				return nil
+			}
+			fn.Params = append(fn.Params, param)
+
+			if !p.peekTokenIs(token.COMMA) {
+				break
+			}
+			p.nextToken()
+		}
+	}
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	fn.Rparen = p.curToken
+
+	if p.peekTokenIs(token.COLON) {
+		var ok bool
+		if fn.Result, ok = p.parseAnnotation(); !ok {
+			return nil
+		}
+	}
+	return fn
+}
+
+// parseAnnotation parses the type following the colon in the peek token.
+func (p *Parser) parseAnnotation() (ast.TypeExpression, bool) {
+	p.nextToken()
+	p.nextToken()
+	typ := p.parseType()
+	return typ, typ != nil
+}
+
+// startsType reports whether a type annotation can start with t.
+func startsType(t token.TokenType) bool {
+	switch t {
+	case token.IDENT, token.LBRACKET, token.LBRACE, token.FUNC:
+		return true
+	}
+	return false
+}
@@ -4,16 +4,23 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"os"
 
+	"github.com/chaitanya-Uike/lemon/diagnostic"
+	"github.com/chaitanya-Uike/lemon/evaluator"
 	"github.com/chaitanya-Uike/lemon/lexer"
-	"github.com/chaitanya-Uike/lemon/token"
+	"github.com/chaitanya-Uike/lemon/object"
+	"github.com/chaitanya-Uike/lemon/parser"
 )
 
+const PROMPT = ">> "
+
 func Start(in io.Reader, out io.Writer) {
 	scanner := bufio.NewScanner(in)
+	env := object.NewEnvironment()
 
 	for {
-		fmt.Printf(">> ")
+		fmt.Fprint(out, PROMPT)
 		scanned := scanner.Scan()
 		if !scanned {
 			return
@@ -21,9 +28,27 @@ func Start(in io.Reader, out io.Writer) {
 
 		line := scanner.Text()
 		l := lexer.New(line)
+		p := parser.New(l)
+
+		program := p.ParseProgram()
+		if len(p.Diagnostics()) != 0 {
+			printParserErrors(out, line, p.Diagnostics())
+			continue
+		}
 
-		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
-			fmt.Printf("%+v\n", tok)
+		evaluated := evaluator.Eval(program, env)
+		if evaluated != nil {
+			fmt.Fprintln(out, evaluated.Inspect())
 		}
 	}
 }
+
+func printParserErrors(out io.Writer, line string, diagnostics []*diagnostic.Diagnostic) {
+	color := false
+	if f, ok := out.(*os.File); ok {
+		color = diagnostic.IsTerminal(f)
+	}
+
+	r := diagnostic.NewRenderer(line, "", color)
+	r.RenderAll(out, diagnostics)
+}
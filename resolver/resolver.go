@@ -0,0 +1,313 @@
+// Package resolver checks the names of a program before it runs: every
+// identifier must refer to a declared variable or a builtin, a block must
+// not declare a name twice and return may only appear in functions.
+package resolver
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/chaitanya-Uike/lemon/ast"
+	"github.com/chaitanya-Uike/lemon/diagnostic"
+	"github.com/chaitanya-Uike/lemon/evaluator"
+)
+
+// diagnostic codes reported by the resolver
+const (
+	CodeUndefined     = "R001"
+	CodeDuplicate     = "R002"
+	CodeReturnOutside = "R003"
+)
+
+type Kind int
+
+const (
+	// Global is a variable declared at the top level of the program.
+	Global Kind = iota
+	// Local is a variable of the function the identifier is in, or of a
+	// block of the program.
+	Local
+	// Upvalue is a variable of an enclosing function.
+	Upvalue
+	Builtin
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Global:
+		return "global"
+	case Local:
+		return "local"
+	case Upvalue:
+		return "upvalue"
+	case Builtin:
+		return "builtin"
+	default:
+		return fmt.Sprintf("kind(%d)", int(k))
+	}
+}
+
+// Binding is what an identifier refers to, Decl is the identifier that
+// declares the variable and nil for builtins.
+type Binding struct {
+	Kind Kind
+	Decl *ast.IdentifierLiteral
+}
+
+type Resolution struct {
+	// Bindings holds the binding of every identifier naming a variable,
+	// declarations included. Labels and undefined names have none.
+	Bindings    map[*ast.IdentifierLiteral]Binding
+	Diagnostics []*diagnostic.Diagnostic
+}
+
+// Resolve resolves the names of program. Names are looked up like the
+// evaluator does: code sees the variables of its own function once they are
+// declared, while a function body sees every variable of the scopes around
+// it, since it only runs once they are declared.
+func Resolve(program *ast.Program) *Resolution {
+	r := &resolver{res: &Resolution{Bindings: make(map[*ast.IdentifierLiteral]Binding)}}
+
+	r.openScope()
+	r.statements(program.Statements)
+	r.closeScope()
+
+	sort.SliceStable(r.res.Diagnostics, func(i, j int) bool {
+		return r.res.Diagnostics[i].Span.Start.Offset < r.res.Diagnostics[j].Span.Start.Offset
+	})
+	return r.res
+}
+
+type symbol struct {
+	decl     *ast.IdentifierLiteral
+	declared bool
+}
+
+type scope struct {
+	outer   *scope
+	depth   int // the number of functions around the scope
+	symbols map[string]*symbol
+}
+
+type resolver struct {
+	scope *scope
+	depth int
+	res   *Resolution
+}
+
+func (r *resolver) openScope() {
+	r.scope = &scope{outer: r.scope, depth: r.depth, symbols: make(map[string]*symbol)}
+}
+
+func (r *resolver) closeScope() {
+	r.scope = r.scope.outer
+}
+
+func (r *resolver) report(code string, node ast.Node, format string, a ...any) *diagnostic.Diagnostic {
+	d := &diagnostic.Diagnostic{
+		Severity: diagnostic.Error,
+		Code:     code,
+		Span:     diagnostic.Span{Start: node.Pos(), End: node.End()},
+		Message:  fmt.Sprintf(format, a...),
+	}
+	r.res.Diagnostics = append(r.res.Diagnostics, d)
+	return d
+}
+
+// define adds a variable to the current scope, it is only visible to the
+// code of the same function once declared.
+func (r *resolver) define(decl *ast.IdentifierLiteral, declared bool) {
+	if prev, ok := r.scope.symbols[decl.Value]; ok {
+		d := r.report(CodeDuplicate, decl, "identifier already declared: %s", decl.Value)
+		d.Notes = append(d.Notes, fmt.Sprintf("%s was first declared at %s", decl.Value, prev.decl.Pos()))
+		return
+	}
+	r.scope.symbols[decl.Value] = &symbol{decl: decl, declared: declared}
+	r.res.Bindings[decl] = Binding{Kind: r.kind(r.scope), Decl: decl}
+}
+
+// declare marks a variable hoisted by define as declared.
+func (r *resolver) declare(decl *ast.IdentifierLiteral) {
+	if s, ok := r.scope.symbols[decl.Value]; ok && s.decl == decl {
+		s.declared = true
+	}
+}
+
+func (r *resolver) kind(s *scope) Kind {
+	switch {
+	case s.outer == nil:
+		return Global
+	case s.depth == r.depth:
+		return Local
+	default:
+		return Upvalue
+	}
+}
+
+func (r *resolver) lookup(id *ast.IdentifierLiteral) (Binding, bool) {
+	for s := r.scope; s != nil; s = s.outer {
+		sym, ok := s.symbols[id.Value]
+		if !ok || (s.depth == r.depth && !sym.declared) {
+			continue
+		}
+		return Binding{Kind: r.kind(s), Decl: sym.decl}, true
+	}
+	if _, ok := evaluator.LookupBuiltin(id.Value); ok {
+		return Binding{Kind: Builtin}, true
+	}
+	return Binding{}, false
+}
+
+func (r *resolver) identifier(id *ast.IdentifierLiteral) {
+	binding, ok := r.lookup(id)
+	if !ok {
+		r.report(CodeUndefined, id, "identifier not found: %s", id.Value)
+		return
+	}
+	r.res.Bindings[id] = binding
+}
+
+// statements resolves a list of statements in the current scope, the
+// variables they declare are defined up front.
+func (r *resolver) statements(stmts []ast.Statement) {
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *ast.DeclareStatement:
+			r.define(stmt.Name, false)
+		case *ast.FunctionStatement:
+			r.define(stmt.Name, false)
+		}
+	}
+
+	for _, stmt := range stmts {
+		r.statement(stmt)
+	}
+}
+
+func (r *resolver) block(block *ast.BlockStatement) {
+	r.openScope()
+	r.statements(block.Statements)
+	r.closeScope()
+}
+
+func (r *resolver) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.ExpressionStatement:
+		r.expression(stmt.Expression)
+	case *ast.BlockStatement:
+		r.block(stmt)
+	case *ast.IfStatement:
+		r.expression(stmt.Condition)
+		r.block(stmt.Consequence)
+		if stmt.Alternate != nil {
+			r.statement(stmt.Alternate)
+		}
+	case *ast.ReturnStatement:
+		if r.depth == 0 {
+			r.report(CodeReturnOutside, stmt, "return outside of a function")
+		}
+		if stmt.ReturnValue != nil {
+			r.expression(stmt.ReturnValue)
+		}
+	case *ast.DeclareStatement:
+		r.expression(stmt.Value)
+		r.declare(stmt.Name)
+	case *ast.FunctionStatement:
+		r.function(stmt.Function)
+		r.declare(stmt.Name)
+	case *ast.AssignStatement:
+		if target, ok := stmt.Target.(*ast.IdentifierLiteral); ok {
+			r.expression(stmt.Value)
+			binding, ok := r.lookup(target)
+			if !ok || binding.Kind == Builtin {
+				r.report(CodeUndefined, target, "assignment to undeclared identifier: %s", target.Value)
+				return
+			}
+			r.res.Bindings[target] = binding
+			return
+		}
+		r.expression(stmt.Target)
+		r.expression(stmt.Value)
+	case *ast.WhileStatement:
+		r.expression(stmt.Condition)
+		r.block(stmt.Body)
+	case *ast.ForInStatement:
+		// the variable and the body share the scope of an iteration
+		r.expression(stmt.Iterable)
+		r.openScope()
+		r.define(stmt.Variable, true)
+		r.statements(stmt.Body.Statements)
+		r.closeScope()
+	case *ast.ForStatement:
+		r.openScope()
+		if stmt.Init != nil {
+			r.statements([]ast.Statement{stmt.Init})
+		}
+		if stmt.Condition != nil {
+			r.expression(stmt.Condition)
+		}
+		if stmt.Post != nil {
+			r.statement(stmt.Post)
+		}
+		r.block(stmt.Body)
+		r.closeScope()
+	case *ast.LabeledStatement:
+		r.statement(stmt.Statement)
+	}
+}
+
+// function resolves a function literal, its parameters and its body share
+// a scope.
+func (r *resolver) function(fl *ast.FunctionLiteral) {
+	r.depth++
+	r.openScope()
+	for _, param := range fl.Parameters {
+		r.define(param, true)
+	}
+	r.statements(fl.Body.Statements)
+	r.closeScope()
+	r.depth--
+}
+
+func (r *resolver) expression(exp ast.Expression) {
+	switch exp := exp.(type) {
+	case *ast.IdentifierLiteral:
+		r.identifier(exp)
+	case *ast.PrefixExpression:
+		r.expression(exp.Expression)
+	case *ast.InfixExpression:
+		r.expression(exp.Left)
+		r.expression(exp.Right)
+	case *ast.InterpolatedString:
+		for _, part := range exp.Parts {
+			r.expression(part)
+		}
+	case *ast.FunctionLiteral:
+		r.function(exp)
+	case *ast.CallExpression:
+		r.expression(exp.Function)
+		for _, arg := range exp.Arguments {
+			r.expression(arg)
+		}
+	case *ast.ArrayLiteral:
+		for _, element := range exp.Elements {
+			r.expression(element)
+		}
+	case *ast.HashLiteral:
+		for _, pair := range exp.Pairs {
+			r.expression(pair.Key)
+			r.expression(pair.Value)
+		}
+	case *ast.IndexExpression:
+		r.expression(exp.Left)
+		r.expression(exp.Index)
+	case *ast.SliceExpression:
+		r.expression(exp.Left)
+		if exp.Low != nil {
+			r.expression(exp.Low)
+		}
+		if exp.High != nil {
+			r.expression(exp.High)
+		}
+	}
+}
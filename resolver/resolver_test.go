@@ -0,0 +1,217 @@
+package resolver
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/chaitanya-Uike/lemon/ast"
+	"github.com/chaitanya-Uike/lemon/lexer"
+	"github.com/chaitanya-Uike/lemon/parser"
+)
+
+func testResolve(t *testing.T, input string) (*ast.Program, *Resolution) {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 0 {
+		for _, msg := range p.Errors() {
+			t.Errorf("parser error: %q", msg)
+		}
+		t.FailNow()
+	}
+
+	return program, Resolve(program)
+}
+
+func TestValidPrograms(t *testing.T) {
+	inputs := []string{
+		"x := 1; x = x + len([1])",
+		"x := 1; if true { x := x + 1; x }",
+		"func isEven(n) { if n == 0 { return true }\nisOdd(n - 1) }\nfunc isOdd(n) { if n == 0 { return false }\nisEven(n - 1) }",
+		"func newCounter() { count := 0; return func() { count = count + 1 } }",
+		"func f() { g() }\nfunc g() { 1 }",
+		"if true { f := func() { x }; x := 2 }",
+		"for x in [1] { y := x }\nfor x in [2] { y := x }",
+		"for i := 0; i < 3; i = i + 1 { i := 1 }",
+		"outer: while true { break outer }",
+		`m := {"a": 1}; m["a"] = 2; m["a":]`,
+		"len := 1; len = 2",
+		`name := "x"; "${name}"`,
+	}
+
+	for _, input := range inputs {
+		_, res := testResolve(t, input)
+		for _, d := range res.Diagnostics {
+			t.Errorf("%s - unexpected diagnostic %s", input, d.Error())
+		}
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	tests := []struct {
+		input    string
+		code     string
+		expected string
+	}{
+		{"x", CodeUndefined, "1:1: identifier not found: x"},
+		{"x := x", CodeUndefined, "1:6: identifier not found: x"},
+		{"f()\nfunc f() {}", CodeUndefined, "1:1: identifier not found: f"},
+		{"if true { b := 1 }\nb", CodeUndefined, "2:1: identifier not found: b"},
+		{"for x in [1] { }\nx", CodeUndefined, "2:1: identifier not found: x"},
+		{"func f(a) { a + b }", CodeUndefined, "1:17: identifier not found: b"},
+		{"b = 1", CodeUndefined, "1:1: assignment to undeclared identifier: b"},
+		{"len = 1", CodeUndefined, "1:1: assignment to undeclared identifier: len"},
+		{"a := 1\na := 2", CodeDuplicate, "2:1: identifier already declared: a"},
+		{"func f(a, a) {}", CodeDuplicate, "1:11: identifier already declared: a"},
+		{"func f(a) { a := 1 }", CodeDuplicate, "1:13: identifier already declared: a"},
+		{"for x in [1] { x := 2 }", CodeDuplicate, "1:16: identifier already declared: x"},
+		{"func f() {}\nf := 1", CodeDuplicate, "2:1: identifier already declared: f"},
+		{"return 1", CodeReturnOutside, "1:1: return outside of a function"},
+		{"if true { return }", CodeReturnOutside, "1:11: return outside of a function"},
+	}
+
+	for _, tt := range tests {
+		_, res := testResolve(t, tt.input)
+		if len(res.Diagnostics) != 1 {
+			t.Fatalf("%s - expected 1 diagnostic, got %d", tt.input, len(res.Diagnostics))
+		}
+
+		d := res.Diagnostics[0]
+		if d.Code != tt.code {
+			t.Errorf("%s - expected code %s, got %s", tt.input, tt.code, d.Code)
+		}
+		if d.Error() != tt.expected {
+			t.Errorf("%s - expected %q, got %q", tt.input, tt.expected, d.Error())
+		}
+	}
+}
+
+func TestDiagnosticOrder(t *testing.T) {
+	_, res := testResolve(t, "a\nx := 1\nb\nx := 2")
+
+	var messages []string
+	for _, d := range res.Diagnostics {
+		messages = append(messages, d.Error())
+	}
+
+	expected := "1:1: identifier not found: a, 3:1: identifier not found: b, 4:1: identifier already declared: x"
+	if strings.Join(messages, ", ") != expected {
+		t.Errorf("Expected %s, got %s", expected, strings.Join(messages, ", "))
+	}
+	if notes := res.Diagnostics[2].Notes; len(notes) != 1 || notes[0] != "x was first declared at 2:1" {
+		t.Errorf("Expected a note on the first declaration, got %q", notes)
+	}
+}
+
+func TestBindings(t *testing.T) {
+	input := `
+g := 1
+func outer(p) {
+	l := p + g
+	return func() { l + p + len([]) }
+}
+if true { b := g; b }
+`
+	program, res := testResolve(t, input)
+	if len(res.Diagnostics) != 0 {
+		t.Fatalf("unexpected diagnostics: %v", res.Diagnostics)
+	}
+
+	// the identifiers in source order with their binding
+	expected := []struct {
+		name string
+		kind Kind
+		line int
+	}{
+		{"g", Global, 2},
+		{"outer", Global, 3},
+		{"p", Local, 3},
+		{"l", Local, 4},
+		{"p", Local, 3},
+		{"g", Global, 2},
+		{"l", Upvalue, 4},
+		{"p", Upvalue, 3},
+		{"len", Builtin, 0},
+		{"b", Local, 7},
+		{"g", Global, 2},
+		{"b", Local, 7},
+	}
+
+	identifiers := collectIdentifiers(program)
+	if len(identifiers) != len(expected) {
+		t.Fatalf("Expected %d identifiers, got %d", len(expected), len(identifiers))
+	}
+
+	for i, tt := range expected {
+		id := identifiers[i]
+		binding, ok := res.Bindings[id]
+		if !ok {
+			t.Fatalf("identifier %d %s has no binding", i, id.Value)
+		}
+		if id.Value != tt.name || binding.Kind != tt.kind {
+			t.Errorf("identifier %d - expected %s %s, got %s %s", i, tt.kind, tt.name, binding.Kind, id.Value)
+		}
+
+		line := 0
+		if binding.Decl != nil {
+			line = binding.Decl.Pos().Line
+		}
+		if line != tt.line {
+			t.Errorf("identifier %d %s - expected declaration on line %d, got %d", i, id.Value, tt.line, line)
+		}
+	}
+}
+
+// collectIdentifiers returns the identifiers with a binding in source order.
+func collectIdentifiers(program *ast.Program) []*ast.IdentifierLiteral {
+	var ids []*ast.IdentifierLiteral
+	var walkStatement func(ast.Statement)
+	var walkExpression func(ast.Expression)
+
+	walkExpression = func(exp ast.Expression) {
+		switch exp := exp.(type) {
+		case *ast.IdentifierLiteral:
+			ids = append(ids, exp)
+		case *ast.InfixExpression:
+			walkExpression(exp.Left)
+			walkExpression(exp.Right)
+		case *ast.CallExpression:
+			walkExpression(exp.Function)
+			for _, arg := range exp.Arguments {
+				walkExpression(arg)
+			}
+		case *ast.FunctionLiteral:
+			for _, param := range exp.Parameters {
+				ids = append(ids, param)
+			}
+			for _, stmt := range exp.Body.Statements {
+				walkStatement(stmt)
+			}
+		}
+	}
+	walkStatement = func(stmt ast.Statement) {
+		switch stmt := stmt.(type) {
+		case *ast.ExpressionStatement:
+			walkExpression(stmt.Expression)
+		case *ast.DeclareStatement:
+			ids = append(ids, stmt.Name)
+			walkExpression(stmt.Value)
+		case *ast.FunctionStatement:
+			ids = append(ids, stmt.Name)
+			walkExpression(stmt.Function)
+		case *ast.ReturnStatement:
+			walkExpression(stmt.ReturnValue)
+		case *ast.IfStatement:
+			walkExpression(stmt.Condition)
+			for _, stmt := range stmt.Consequence.Statements {
+				walkStatement(stmt)
+			}
+		}
+	}
+
+	for _, stmt := range program.Statements {
+		walkStatement(stmt)
+	}
+	return ids
+}
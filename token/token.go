@@ -1,46 +1,87 @@
 package token
 
+import "fmt"
+
 type TokenType string
 
+// Position is a location in the source. Offset is a 0-based byte offset,
+// Line and Column are 1-based, Column counting bytes from the start of the line.
+type Position struct {
+	Offset int
+	Line   int
+	Column int
+}
+
+func (p Position) IsValid() bool { return p.Line > 0 }
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Token spans the source from Pos up to, but not including, End.
 type Token struct {
 	Type    TokenType
 	Literal string
+	Pos     Position
+	End     Position
 }
 
 const (
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
 
-	IDENT = "IDENT"
-	INT   = "INT"
-	FLOAT = "FLOAT"
+	IDENT   = "IDENT"
+	INT     = "INT"
+	FLOAT   = "FLOAT"
+	DECIMAL = "DECIMAL"
+	STRING  = "STRING"
 
 	ASSIGN   = "="
+	DECLARE  = ":="
 	PLUS     = "+"
 	MINUS    = "-"
 	BANG     = "!"
 	ASTERISK = "*"
 	SLASH    = "/"
+	PERCENT  = "%"
 
 	EQ     = "=="
 	NOT_EQ = "!="
 	LT     = "<"
 	GT     = ">"
+	LT_EQ  = "<="
+	GT_EQ  = ">="
+
+	AND = "&&"
+	OR  = "||"
+
+	AMPERSAND = "&"
+	PIPE      = "|"
+	CARET     = "^"
+	TILDE     = "~"
+	SHL       = "<<"
+	SHR       = ">>"
 
 	LPAREN = "("
 	RPAREN = ")"
 	LBRACE = "{"
 	RBRACE = "}"
 
-	COMMA = ","
+	LBRACKET = "["
+	RBRACKET = "]"
+
+	COMMA    = ","
+	COLON    = ":"
+	QUESTION = "?"
 	/*
 		 * semicolon auto added by lexer using following rules
 			* after a line's final token (i.e. token before '\n')
 			* if that last token is one of:
 				* identifier
 				* literal
-				* return
+				* return, break or continue
 				* )
+				* ]
 	*/
 	SEMICOLON = ";"
 
@@ -50,6 +91,12 @@ const (
 	IF     = "IF"
 	ELSE   = "ELSE"
 	RETURN = "RETURN"
+
+	WHILE    = "WHILE"
+	FOR      = "FOR"
+	IN       = "IN"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
 )
 
 var keywords = map[string]TokenType{
@@ -59,6 +106,12 @@ var keywords = map[string]TokenType{
 	"if":     IF,
 	"else":   ELSE,
 	"return": RETURN,
+
+	"while":    WHILE,
+	"for":      FOR,
+	"in":       IN,
+	"break":    BREAK,
+	"continue": CONTINUE,
 }
 
 func LookupIdent(ident string) TokenType {
@@ -0,0 +1,309 @@
+package vm_test
+
+import (
+	"testing"
+
+	"github.com/chaitanya-Uike/lemon/ast"
+	"github.com/chaitanya-Uike/lemon/compiler"
+	"github.com/chaitanya-Uike/lemon/evaluator"
+	"github.com/chaitanya-Uike/lemon/lexer"
+	"github.com/chaitanya-Uike/lemon/object"
+	"github.com/chaitanya-Uike/lemon/parser"
+	"github.com/chaitanya-Uike/lemon/resolver"
+	"github.com/chaitanya-Uike/lemon/vm"
+)
+
+// conformanceTests run on both the evaluator and the vm, the two must agree
+// on the result of every program.
+var conformanceTests = []struct {
+	input    string
+	expected string
+}{
+	// arithmetic
+	{"5 + 5 * 2 - 10 / 2", "10"},
+	{"7 % 3 + (12 & 10) + (1 | 2) + (6 ^ 3) + (1 << 4) + (32 >> 2)", "41"},
+	{"-5 + ~5", "-11"},
+	{"7 / 2.0", "3.5"},
+	{"1 == 1.0", "true"},
+	{"9223372036854775807 + 1", "9223372036854775808"},
+	{"1 << 64", "18446744073709551616"},
+	{"0.1d + 0.2d", "0.3"},
+	{"3 * 19.99d", "59.97"},
+	{"1 < 2 && 2 > 3", "false"},
+	{"0 || 2", "true"},
+	{"!(1 <= 2) == false", "true"},
+
+	// strings
+	{`"Hello" + " " + "World!"`, "Hello World!"},
+	{`x := "in"; "out ${"mid ${x}"} out"`, "out mid in out"},
+	{`n := 3; "${n} * 2 = ${n * 2}"`, "3 * 2 = 6"},
+	{`"héllo"[1:4]`, "éll"},
+	{`"abc"[-1]`, "c"},
+
+	// arrays and hashes
+	{"[1, 2 * 2, 3 + 3]", "[1, 4, 6]"},
+	{"xs := [1, 2, 3]; xs[0] = 9; xs[-1] = 7; xs", "[9, 2, 7]"},
+	{"xs := [1, 2]; xs[0] = xs; xs", "[[...], 2]"},
+	{`m := {"a": 1}; m["self"] = m; [m]`, `[{"a": 1, "self": {...}}]`},
+	{"xs := [1]; [xs, xs]", "[[1], [1]]"},
+	{"[1, 2, 3, 4][1:3]", "[2, 3]"},
+	{"[1, 2, 3, 4][:2]", "[1, 2]"},
+	{"[1, 2, 3, 4][-2:]", "[3, 4]"},
+	{"[1, 2, 3][:]", "[1, 2, 3]"},
+	{`m := {"a": 1, 2: "two"}; m["b"] = m["a"] + 1; m`, `{"a": 1, 2: two, "b": 2}`},
+	{`({"foo": 5})["bar"]`, "null"},
+	{`keys({"b": 1, "a": 2})`, "[b, a]"},
+	{"xs := [1]; ys := push(xs, 2, 3); len(xs) * 10 + len(ys)", "13"},
+
+	// conditionals and implicit results
+	{"if 1 > 2 { 10 }", "null"},
+	{"if 1 > 2 { 10 } else if 2 > 1 { 20 } else { 30 }", "20"},
+	{"func f(x) { if x { 1 } else { 2 } }\nf(false)", "2"},
+	{"func f() { }\nf()", "null"},
+	{"return 2 * 5; 9", "10"},
+	{"a := 1", "null"},
+	{"a := 1; a = 2", "null"},
+
+	// variables and scopes
+	{"a := 5; b := a; c := a + b + 5; c", "15"},
+	{"a := 1; if true { if true { a = a + 2 } }\na", "3"},
+	{"a := 1; if true { a := 2 }\na", "1"},
+	{"if true { a := 1; if true { b := a + 1; a = b } }", "null"},
+	{"a := 1; a := 2", "ERROR: identifier already declared: a"},
+	{"b = 1", "ERROR: assignment to undeclared identifier: b"},
+	{"if true { b := 1 }\nb", "ERROR: identifier not found: b"},
+	{"x := x", "ERROR: identifier not found: x"},
+
+	// functions and closures
+	{"func(x, y) { x + y }(5 + 5, func(x) { x }(10))", "20"},
+	{"func fact(n) { if n < 2 { return 1 }\nreturn n * fact(n - 1) }\nfact(20)", "2432902008176640000"},
+	{"func fib(n) { if n < 2 { return n }\nfib(n - 1) + fib(n - 2) }\nfib(15)", "610"},
+	{`
+	func isEven(n) { if n == 0 { return true }
+	isOdd(n - 1) }
+	func isOdd(n) { if n == 0 { return false }
+	isEven(n - 1) }
+	isEven(10)`, "true"},
+	{`
+	func newAdder(x) {
+		return func(y) { x + y }
+	}
+	newAdder(2)(3)`, "5"},
+	{`
+	func newCounter() {
+		count := 0
+		return func() {
+			count = count + 1
+			return count
+		}
+	}
+	a := newCounter()
+	b := newCounter()
+	a(); a(); b()
+	a() * 10 + b()`, "32"},
+	{`
+	x := 1
+	get := func() { x }
+	x = 2
+	get()`, "2"},
+	{`
+	func outer() {
+		x := 1
+		func middle() {
+			func inner() { x = x * 10 }
+			inner()
+		}
+		middle()
+		return x
+	}
+	outer()`, "10"},
+	{`
+	x := 1
+	if true {
+		x := 2
+		f := func() { x }
+		x = 3
+		if f() != 3 { return 0 }
+	}
+	x`, "1"},
+	{`
+	func make() {
+		fs := []
+		for i := 0; i < 3; i = i + 1 {
+			n := i
+			fs = push(fs, func() { n })
+		}
+		fs
+	}
+	fs := make()
+	fs[0]() + fs[1]() * 10 + fs[2]() * 100`, "210"},
+	{`
+	func f(n) {
+		g := func() { n = n + 1 }
+		g(); g()
+		n
+	}
+	f(1)`, "3"},
+	{"func add(a: int, b: int): int { a + b }\nf: func(int): int? := func(x) { add(x, 1) }\nn: int := f(2)\nn", "3"},
+
+	// loops
+	{"i := 0\nwhile i < 5 { i = i + 1 }\ni", "5"},
+	{"sum := 0\nfor i := 0; i < 5; i = i + 1 { sum = sum + i }\nsum", "10"},
+	{"sum := 0\nfor x in [1, 2, 3, 4] { if x == 2 { continue }\nsum = sum + x }\nsum", "8"},
+	{"i := 0\nfor ;; { i = i + 1; if i > 3 { break } }\ni", "4"},
+	{`out := ""; for c in "héllo" { out = c + out }; out`, "olléh"},
+	{`out := ""; for k in ({"b": 1, "a": 2}) { out = out + k }; out`, "ba"},
+	{`
+	n := 0
+	outer: for x in [1, 2, 3] {
+		for y in [1, 2, 3] {
+			if y == 2 { continue outer }
+			if x == 3 { break outer }
+			n = n + 1
+		}
+	}
+	n`, "2"},
+	{`
+	n := 0
+	loop: while true {
+		for i := 0; ; i = i + 1 {
+			n = n + i
+			if i == 3 { break loop }
+		}
+	}
+	n`, "6"},
+	{`
+	func find(xs, target) {
+		i := 0
+		while true {
+			if xs[i] == target { return i }
+			i = i + 1
+		}
+	}
+	find([5, 6, 7], 7)`, "2"},
+	{`
+	fs := []
+	for x in [1, 2] { fs = push(fs, func() { x }) }
+	fs[0]() * 10 + fs[1]()`, "12"},
+	{"xs := [1]; for x in xs { xs = push(xs, x); if len(xs) > 3 { break } }; len(xs)", "2"},
+	{"for x in [1] { y := x }\ny", "ERROR: identifier not found: y"},
+	{"for i := 0; i < 1; i = i + 1 {}\ni", "ERROR: identifier not found: i"},
+	{"func f() { x := 1 }\nwhile true { f(); break }", "null"},
+
+	// errors and builtins
+	{"5 + true", "ERROR: type mismatch: INTEGER + BOOLEAN"},
+	{"-false", "ERROR: unknown operator: -BOOLEAN"},
+	{"5 % 0", "ERROR: division by zero"},
+	{"1.5 == 1.5d", "ERROR: type mismatch: FLOAT == DECIMAL"},
+	{`"abc"[5]`, "ERROR: index out of range: 5 (length 3)"},
+	{"[1, 2, 3][1:5]", "ERROR: slice bounds out of range: 5 (length 3)"},
+	{"m := {}; m[{}] = 1", "ERROR: unusable as hash key: HASH"},
+	{"({[1]: 2})", "ERROR: unusable as hash key: ARRAY"},
+	{"for x in 5 {}", "ERROR: cannot iterate over INTEGER"},
+	{"len(1)", "ERROR: argument to `len` not supported, got INTEGER"},
+	{"func(x) { x }(1, 2)", "ERROR: wrong number of arguments. got=2, want=1"},
+	{"1(2)", "ERROR: not a function: INTEGER"},
+	{"foo", "ERROR: identifier not found: foo"},
+	{`len("日本語") + int(2.5) + len(str(1234))`, "9"},
+	{"decimal(0.1) + decimal(\"0.2\")", "0.3"},
+	{"puts()", "null"},
+}
+
+func TestConformance(t *testing.T) {
+	for _, tt := range conformanceTests {
+		program := parse(t, tt.input)
+
+		evaluated := inspect(evaluator.Eval(program, object.NewEnvironment()), nil)
+		if evaluated != tt.expected {
+			t.Errorf("evaluator: %s - expected %s, got %s", tt.input, tt.expected, evaluated)
+		}
+
+		executed := run(program)
+		if executed != tt.expected {
+			t.Errorf("vm: %s - expected %s, got %s", tt.input, tt.expected, executed)
+		}
+	}
+}
+
+// TestCompileTimeErrors covers the programs on which the two differ: the
+// compiler rejects errors in code that never runs, which the evaluator only
+// reports when it gets to them. The resolver rejects the programs first.
+func TestCompileTimeErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"if false { a := 1; a := 2 }\n1", "ERROR: identifier already declared: a"},
+		{"if false { b = 1 }\n1", "ERROR: assignment to undeclared identifier: b"},
+	}
+
+	for _, tt := range tests {
+		program := parse(t, tt.input)
+
+		evaluated := inspect(evaluator.Eval(program, object.NewEnvironment()), nil)
+		if evaluated != "1" {
+			t.Errorf("evaluator: %s - expected 1, got %s", tt.input, evaluated)
+		}
+
+		executed := run(program)
+		if executed != tt.expected {
+			t.Errorf("vm: %s - expected %s, got %s", tt.input, tt.expected, executed)
+		}
+
+		if res := resolver.Resolve(program); len(res.Diagnostics) == 0 {
+			t.Errorf("resolver: %s - expected a diagnostic, got none", tt.input)
+		}
+	}
+}
+
+func parse(t testing.TB, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+	return program
+}
+
+func run(program *ast.Program) string {
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		return inspect(nil, err)
+	}
+
+	machine := vm.New(comp.Bytecode())
+	if err := machine.Run(); err != nil {
+		return inspect(nil, err)
+	}
+	return inspect(machine.Result(), nil)
+}
+
+// inspect prints a result the same way for both implementations.
+func inspect(result object.Object, err error) string {
+	switch {
+	case err != nil:
+		return "ERROR: " + err.Error()
+	case result == nil:
+		return "null"
+	}
+	return result.Inspect()
+}
+
+const fibInput = `
+func fib(n) { if n < 2 { return n }
+fib(n - 1) + fib(n - 2) }
+fib(20)`
+
+func BenchmarkEvaluatorFib(b *testing.B) {
+	program := parse(b, fibInput)
+	for b.Loop() {
+		evaluator.Eval(program, object.NewEnvironment())
+	}
+}
+
+func BenchmarkVMFib(b *testing.B) {
+	program := parse(b, fibInput)
+	for b.Loop() {
+		run(program)
+	}
+}
@@ -0,0 +1,476 @@
+// Package vm executes the bytecode produced by the compiler. Programs give
+// the same results as with the evaluator, except that functions print
+// differently and recursion is limited to MaxFrames calls.
+package vm
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/chaitanya-Uike/lemon/code"
+	"github.com/chaitanya-Uike/lemon/compiler"
+	"github.com/chaitanya-Uike/lemon/evaluator"
+	"github.com/chaitanya-Uike/lemon/object"
+)
+
+const (
+	StackSize = 2048
+	MaxFrames = 1024
+)
+
+var errStackOverflow = errors.New("stack overflow")
+
+// errInvalidBytecode is returned for instructions the compiler doesn't write,
+// bytecode read from a file is verified before it runs.
+var errInvalidBytecode = errors.New("invalid bytecode")
+
+var infixOperators = map[code.Opcode]string{
+	code.OpAdd:          "+",
+	code.OpSub:          "-",
+	code.OpMul:          "*",
+	code.OpDiv:          "/",
+	code.OpMod:          "%",
+	code.OpBitAnd:       "&",
+	code.OpBitOr:        "|",
+	code.OpBitXor:       "^",
+	code.OpShl:          "<<",
+	code.OpShr:          ">>",
+	code.OpEqual:        "==",
+	code.OpNotEqual:     "!=",
+	code.OpLess:         "<",
+	code.OpGreater:      ">",
+	code.OpLessEqual:    "<=",
+	code.OpGreaterEqual: ">=",
+}
+
+var prefixOperators = map[code.Opcode]string{
+	code.OpMinus:  "-",
+	code.OpBang:   "!",
+	code.OpBitNot: "~",
+}
+
+// iterator is the state of a for-in loop, it lives in a hidden local.
+type iterator struct {
+	items []object.Object
+	pos   int
+}
+
+func (it *iterator) Type() object.ObjectType { return "ITERATOR" }
+func (it *iterator) Inspect() string         { return "iterator" }
+
+// VM runs a compiled program. The operations on values are the evaluator's,
+// so both give the same results and errors.
+type VM struct {
+	constants []object.Object
+	globals   []object.Object
+	names     []string
+
+	stack []object.Object
+	sp    int // always points to the next free slot, the top is stack[sp-1]
+
+	frames      []*Frame
+	framesIndex int
+
+	result object.Object
+}
+
+// New returns a vm running bytecode written by the compiler or read with
+// UnmarshalBinary, which verifies it. Other bytecode may make Run fail with
+// an invalid bytecode error.
+func New(bytecode *compiler.Bytecode) *VM {
+	mainFn := &object.CompiledFunction{Instructions: bytecode.Instructions, NumLocals: bytecode.NumLocals}
+	mainFrame := NewFrame(&object.Closure{Fn: mainFn}, 0)
+
+	frames := make([]*Frame, MaxFrames)
+	frames[0] = mainFrame
+
+	return &VM{
+		constants:   bytecode.Constants,
+		globals:     make([]object.Object, len(bytecode.Globals)),
+		names:       bytecode.Globals,
+		stack:       make([]object.Object, StackSize),
+		sp:          bytecode.NumLocals,
+		frames:      frames,
+		framesIndex: 1,
+	}
+}
+
+// Result is the value the program evaluated to, like the result of
+// evaluator.Eval.
+func (vm *VM) Result() object.Object {
+	return vm.result
+}
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[vm.framesIndex-1]
+}
+
+func (vm *VM) pushFrame(f *Frame) error {
+	if vm.framesIndex == MaxFrames {
+		return errStackOverflow
+	}
+	vm.frames[vm.framesIndex] = f
+	vm.framesIndex++
+	return nil
+}
+
+func (vm *VM) popFrame() *Frame {
+	vm.framesIndex--
+	return vm.frames[vm.framesIndex]
+}
+
+func (vm *VM) Run() error {
+	var ip int
+	var ins code.Instructions
+	var op code.Opcode
+
+	for vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
+		frame := vm.currentFrame()
+		frame.ip++
+
+		ip = frame.ip
+		ins = frame.Instructions()
+		op = code.Opcode(ins[ip])
+
+		var err error
+		switch op {
+		case code.OpConstant:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			err = vm.push(vm.constants[constIndex])
+		case code.OpPop:
+			vm.pop()
+		case code.OpTrue:
+			err = vm.push(evaluator.TRUE)
+		case code.OpFalse:
+			err = vm.push(evaluator.FALSE)
+		case code.OpNull:
+			err = vm.push(evaluator.NULL)
+
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod,
+			code.OpBitAnd, code.OpBitOr, code.OpBitXor, code.OpShl, code.OpShr,
+			code.OpEqual, code.OpNotEqual, code.OpLess, code.OpGreater, code.OpLessEqual, code.OpGreaterEqual:
+			right := vm.pop()
+			left := vm.pop()
+			err = vm.pushResult(evaluator.EvalInfix(infixOperators[op], left, right))
+		case code.OpMinus, code.OpBang, code.OpBitNot:
+			err = vm.pushResult(evaluator.EvalPrefix(prefixOperators[op], vm.pop()))
+
+		case code.OpJump:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip = pos - 1
+		case code.OpJumpNotTruthy:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+			if !evaluator.IsTruthy(vm.pop()) {
+				frame.ip = pos - 1
+			}
+
+		case code.OpGetGlobal:
+			index := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			val := vm.globals[index]
+			if val == nil {
+				return fmt.Errorf("identifier not found: %s", vm.names[index])
+			}
+			err = vm.push(val)
+		case code.OpSetGlobal:
+			index := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			vm.globals[index] = vm.pop()
+		case code.OpGetLocal:
+			index := code.ReadUint8(ins[ip+1:])
+			frame.ip += 1
+			val := vm.stack[frame.basePointer+int(index)]
+			if val == nil {
+				return errInvalidBytecode
+			}
+			err = vm.push(val)
+		case code.OpSetLocal:
+			index := code.ReadUint8(ins[ip+1:])
+			frame.ip += 1
+			vm.stack[frame.basePointer+int(index)] = vm.pop()
+		case code.OpNewCell:
+			index := code.ReadUint8(ins[ip+1:])
+			name := code.ReadUint16(ins[ip+2:])
+			frame.ip += 3
+			var s *object.String
+			if s, err = vm.name(int(name)); err == nil {
+				vm.stack[frame.basePointer+int(index)] = &object.Cell{Name: s.Value}
+			}
+		case code.OpGetCell:
+			index := code.ReadUint8(ins[ip+1:])
+			frame.ip += 1
+			var cell *object.Cell
+			if cell, err = vm.cell(frame, int(index)); err == nil {
+				err = vm.pushCell(cell)
+			}
+		case code.OpSetCell:
+			index := code.ReadUint8(ins[ip+1:])
+			frame.ip += 1
+			var cell *object.Cell
+			if cell, err = vm.cell(frame, int(index)); err == nil {
+				cell.Value = vm.pop()
+			}
+		case code.OpGetFree:
+			index := code.ReadUint8(ins[ip+1:])
+			frame.ip += 1
+			err = vm.pushCell(frame.cl.Free[index])
+		case code.OpSetFree:
+			index := code.ReadUint8(ins[ip+1:])
+			frame.ip += 1
+			frame.cl.Free[index].Value = vm.pop()
+		case code.OpGetBuiltin:
+			index := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			name, nameErr := vm.name(int(index))
+			if nameErr != nil {
+				return nameErr
+			}
+			builtin, ok := evaluator.LookupBuiltin(name.Value)
+			if !ok {
+				return fmt.Errorf("identifier not found: %s", name.Value)
+			}
+			err = vm.push(builtin)
+
+		case code.OpArray:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+			elements := make([]object.Object, numElements)
+			copy(elements, vm.stack[vm.sp-numElements:vm.sp])
+			vm.sp -= numElements
+			err = vm.push(&object.Array{Elements: elements})
+		case code.OpHash:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+			hash, hashErr := vm.buildHash(vm.sp-numElements, vm.sp)
+			if hashErr != nil {
+				return hashErr
+			}
+			vm.sp -= numElements
+			err = vm.push(hash)
+		case code.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+			err = vm.pushResult(evaluator.EvalIndex(left, index))
+		case code.OpSetIndex:
+			val := vm.pop()
+			index := vm.pop()
+			left := vm.pop()
+			if errObj, ok := evaluator.EvalIndexAssignment(left, index, val).(*object.Error); ok {
+				return runtimeError(errObj)
+			}
+		case code.OpSlice:
+			bounds := code.ReadUint8(ins[ip+1:])
+			frame.ip += 1
+			var low, high object.Object
+			if bounds&2 != 0 {
+				high = vm.pop()
+			}
+			if bounds&1 != 0 {
+				low = vm.pop()
+			}
+			err = vm.pushResult(evaluator.EvalSlice(vm.pop(), low, high))
+		case code.OpInterpolate:
+			numParts := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+			var out strings.Builder
+			for _, part := range vm.stack[vm.sp-numParts : vm.sp] {
+				out.WriteString(part.Inspect())
+			}
+			vm.sp -= numParts
+			err = vm.push(&object.String{Value: out.String()})
+
+		case code.OpIter:
+			items, errObj := evaluator.IterationItems(vm.pop())
+			if errObj != nil {
+				return runtimeError(errObj)
+			}
+			err = vm.push(&iterator{items: items})
+		case code.OpNext:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+			it, ok := vm.pop().(*iterator)
+			if !ok {
+				return errInvalidBytecode
+			}
+			if it.pos == len(it.items) {
+				frame.ip = pos - 1
+				break
+			}
+			it.pos++
+			err = vm.push(it.items[it.pos-1])
+
+		case code.OpCaptureLocal:
+			index := code.ReadUint8(ins[ip+1:])
+			frame.ip += 1
+			err = vm.push(vm.stack[frame.basePointer+int(index)])
+		case code.OpCaptureFree:
+			index := code.ReadUint8(ins[ip+1:])
+			frame.ip += 1
+			err = vm.push(frame.cl.Free[index])
+		case code.OpClosure:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			numFree := int(code.ReadUint8(ins[ip+3:]))
+			frame.ip += 3
+			err = vm.pushClosure(int(constIndex), numFree)
+		case code.OpCall:
+			numArgs := code.ReadUint8(ins[ip+1:])
+			frame.ip += 1
+			err = vm.executeCall(int(numArgs))
+		case code.OpReturnValue:
+			returnValue := vm.pop()
+
+			frame := vm.popFrame()
+			if vm.framesIndex == 0 {
+				vm.result = returnValue
+				return nil
+			}
+			vm.sp = frame.basePointer - 1
+			err = vm.push(returnValue)
+
+		default:
+			def, lookupErr := code.Lookup(byte(op))
+			if lookupErr != nil {
+				return lookupErr
+			}
+			return fmt.Errorf("unhandled opcode %s", def.Name)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (vm *VM) push(o object.Object) error {
+	if vm.sp >= StackSize {
+		return errStackOverflow
+	}
+	vm.stack[vm.sp] = o
+	vm.sp++
+	return nil
+}
+
+func (vm *VM) pop() object.Object {
+	o := vm.stack[vm.sp-1]
+	vm.sp--
+	return o
+}
+
+// pushResult pushes the result of an operation, unless it failed.
+func (vm *VM) pushResult(o object.Object) error {
+	if errObj, ok := o.(*object.Error); ok {
+		return runtimeError(errObj)
+	}
+	return vm.push(o)
+}
+
+// name returns the constant naming a variable or a builtin.
+func (vm *VM) name(index int) (*object.String, error) {
+	s, ok := vm.constants[index].(*object.String)
+	if !ok {
+		return nil, errInvalidBytecode
+	}
+	return s, nil
+}
+
+// cell returns the cell in a local slot of frame.
+func (vm *VM) cell(frame *Frame, index int) (*object.Cell, error) {
+	cell, ok := vm.stack[frame.basePointer+index].(*object.Cell)
+	if !ok {
+		return nil, errInvalidBytecode
+	}
+	return cell, nil
+}
+
+func (vm *VM) pushCell(cell *object.Cell) error {
+	if cell.Value == nil {
+		return fmt.Errorf("identifier not found: %s", cell.Name)
+	}
+	return vm.push(cell.Value)
+}
+
+func runtimeError(errObj *object.Error) error {
+	return errors.New(errObj.Message)
+}
+
+func (vm *VM) buildHash(startIndex, endIndex int) (object.Object, error) {
+	hash := object.NewHash()
+
+	for i := startIndex; i < endIndex; i += 2 {
+		key := vm.stack[i]
+		value := vm.stack[i+1]
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return nil, fmt.Errorf("unusable as hash key: %s", key.Type())
+		}
+		hash.Set(hashKey, value)
+	}
+
+	return hash, nil
+}
+
+func (vm *VM) pushClosure(constIndex, numFree int) error {
+	fn, ok := vm.constants[constIndex].(*object.CompiledFunction)
+	if !ok {
+		return fmt.Errorf("not a function: %+v", vm.constants[constIndex])
+	}
+
+	free := make([]*object.Cell, numFree)
+	for i := range numFree {
+		cell, ok := vm.stack[vm.sp-numFree+i].(*object.Cell)
+		if !ok {
+			return errInvalidBytecode
+		}
+		free[i] = cell
+	}
+	vm.sp -= numFree
+
+	return vm.push(&object.Closure{Fn: fn, Free: free})
+}
+
+func (vm *VM) executeCall(numArgs int) error {
+	callee := vm.stack[vm.sp-1-numArgs]
+	switch callee := callee.(type) {
+	case *object.Closure:
+		return vm.callClosure(callee, numArgs)
+	case *object.Builtin:
+		return vm.callBuiltin(callee, numArgs)
+	default:
+		return fmt.Errorf("not a function: %s", callee.Type())
+	}
+}
+
+func (vm *VM) callClosure(cl *object.Closure, numArgs int) error {
+	if numArgs != cl.Fn.NumParameters {
+		return fmt.Errorf("wrong number of arguments. got=%d, want=%d", numArgs, cl.Fn.NumParameters)
+	}
+
+	frame := NewFrame(cl, vm.sp-numArgs)
+	if err := vm.pushFrame(frame); err != nil {
+		return err
+	}
+
+	vm.sp = frame.basePointer + cl.Fn.NumLocals
+	if vm.sp >= StackSize {
+		return errStackOverflow
+	}
+	return nil
+}
+
+func (vm *VM) callBuiltin(builtin *object.Builtin, numArgs int) error {
+	args := vm.stack[vm.sp-numArgs : vm.sp]
+
+	result := builtin.Fn(args...)
+	vm.sp = vm.sp - numArgs - 1
+
+	if result == nil {
+		result = evaluator.NULL
+	}
+	return vm.pushResult(result)
+}
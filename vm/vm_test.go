@@ -0,0 +1,105 @@
+package vm_test
+
+import (
+	"testing"
+
+	"github.com/chaitanya-Uike/lemon/code"
+	"github.com/chaitanya-Uike/lemon/compiler"
+	"github.com/chaitanya-Uike/lemon/object"
+	"github.com/chaitanya-Uike/lemon/vm"
+)
+
+func TestStackOverflow(t *testing.T) {
+	tests := []string{
+		"func f() { f() }\nf()",
+		"func f(n) { 1 + f(n + 1) }\nf(0)",
+	}
+
+	for _, input := range tests {
+		result := run(parse(t, input))
+		if result != "ERROR: stack overflow" {
+			t.Errorf("%s - expected stack overflow, got %s", input, result)
+		}
+	}
+}
+
+func TestClosureResult(t *testing.T) {
+	comp := compiler.New()
+	if err := comp.Compile(parse(t, "func(x) { x }")); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	machine := vm.New(comp.Bytecode())
+	if err := machine.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+
+	result := machine.Result()
+	if result.Type() != object.FUNCTION_OBJ {
+		t.Fatalf("Expected %s, got %s", object.FUNCTION_OBJ, result.Type())
+	}
+	if _, ok := result.(*object.Closure); !ok {
+		t.Fatalf("Expected *object.Closure, got %T", result)
+	}
+}
+
+func TestInvalidBytecode(t *testing.T) {
+	ins := func(instructions ...[]byte) code.Instructions {
+		var out code.Instructions
+		for _, i := range instructions {
+			out = append(out, i...)
+		}
+		return out
+	}
+
+	tests := []*compiler.Bytecode{
+		{Instructions: ins(code.Make(code.OpNull), code.Make(code.OpNext, 4), code.Make(code.OpReturnValue))},
+		{
+			Instructions: ins(code.Make(code.OpNull), code.Make(code.OpSetLocal, 0), code.Make(code.OpGetCell, 0), code.Make(code.OpReturnValue)),
+			NumLocals:    1,
+		},
+		{
+			Instructions: ins(code.Make(code.OpGetBuiltin, 0), code.Make(code.OpReturnValue)),
+			Constants:    []object.Object{&object.Integer{Value: 1}},
+		},
+		{
+			Instructions: ins(code.Make(code.OpGetLocal, 0), code.Make(code.OpReturnValue)),
+			NumLocals:    1,
+		},
+		{
+			Instructions: ins(code.Make(code.OpNull), code.Make(code.OpClosure, 0, 1), code.Make(code.OpReturnValue)),
+			Constants:    []object.Object{&object.CompiledFunction{Instructions: ins(code.Make(code.OpNull), code.Make(code.OpReturnValue))}},
+		},
+	}
+
+	for _, bytecode := range tests {
+		err := vm.New(bytecode).Run()
+		if err == nil || err.Error() != "invalid bytecode" {
+			t.Errorf("%s - expected invalid bytecode, got %v", bytecode.Instructions, err)
+		}
+	}
+}
+
+func TestBytecodeFiles(t *testing.T) {
+	for _, tt := range conformanceTests {
+		comp := compiler.New()
+		if err := comp.Compile(parse(t, tt.input)); err != nil {
+			continue
+		}
+
+		data, err := comp.Bytecode().MarshalBinary()
+		if err != nil {
+			t.Fatalf("%s - marshal error: %s", tt.input, err)
+		}
+		bytecode := &compiler.Bytecode{}
+		if err := bytecode.UnmarshalBinary(data); err != nil {
+			t.Fatalf("%s - unmarshal error: %s", tt.input, err)
+		}
+
+		machine := vm.New(bytecode)
+		err = machine.Run()
+		executed := inspect(machine.Result(), err)
+		if executed != tt.expected {
+			t.Errorf("%s - expected %s, got %s", tt.input, tt.expected, executed)
+		}
+	}
+}